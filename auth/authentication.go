@@ -43,8 +43,9 @@ func GetJWT(userid string) (string, error) {
 	return tokenString, nil
 }
 
-/* Authenticate Token for subsequent requests */
-func AuthenticateToken(r *http.Request, userid string) bool {
+/* Authenticate Token for subsequent requests and return the user id (client) it was issued to.
+Access to the payload user/portfolio is authorized separately based on portfolio grants */
+func AuthenticateToken(r *http.Request) (string, bool) {
 	if r.Header["Token"] != nil {
 
 		mySigningKey := []byte(util.GetAppUtil().Config.AuthKey)
@@ -55,33 +56,32 @@ func AuthenticateToken(r *http.Request, userid string) bool {
 		if err != nil {
 			util.GetAppUtil().AppLogger.Println("Error while parsing Token")
 			util.GetAppUtil().AppLogger.Println(err)
-			return false
+			return "", false
 		}
 
-		/* When Token is valid - compare userid from token and request */
+		/* When Token is valid - return userid from token */
 		if token.Valid {
 			claims, ok := token.Claims.(jwt.MapClaims)
 			if ok {
-				util.GetAppUtil().AppLogger.Println("userid in token - ", claims["client"])
-				util.GetAppUtil().AppLogger.Println("userid in request - ", userid)
-
-				if userid == claims["client"] {
+				tokenUserId, ok := claims["client"].(string)
+				if ok && tokenUserId != "" {
+					util.GetAppUtil().AppLogger.Println("userid in token - ", tokenUserId)
 					util.GetAppUtil().AppLogger.Println("Token Authenticated")
-					return true
+					return tokenUserId, true
 				} else {
-					util.GetAppUtil().AppLogger.Println("Userid in token does not match with User id in request")
-					return false
+					util.GetAppUtil().AppLogger.Println("Userid not found in token")
+					return "", false
 				}
 			} else {
-				return false
+				return "", false
 			}
 		} else {
 			util.GetAppUtil().AppLogger.Println("Invalid Token")
-			return false
+			return "", false
 		}
 	} else {
 		util.GetAppUtil().AppLogger.Println("Token Not Found")
-		return false
+		return "", false
 	}
 }
 
@@ -0,0 +1,139 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+)
+
+/* Counters of a cache since start */
+type Stats struct {
+	Name          string
+	Entries       int
+	Capacity      int
+	Hits          uint64
+	Misses        uint64
+	Loads         uint64
+	Evictions     uint64
+	Invalidations uint64
+}
+
+/* Concurrency safe key/value cache. Least recently used entries are evicted beyond capacity, zero capacity is unbounded.
+** Values are shared between readers and must not be modified once stored */
+type Cache struct {
+	name       string
+	mutex      sync.Mutex
+	capacity   int
+	entries    map[string]*list.Element
+	order      *list.List
+	generation uint64
+	stats      Stats
+}
+
+type entry struct {
+	key   string
+	value interface{}
+}
+
+func New(name string, capacity int) *Cache {
+	return &Cache{name: name, capacity: capacity, entries: make(map[string]*list.Element), order: list.New()}
+}
+
+func (cache *Cache) Get(key string) (interface{}, bool) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	element, isPresent := cache.entries[key]
+	if !isPresent {
+		cache.stats.Misses++
+		return nil, false
+	}
+	cache.stats.Hits++
+	cache.order.MoveToFront(element)
+	return element.Value.(*entry).value, true
+}
+
+func (cache *Cache) Set(key string, value interface{}) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	cache.set(key, value)
+}
+
+/* Value of key, loaded and stored on a miss. Value loaded while cache was invalidated is returned but not stored,
+** so that stale data loaded before invalidation does not outlive it */
+func (cache *Cache) GetOrLoad(key string, load func() (interface{}, error)) (interface{}, error) {
+	if value, isPresent := cache.Get(key); isPresent {
+		return value, nil
+	}
+	cache.mutex.Lock()
+	generation := cache.generation
+	cache.stats.Loads++
+	cache.mutex.Unlock()
+
+	value, err := load()
+	if err != nil {
+		return nil, err
+	}
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	if cache.generation == generation {
+		cache.set(key, value)
+	}
+	return value, nil
+}
+
+/* Remove keys, all entries when no key is provided */
+func (cache *Cache) Invalidate(keys ...string) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	cache.generation++
+	cache.stats.Invalidations++
+	if len(keys) == 0 {
+		cache.entries = make(map[string]*list.Element)
+		cache.order.Init()
+		return
+	}
+	for _, key := range keys {
+		if element, isPresent := cache.entries[key]; isPresent {
+			cache.order.Remove(element)
+			delete(cache.entries, key)
+		}
+	}
+}
+
+/* Change capacity, evicting least recently used entries beyond it */
+func (cache *Cache) SetCapacity(capacity int) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	cache.capacity = capacity
+	cache.evict()
+}
+
+func (cache *Cache) Stats() Stats {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	stats := cache.stats
+	stats.Name = cache.name
+	stats.Entries = len(cache.entries)
+	stats.Capacity = cache.capacity
+	return stats
+}
+
+/* Caller holds mutex */
+func (cache *Cache) set(key string, value interface{}) {
+	if element, isPresent := cache.entries[key]; isPresent {
+		element.Value.(*entry).value = value
+		cache.order.MoveToFront(element)
+		return
+	}
+	cache.entries[key] = cache.order.PushFront(&entry{key: key, value: value})
+	cache.evict()
+}
+
+/* Caller holds mutex */
+func (cache *Cache) evict() {
+	for cache.capacity > 0 && cache.order.Len() > cache.capacity {
+		element := cache.order.Back()
+		cache.order.Remove(element)
+		delete(cache.entries, element.Value.(*entry).key)
+		cache.stats.Evictions++
+	}
+}
@@ -0,0 +1,199 @@
+package calendar
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+/* Indian Standard Time. Fixed offset as IST has no daylight saving, avoids depending on tzdata */
+var IST = time.FixedZone("IST", 5*60*60+30*60)
+
+/* Normal equity session of NSE and BSE in IST */
+const (
+	SessionOpenHour    = 9
+	SessionOpenMinute  = 15
+	SessionCloseHour   = 15
+	SessionCloseMinute = 30
+)
+
+/* Trading days of an exchange. Weekends and listed holidays are closed.
+** Holidays are known only for years present in the holiday file */
+type Calendar struct {
+	Exchange string
+	holidays map[string]string
+	fromYear int
+	toYear   int
+}
+
+/* Calendars by exchange loaded from holiday files */
+var calendars = map[string]*Calendar{}
+
+func New(exchange string, holidays map[string]string) *Calendar {
+	if holidays == nil {
+		holidays = make(map[string]string)
+	}
+	cal := &Calendar{Exchange: exchange, holidays: holidays}
+	for dateStr := range holidays {
+		holiday, err := time.Parse("2006-01-02", dateStr)
+		if err != nil {
+			continue
+		}
+		if cal.fromYear == 0 || holiday.Year() < cal.fromYear {
+			cal.fromYear = holiday.Year()
+		}
+		if holiday.Year() > cal.toYear {
+			cal.toYear = holiday.Year()
+		}
+	}
+	return cal
+}
+
+/* Load <dir>/<exchange>.csv for each exchange. Missing file leaves only weekends as holidays */
+func LoadHolidays(dir string, exchanges ...string) error {
+	for _, exchange := range exchanges {
+		file, err := os.Open(filepath.Join(dir, exchange+".csv"))
+		if os.IsNotExist(err) {
+			calendars[exchange] = New(exchange, nil)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		holidays, err := ReadHolidays(file)
+		file.Close()
+		if err != nil {
+			return fmt.Errorf("error while reading holidays of %s: %v", exchange, err)
+		}
+		calendars[exchange] = New(exchange, holidays)
+	}
+	return nil
+}
+
+/* Holiday csv with header Date,Description and dates as yyyy-mm-dd */
+func ReadHolidays(reader io.Reader) (map[string]string, error) {
+	holidays := make(map[string]string)
+	records, err := csv.NewReader(reader).ReadAll()
+	if err != nil {
+		return holidays, err
+	}
+	for k, record := range records {
+		if k == 0 {
+			continue
+		}
+		holiday, err := time.Parse("2006-01-02", strings.TrimSpace(record[0]))
+		if err != nil {
+			return holidays, err
+		}
+		description := ""
+		if len(record) > 1 {
+			description = strings.TrimSpace(record[1])
+		}
+		holidays[holiday.Format("2006-01-02")] = description
+	}
+	return holidays, nil
+}
+
+/* Calendar of exchange. Weekend only calendar when holidays are not loaded */
+func For(exchange string) *Calendar {
+	if cal, isPresent := calendars[exchange]; isPresent {
+		return cal
+	}
+	return New(exchange, nil)
+}
+
+/* -------------------------------------- */
+/* TRADING DAYS */
+
+/* Calendar date of day in IST as UTC midnight. Plain dates (UTC midnight) keep their date as IST is ahead of UTC */
+func dateOf(day time.Time) time.Time {
+	day = day.In(IST)
+	return time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func (cal *Calendar) IsHoliday(day time.Time) bool {
+	_, isPresent := cal.holidays[dateOf(day).Format("2006-01-02")]
+	return isPresent
+}
+
+/* Is day a trading day, and whether that is known. Outside covered years only weekends are
+** treated as closed and known is false */
+func (cal *Calendar) IsTradingDay(day time.Time) (bool, bool) {
+	return cal.isOpen(day), cal.Covers(day)
+}
+
+/* Is day within years of holiday file */
+func (cal *Calendar) Covers(day time.Time) bool {
+	year := dateOf(day).Year()
+	return cal.fromYear > 0 && year >= cal.fromYear && year <= cal.toYear
+}
+
+/* First and last day of covered years as dates at UTC midnight, false when no holidays are loaded */
+func (cal *Calendar) CoveredRange() (time.Time, time.Time, bool) {
+	if cal.fromYear == 0 {
+		return time.Time{}, time.Time{}, false
+	}
+	return time.Date(cal.fromYear, time.January, 1, 0, 0, 0, 0, time.UTC), time.Date(cal.toYear, time.December, 31, 0, 0, 0, 0, time.UTC), true
+}
+
+/* Weekday which is not a listed holiday */
+func (cal *Calendar) isOpen(day time.Time) bool {
+	date := dateOf(day)
+	return date.Weekday() != time.Saturday && date.Weekday() != time.Sunday && !cal.IsHoliday(date)
+}
+
+/* Trading day strictly before day, as date at UTC midnight */
+func (cal *Calendar) PreviousTradingDay(day time.Time) time.Time {
+	date := dateOf(day).AddDate(0, 0, -1)
+	for !cal.isOpen(date) {
+		date = date.AddDate(0, 0, -1)
+	}
+	return date
+}
+
+/* Trading day strictly after day, as date at UTC midnight */
+func (cal *Calendar) NextTradingDay(day time.Time) time.Time {
+	date := dateOf(day).AddDate(0, 0, 1)
+	for !cal.isOpen(date) {
+		date = date.AddDate(0, 0, 1)
+	}
+	return date
+}
+
+/* Day itself when it is a trading day, else previous trading day */
+func (cal *Calendar) LatestTradingDay(day time.Time) time.Time {
+	date := dateOf(day)
+	if cal.isOpen(date) {
+		return date
+	}
+	return cal.PreviousTradingDay(date)
+}
+
+/* -------------------------------------- */
+/* SESSION */
+
+/* Session open and close of day in IST */
+func (cal *Calendar) Session(day time.Time) (time.Time, time.Time) {
+	date := dateOf(day)
+	open := time.Date(date.Year(), date.Month(), date.Day(), SessionOpenHour, SessionOpenMinute, 0, 0, IST)
+	close := time.Date(date.Year(), date.Month(), date.Day(), SessionCloseHour, SessionCloseMinute, 0, 0, IST)
+	return open, close
+}
+
+/* Is market open at instant t */
+func (cal *Calendar) IsMarketOpen(t time.Time) bool {
+	return cal.InSession(t, 0)
+}
+
+/* Is t within session of a trading day, extended by afterClose to pick up closing prices */
+func (cal *Calendar) InSession(t time.Time, afterClose time.Duration) bool {
+	if !cal.isOpen(t.In(IST)) {
+		return false
+	}
+	open, close := cal.Session(t.In(IST))
+	return !t.Before(open) && !t.After(close.Add(afterClose))
+}
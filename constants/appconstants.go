@@ -12,12 +12,23 @@ const (
 	/* Return constants */
 	ReturnBaseValue = 10
 
+	/* Time weighted return periods */
+	TwrPeriodMTD       = "MTD"
+	TwrPeriodQTD       = "QTD"
+	TwrPeriodYTD       = "YTD"
+	TwrPeriod1Y        = "1Y"
+	TwrPeriod3Y        = "3Y"
+	TwrPeriod5Y        = "5Y"
+	TwrPeriodInception = "INCEPTION"
+
 	/* Env/Config Constants */
 	AppEnvName string = "app"
 	AppEnvType string = "env"
 	AppEnvPath string = "."
 
-	/* EndPoint/Route Paths */
+	/* EndPoint/Route Paths. v2 serves the same routes under AppRouteV2Prefix with typed JSON */
+	AppRouteV1Prefix                string = "/PortfolioApis"
+	AppRouteV2Prefix                string = "/PortfolioApis/v2"
 	AppRouteRegister                string = "/PortfolioApis/register"
 	AppRouteLogin                   string = "/PortfolioApis/login"
 	AppRouteUpdatePrices            string = "/PortfolioApis/updateprices"
@@ -35,28 +46,127 @@ const (
 	AppRouteCalculateIndexSIPReturn string = "/PortfolioApis/calculateindexsipreturn"
 	AppRouteCalculateATHforPF       string = "/PortfolioApis/calculateathforpf"
 	AppRouteCalculateXirrReturn     string = "/PortfolioApis/calculatexirrreturn"
+	AppRouteGetUserTransactions     string = "/PortfolioApis/getusertransactions"
+	AppRouteAddPortfolio            string = "/PortfolioApis/addportfolio"
+	AppRouteUpdatePortfolio         string = "/PortfolioApis/updateportfolio"
+	AppRouteGetPortfolios           string = "/PortfolioApis/getportfolios"
+	AppRouteSharePortfolio          string = "/PortfolioApis/shareportfolio"
+	AppRouteRevokePortfolioShare    string = "/PortfolioApis/revokeportfolioshare"
+	AppRouteGetPortfolioShares      string = "/PortfolioApis/getportfolioshares"
+	AppRouteHouseholdNetworth       string = "/PortfolioApis/householdnetworth"
+	AppRouteAddInstruments          string = "/PortfolioApis/addinstruments"
+	AppRouteGetInstruments          string = "/PortfolioApis/getinstruments"
+	AppRouteAddCorporateActions     string = "/PortfolioApis/addcorporateactions"
+	AppRouteGetCorporateActions     string = "/PortfolioApis/getcorporateactions"
+	AppRouteDataQualityReport       string = "/PortfolioApis/dataqualityreport"
+	AppRouteBackfillGaps            string = "/PortfolioApis/backfillgaps"
+	AppRouteGetIngestionJob         string = "/PortfolioApis/getingestionjob"
+	AppRouteGetIngestionJobs        string = "/PortfolioApis/getingestionjobs"
+	AppRouteUploadUniverse          string = "/PortfolioApis/uploaduniverse"
+	AppRouteGetIndexMembers         string = "/PortfolioApis/getindexmembers"
+	AppRouteSearchCompanies         string = "/PortfolioApis/searchcompanies"
+	AppRouteGetCacheStats           string = "/PortfolioApis/getcachestats"
+	AppRouteCalculateNavReturn      string = "/PortfolioApis/calculatenavreturn"
+	AppRouteCalculateTwrReturn      string = "/PortfolioApis/calculatetwrreturn"
 
 	/* Auth/JWT */
 	AppJWTAudience = "ApiUsers"
 	AppJWTIssuer   = "PortfolioApisApp"
 
 	/* AppFile */
-	AppDataMasterUrl        = "https://www1.nseindia.com/content/indices/ind_nifty500list.csv"
-	AppDataMasterFile       = "TOP500.csv"
-	AppDataPricesFileSuffix = ".NS.csv"
-	AppDataPricesUrl        = "https://query1.finance.yahoo.com/v7/finance/download/"
-	AppDataPricesUrlSuffix  = ".NS?period1=%s&period2=%s&interval=1d&events=history&includeAdjustedClose=true"
-
-	/* Below 4 constants added to support Index data from Yahoo finance*/
-	AppDataCsv                      = ".csv"
-	AppDataBenchmarkNSE             = "NSEI"
-	AppDataBenchmarkBSE             = "BSE-"
-	AppDataBenchmarkPricesUrlSuffix = "?period1=%s&period2=%s&interval=1d&events=history&includeAdjustedClose=true"
-	AppDataBenchmarkAppenderText    = "^"
-
-	AppDataPrefixMF           = "0P00"
-	AppDataPricesFileSuffixMF = ".BO.csv"
-	AppDataPricesUrlSuffixMF  = ".BO?period1=%s&period2=%s&interval=1d&events=history&includeAdjustedClose=true"
+	AppDataIndicesUrl = "https://www1.nseindia.com/content/indices/"
+	AppDataMasterFile = "TOP500.csv"
+	AppDataPricesUrl  = "https://query1.finance.yahoo.com/v7/finance/download/"
+	AppDataCsv        = ".csv"
+
+	/* Yahoo Finance ticker rules when instrument has no provider symbol.
+	** NSE stocks end with .NS, BSE listed instruments with .BO and NSE indices start with ^ */
+	AppDataPricesUrlQuery        = "?period1=%s&period2=%s&interval=1d&events=history&includeAdjustedClose=true"
+	AppDataEventsUrlQuery        = "?period1=%s&period2=%s&interval=1d&events=%s&includeAdjustedClose=true"
+	AppDataEventsDividend        = "div"
+	AppDataEventsSplit           = "split"
+	AppDataSymbolSuffixNSE       = ".NS"
+	AppDataSymbolSuffixBSE       = ".BO"
+	AppDataBenchmarkAppenderText = "^"
+
+	/* Background jobs and their schedules when not configured in APP_JOB_SCHEDULES. off disables a job.
+	** Alerts job is to be added along with alert rules */
+	AppJobPriceRefresh              = "pricerefresh"
+	AppJobMasterListRefresh         = "masterlist"
+	AppJobCacheWarmup               = "cachewarmup"
+	AppJobSnapshots                 = "snapshots"
+	AppJobPriceRefreshSchedule      = "@hourly"
+	AppJobMasterListRefreshSchedule = "off"
+	AppJobCacheWarmupSchedule       = "off"
+	AppJobSnapshotsSchedule         = "off"
+
+	/* Ingestion jobs listed when limit is not provided, and most that can be listed */
+	AppIngestionJobsLimit    = 20
+	AppIngestionJobsMaxLimit = 100
+
+	/* Seconds between heartbeats of a queued or running ingestion job, and after which a job without one is failed at startup */
+	AppIngestionJobHeartbeatSecs = 60
+	AppIngestionJobStaleSecs     = 300
+
+	/* First date of price history fetched for new instruments and on full reload */
+	AppPriceHistoryStartYear = 1996
+
+	/* Price rows written per insert when APP_INGEST_BATCH_SIZE is not configured */
+	AppIngestBatchSize = 500
+
+	/* Provider download settings when not configured. Yahoo Finance blocks more than 5 hits per second */
+	AppProviderRatePerSec          = 2
+	AppProviderBurst               = 2
+	AppProviderConcurrency         = 4
+	AppProviderMaxRetries          = 3
+	AppProviderBreakerThreshold    = 5
+	AppProviderBreakerCooldownSecs = 60
+
+	/* Close change % flagged as suspicious when APP_DQ_JUMP_PCT is not configured, and days within which gaps are refetched */
+	AppDQJumpPct      = 20
+	AppDQBackfillDays = 30
+
+	/* Holiday files directory when APP_HOLIDAY_DIR is not configured and
+	** minutes after session close till which hourly price update runs to pick up closing prices */
+	AppHolidayDir                = "holidays/"
+	AppPriceUpdateAfterCloseMins = 90
+
+	/* Universe loaded into master list when APP_UNIVERSES is not configured */
+	AppDefaultUniverse = "NIFTY500"
+
+	/* Companies whose full price history is cached when APP_PRICE_CACHE_SIZE is not configured */
+	AppPriceCacheSize = 500
+
+	/* Company search page size when not provided or above max, and query word length from which one typo
+	** is tolerated (two typos from twice the length) */
+	AppSearchPageSize    = 20
+	AppSearchMaxPageSize = 100
+	AppSearchFuzzyMinLen = 4
+
+	/* Benchmark index when APP_BENCHMARK is not configured */
+	AppDefaultBenchmark = "BSE-500"
+
+	/* Price/Master list providers selectable via config */
+	AppProviderYahoo = "yahoo"
+	AppProviderNSE   = "nse"
+	AppProviderLocal = "local"
+	AppProviderAMFI  = "amfi"
+
+	/* AMFI mutual fund NAVs. History report covers window days per request and starts from April of start year */
+	AppAmfiNavUrl            = "https://www.amfiindia.com/spages/NAVAll.txt"
+	AppAmfiHistoryUrl        = "https://portal.amfiindia.com/DownloadNAVHistoryReport_Po.aspx"
+	AppAmfiHistoryUrlQuery   = "?frmdt=%s&todt=%s"
+	AppAmfiNavFile           = "NAVAll.txt"
+	AppAmfiHistoryFile       = "NAVHistory.txt"
+	AppAmfiHistoryWindowDays = 90
+	AppAmfiHistoryStartYear  = 2006
+
+	/* Stand-in server paths mimicking Yahoo and NSE */
+	AppStandInPricesPath  = "/v7/finance/download/"
+	AppStandInMasterPath  = "/content/indices/ind_nifty500list.csv"
+	AppStandInIndicesPath = "/content/indices/"
+	AppStandInNavPath     = "/spages/NAVAll.txt"
+	AppStandInHistoryPath = "/DownloadNAVHistoryReport_Po.aspx"
 
 	/* Error Codes */
 	AppErrUserUnauthorized  = "E100: User is Unauthorized!!. Please check Token value."
@@ -86,16 +196,67 @@ const (
 	AppErrGetModelPfSync     = "E208: Error while syncing Model Portfolio"
 	AppErrFetchNWOverPeriods = "E209: Error while calculating Networth over periods"
 
-	AppErrUpdateSelectedCompaniesPrice     = "E210: Error while Updating Prices for selected companies"
-	AppSuccessUpdateSelectedCompaniesPrice = "Prices updated successfully for selected companies !!"
+	AppErrUpdateSelectedCompaniesPrice = "E210: Error while Updating Prices for selected companies"
 
 	AppErrFetchAllCompanies     = "E211: Error while fetching all companies"
 	AppSuccessFetchAllCompanies = "Fetched all companies !!"
 
-	AppErrCalculateReturn     = "E212: Error while calculating return"
-	AppSuccessCalculateReturn = "Calculated Return Successfuly !!"
+	AppErrCalculateReturn = "E212: Error while calculating return"
 
 	AppErrCalculateATHforPF = "E213: Error while calculating ATH for PF"
 
 	AppErrCalculateXirrReturn = "E214: Error while calculating Xirr Return for PF"
+
+	AppErrInvalidTransaction  = "E215: Invalid transaction provided. Please check type, quantity, price, date and sell quantity"
+	AppErrGetUserTransactions = "E216: Error while fetching User Transactions"
+
+	AppErrInvalidPortfolio    = "E217: Invalid PortfolioId provided"
+	AppErrAddPortfolio        = "E218: Error while adding Portfolio. Please provide a unique portfolio name"
+	AppSuccessAddPortfolio    = "Portfolio Added successfully!!"
+	AppErrUpdatePortfolio     = "E219: Error while updating Portfolio"
+	AppSuccessUpdatePortfolio = "Portfolio Updated successfully!!"
+	AppErrGetPortfolios       = "E220: Error while fetching Portfolios"
+
+	AppErrInvalidPermission        = "E221: Invalid permission provided. Allowed values are READ and WRITE"
+	AppErrInvalidGrantee           = "E222: Invalid grantee UserId provided"
+	AppErrSharePortfolio           = "E223: Error while updating Portfolio sharing"
+	AppSuccessSharePortfolio       = "Portfolio Shared successfully!!"
+	AppSuccessRevokePortfolioShare = "Portfolio Share revoked successfully!!"
+	AppErrGetPortfolioShares       = "E224: Error while fetching Portfolio shares"
+	AppErrHouseholdNetworth        = "E225: Error while calculating Household Networth"
+
+	AppErrInvalidPayload = "E226: Invalid payload. Please check number and date (yyyy-mm-dd) fields"
+
+	AppErrInvalidInstrument  = "E227: Invalid instrument provided. Please check companyId, asset class, currency and provider"
+	AppErrAddInstruments     = "E228: Error while adding Instruments"
+	AppSuccessAddInstruments = "Instruments Added successfully!!"
+	AppErrGetInstruments     = "E229: Error while fetching Instruments"
+
+	AppErrInvalidCorporateAction  = "E230: Invalid corporate action provided. Please check companyId, type, exDate, ratio and amount"
+	AppErrAddCorporateActions     = "E231: Error while adding Corporate actions"
+	AppSuccessAddCorporateActions = "Corporate actions Added successfully!!"
+	AppErrGetCorporateActions     = "E232: Error while fetching Corporate actions"
+
+	AppErrDataQualityReport = "E233: Error while preparing Data quality report"
+	AppErrBackfillGaps      = "E234: Error while backfilling price gaps"
+	AppSuccessBackfillGaps  = "Price gaps backfilled successfully!!"
+
+	AppErrGetIngestionJob  = "E235: Error while fetching Ingestion job. Please check jobId"
+	AppErrGetIngestionJobs = "E236: Error while fetching Ingestion jobs"
+
+	AppErrWarmupCaches = "E237: Error while loading caches"
+
+	AppErrInvalidUniverse    = "E238: Invalid universe provided. Please check indexid and csv with symbol and company name columns"
+	AppErrUploadUniverse     = "E239: Error while loading Universe"
+	AppSuccessUploadUniverse = "Universe loaded successfully!!"
+	AppErrGetIndexMembers    = "E240: Error while fetching Index members. Please check indexid and date (yyyy-mm-dd)"
+
+	AppErrSearchCompanies = "E241: Error while searching companies"
+
+	AppErrRefreshSnapshots = "E242: Error while refreshing portfolio snapshots"
+
+	AppErrCalculateNavReturn = "E243: Error while calculating NAV Return for PF"
+	AppErrCalculateTwrReturn = "E244: Error while calculating Time weighted Return for PF"
+
+	AppErrGrantNotFound = "E245: Portfolio is not shared with grantee UserId provided"
 )
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"io/ioutil"
 	"net/http"
+	"strings"
 
 	"github.com/vijayyogesh/PortfolioApis/auth"
 	"github.com/vijayyogesh/PortfolioApis/constants"
@@ -16,6 +17,26 @@ type AppController struct {
 	AppUtil *util.AppUtil
 }
 
+/* Routes which can be served on a portfolio shared by its owner with READ access */
+var sharedReadRoutes = map[string]bool{
+	constants.AppRouteGetUserHoldings:     true,
+	constants.AppRouteGetUserTransactions: true,
+	constants.AppRouteGetModelPf:          true,
+	constants.AppRouteSyncPf:              true,
+	constants.AppRouteNWPeriod:            true,
+	constants.AppRouteCalculateATHforPF:   true,
+	constants.AppRouteCalculateXirrReturn: true,
+	constants.AppRouteCalculateReturn:     true,
+	constants.AppRouteCalculateNavReturn:  true,
+	constants.AppRouteCalculateTwrReturn:  true,
+}
+
+/* Routes which can be served on a portfolio shared by its owner with WRITE access */
+var sharedWriteRoutes = map[string]bool{
+	constants.AppRouteAddUserHoldings: true,
+	constants.AppRouteAddModelPf:      true,
+}
+
 func NewAppController(apputil *util.AppUtil) *AppController {
 	return &AppController{
 		AppUtil: apputil,
@@ -35,10 +56,11 @@ func (appC AppController) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		/* Check UserId in Payload */
 		user, err := getUser(reqBody, appC)
 		userId := user.UserId
+		route, _ := parseRoute(r.URL.Path)
 
 		if userId != "" && err == nil {
 			/* Handle Register */
-			if (r.URL.Path == constants.AppRouteRegister) && (r.Method == http.MethodPost) {
+			if (route == constants.AppRouteRegister) && (r.Method == http.MethodPost) {
 				if user.Password != "" {
 					appC.AppUtil.AppLogger.Println("Registering New User")
 					shaPasswd := auth.GenerateSHA(user.Password)
@@ -48,7 +70,7 @@ func (appC AppController) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 				} else {
 					json.NewEncoder(w).Encode(constants.AppErrInvalidPassword)
 				}
-			} else if (r.URL.Path == constants.AppRouteLogin) && (r.Method == http.MethodPost) {
+			} else if (route == constants.AppRouteLogin) && (r.Method == http.MethodPost) {
 				/* Handle Login */
 
 				/* Validate password */
@@ -77,8 +99,9 @@ func (appC AppController) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 				}
 
 			} else {
-				/* Authenticate Token when already logged In */
-				if auth.AuthenticateToken(r, userId) {
+				/* Authenticate Token when already logged In and authorize access to payload user/portfolio */
+				tokenUserId, isValidToken := auth.AuthenticateToken(r)
+				if isValidToken && isAuthorized(route, tokenUserId, user) {
 					ProcessAppRequests(w, r, appC, reqBody)
 				} else {
 					json.NewEncoder(w).Encode(constants.AppErrUserUnauthorized)
@@ -94,6 +117,29 @@ func (appC AppController) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	appC.AppUtil.AppLogger.Println("Completed ServeHTTP")
 }
 
+/* Token user can access own data on all routes. Data of another user is allowed
+** only on shared routes and only when the portfolio has been granted to token user */
+func isAuthorized(route string, tokenUserId string, user data.User) bool {
+	if tokenUserId == user.UserId {
+		return true
+	}
+	if sharedWriteRoutes[route] {
+		return processor.AuthorizePortfolioAccess(tokenUserId, user.UserId, user.PortfolioId, true)
+	}
+	if sharedReadRoutes[route] {
+		return processor.AuthorizePortfolioAccess(tokenUserId, user.UserId, user.PortfolioId, false)
+	}
+	return false
+}
+
+/* Route in v1 form and whether the request is for the v2 API */
+func parseRoute(path string) (string, bool) {
+	if strings.HasPrefix(path, constants.AppRouteV2Prefix+"/") {
+		return constants.AppRouteV1Prefix + strings.TrimPrefix(path, constants.AppRouteV2Prefix), true
+	}
+	return path, false
+}
+
 /* Get User from request Payload */
 func getUser(reqBody []byte, appC AppController) (data.User, error) {
 	var user data.User
@@ -110,30 +156,41 @@ func ProcessAppRequests(w http.ResponseWriter, r *http.Request, appC AppControll
 
 	processor.InitProcessor(appC.AppUtil)
 
+	/* Routes with typed payloads in v2. Others are same in both versions */
+	route, isV2 := parseRoute(r.URL.Path)
+	if isV2 && processAppRequestsV2(w, r, route, payload) {
+		return
+	}
+
 	/* Commented as updatePrices is taken care by Cron Job */
-	/*if (r.URL.Path == constants.AppRouteUpdatePrices) && (r.Method == http.MethodPost) {
+	/*if (route == constants.AppRouteUpdatePrices) && (r.Method == http.MethodPost) {
 		msg := processor.FetchAndUpdatePrices(appC.AppUtil.Db)
 		json.NewEncoder(w).Encode(msg)
 	} */
 
-	if (r.URL.Path == constants.AppRouteUpdateSelectedCompanies) && (r.Method == http.MethodPost) {
-		msg := processor.UpdateSelectedCompanies(payload)
-		json.NewEncoder(w).Encode(msg)
-	} else if (r.URL.Path == constants.AppRouteUpdateMasterList) && (r.Method == http.MethodPost) {
+	if (route == constants.AppRouteUpdateSelectedCompanies) && (r.Method == http.MethodPost) {
+		/* Route to queue price update of companies, returns job to poll */
+		resp, err := processor.UpdateSelectedCompanies(payload)
+		if err != nil {
+			json.NewEncoder(w).Encode(constants.AppErrUpdateSelectedCompaniesPrice)
+		} else {
+			json.NewEncoder(w).Encode(resp)
+		}
+	} else if (route == constants.AppRouteUpdateMasterList) && (r.Method == http.MethodPost) {
 		/* Route to update/refresh master list of companies */
 		msg := processor.FetchAndUpdateCompaniesMasterList()
 		json.NewEncoder(w).Encode(msg)
-	} else if (r.URL.Path == constants.AppRouteAddUser) && (r.Method == http.MethodPost) {
+	} else if (route == constants.AppRouteAddUser) && (r.Method == http.MethodPost) {
 		/* Route to add new user into system */
 		var user data.User
 		json.Unmarshal(payload, &user)
 		msg := processor.AddUser(user)
 		json.NewEncoder(w).Encode(msg)
-	} else if (r.URL.Path == constants.AppRouteAddUserHoldings) && (r.Method == http.MethodPost) {
-		/* Route to add user holdings */
+	} else if (route == constants.AppRouteAddUserHoldings) && (r.Method == http.MethodPost) {
+		/* Route to add user holdings/ledger transactions */
 		msg := processor.AddUserHoldings(payload)
 		json.NewEncoder(w).Encode(msg)
-	} else if (r.URL.Path == constants.AppRouteGetUserHoldings) && (r.Method == http.MethodPost) {
+	} else if (route == constants.AppRouteGetUserHoldings) && (r.Method == http.MethodPost) {
 		/* Route to fetch User Holdings */
 		resp, err := processor.GetUserHoldings(payload, true)
 		if err != nil {
@@ -141,11 +198,11 @@ func ProcessAppRequests(w http.ResponseWriter, r *http.Request, appC AppControll
 		} else {
 			json.NewEncoder(w).Encode(resp)
 		}
-	} else if (r.URL.Path == constants.AppRouteAddModelPf) && (r.Method == http.MethodPost) {
+	} else if (route == constants.AppRouteAddModelPf) && (r.Method == http.MethodPost) {
 		/* Route to Add Model Portfolio */
 		msg := processor.AddModelPortfolio(payload)
 		json.NewEncoder(w).Encode(msg)
-	} else if (r.URL.Path == constants.AppRouteGetModelPf) && (r.Method == http.MethodPost) {
+	} else if (route == constants.AppRouteGetModelPf) && (r.Method == http.MethodPost) {
 		/* Route to fetch Model Portfolio */
 		resp, err := processor.GetModelPortfolio(payload)
 		if err != nil {
@@ -153,7 +210,7 @@ func ProcessAppRequests(w http.ResponseWriter, r *http.Request, appC AppControll
 		} else {
 			json.NewEncoder(w).Encode(resp)
 		}
-	} else if (r.URL.Path == constants.AppRouteSyncPf) && (r.Method == http.MethodPost) {
+	} else if (route == constants.AppRouteSyncPf) && (r.Method == http.MethodPost) {
 		/* Route to sync Model Pf with actual Pf */
 		resp, err := processor.GetPortfolioModelSync(payload)
 		if err != nil {
@@ -161,7 +218,7 @@ func ProcessAppRequests(w http.ResponseWriter, r *http.Request, appC AppControll
 		} else {
 			json.NewEncoder(w).Encode(resp)
 		}
-	} else if (r.URL.Path == constants.AppRouteNWPeriod) && (r.Method == http.MethodPost) {
+	} else if (route == constants.AppRouteNWPeriod) && (r.Method == http.MethodPost) {
 		/* Route to display NetWorth over a timeframe */
 		resp, err := processor.FetchNetWorthOverPeriods(payload)
 		if err != nil {
@@ -169,7 +226,7 @@ func ProcessAppRequests(w http.ResponseWriter, r *http.Request, appC AppControll
 		} else {
 			json.NewEncoder(w).Encode(resp)
 		}
-	} else if (r.URL.Path == constants.AppRouteFetchAllCompanies) && (r.Method == http.MethodPost) {
+	} else if (route == constants.AppRouteFetchAllCompanies) && (r.Method == http.MethodPost) {
 		/* Route to Fetch All Companies */
 		resp, err := processor.FetchAllCompanies(payload)
 		if err != nil {
@@ -177,7 +234,7 @@ func ProcessAppRequests(w http.ResponseWriter, r *http.Request, appC AppControll
 		} else {
 			json.NewEncoder(w).Encode(resp)
 		}
-	} else if (r.URL.Path == constants.AppRouteCalculateReturn) && (r.Method == http.MethodPost) {
+	} else if (route == constants.AppRouteCalculateReturn) && (r.Method == http.MethodPost) {
 		/* Route to calculate Return */
 		resp, err := processor.CalculateReturn(payload)
 		if err != nil {
@@ -185,7 +242,7 @@ func ProcessAppRequests(w http.ResponseWriter, r *http.Request, appC AppControll
 		} else {
 			json.NewEncoder(w).Encode(resp)
 		}
-	} else if (r.URL.Path == constants.AppRouteCalculateIndexSIPReturn) && (r.Method == http.MethodPost) {
+	} else if (route == constants.AppRouteCalculateIndexSIPReturn) && (r.Method == http.MethodPost) {
 		/* Route to calculate SIP Index */
 		resp, err := processor.CalculateIndexSIPReturn(payload)
 		if err != nil {
@@ -193,7 +250,7 @@ func ProcessAppRequests(w http.ResponseWriter, r *http.Request, appC AppControll
 		} else {
 			json.NewEncoder(w).Encode(resp)
 		}
-	} else if (r.URL.Path == constants.AppRouteCalculateATHforPF) && (r.Method == http.MethodPost) {
+	} else if (route == constants.AppRouteCalculateATHforPF) && (r.Method == http.MethodPost) {
 		/* Route to calculate ATH for PF */
 		resp, err := processor.CalculateATHforPF(payload)
 		if err != nil {
@@ -201,7 +258,7 @@ func ProcessAppRequests(w http.ResponseWriter, r *http.Request, appC AppControll
 		} else {
 			json.NewEncoder(w).Encode(resp)
 		}
-	} else if (r.URL.Path == constants.AppRouteCalculateXirrReturn) && (r.Method == http.MethodPost) {
+	} else if (route == constants.AppRouteCalculateXirrReturn) && (r.Method == http.MethodPost) {
 		/* Route to calculate Returns for PF */
 		resp, err := processor.CalculateXirrReturn(payload)
 		if err != nil {
@@ -209,6 +266,170 @@ func ProcessAppRequests(w http.ResponseWriter, r *http.Request, appC AppControll
 		} else {
 			json.NewEncoder(w).Encode(resp)
 		}
+	} else if (route == constants.AppRouteCalculateNavReturn) && (r.Method == http.MethodPost) {
+		/* Route to calculate unitized NAV of PF */
+		resp, err := processor.CalculateNavReturn(payload)
+		if err != nil {
+			json.NewEncoder(w).Encode(constants.AppErrCalculateNavReturn)
+		} else {
+			json.NewEncoder(w).Encode(resp)
+		}
+	} else if (route == constants.AppRouteCalculateTwrReturn) && (r.Method == http.MethodPost) {
+		/* Route to calculate time weighted Returns for PF and benchmark */
+		resp, err := processor.CalculateTwrReturn(payload)
+		if err != nil {
+			json.NewEncoder(w).Encode(constants.AppErrCalculateTwrReturn)
+		} else {
+			json.NewEncoder(w).Encode(resp)
+		}
+	} else if (route == constants.AppRouteGetUserTransactions) && (r.Method == http.MethodPost) {
+		/* Route to fetch User Transactions ledger */
+		resp, err := processor.GetUserTransactions(payload)
+		if err != nil {
+			json.NewEncoder(w).Encode(constants.AppErrGetUserTransactions)
+		} else {
+			json.NewEncoder(w).Encode(resp)
+		}
+	} else if (route == constants.AppRouteAddPortfolio) && (r.Method == http.MethodPost) {
+		/* Route to add a named portfolio */
+		msg := processor.AddPortfolio(payload)
+		json.NewEncoder(w).Encode(msg)
+	} else if (route == constants.AppRouteUpdatePortfolio) && (r.Method == http.MethodPost) {
+		/* Route to update portfolio name/target amount */
+		msg := processor.UpdatePortfolio(payload)
+		json.NewEncoder(w).Encode(msg)
+	} else if (route == constants.AppRouteGetPortfolios) && (r.Method == http.MethodPost) {
+		/* Route to list portfolios of user */
+		resp, err := processor.GetPortfolios(payload)
+		if err != nil {
+			json.NewEncoder(w).Encode(constants.AppErrGetPortfolios)
+		} else {
+			json.NewEncoder(w).Encode(resp)
+		}
+	} else if (route == constants.AppRouteSharePortfolio) && (r.Method == http.MethodPost) {
+		/* Route to share a portfolio with another user */
+		msg := processor.SharePortfolio(payload)
+		json.NewEncoder(w).Encode(msg)
+	} else if (route == constants.AppRouteRevokePortfolioShare) && (r.Method == http.MethodPost) {
+		/* Route to revoke a portfolio share */
+		msg := processor.RevokePortfolioShare(payload)
+		json.NewEncoder(w).Encode(msg)
+	} else if (route == constants.AppRouteGetPortfolioShares) && (r.Method == http.MethodPost) {
+		/* Route to list portfolio shares of user */
+		resp, err := processor.GetPortfolioShares(payload)
+		if err != nil {
+			json.NewEncoder(w).Encode(constants.AppErrGetPortfolioShares)
+		} else {
+			json.NewEncoder(w).Encode(resp)
+		}
+	} else if (route == constants.AppRouteHouseholdNetworth) && (r.Method == http.MethodPost) {
+		/* Route to display consolidated networth of own and shared portfolios */
+		resp, err := processor.GetHouseholdNetworth(payload)
+		if err != nil {
+			json.NewEncoder(w).Encode(constants.AppErrHouseholdNetworth)
+		} else {
+			json.NewEncoder(w).Encode(resp)
+		}
+	} else if (route == constants.AppRouteAddInstruments) && (r.Method == http.MethodPost) {
+		/* Admin route to add/edit instrument metadata. Payload user is already verified against token */
+		user, err := getUser(payload, appC)
+		if err != nil || !processor.IsAdminUser(user.UserId) {
+			json.NewEncoder(w).Encode(constants.AppErrUserUnauthorized)
+		} else {
+			msg := processor.AddInstruments(payload)
+			json.NewEncoder(w).Encode(msg)
+		}
+	} else if (route == constants.AppRouteGetInstruments) && (r.Method == http.MethodPost) {
+		/* Route to fetch instrument metadata */
+		resp, err := processor.GetInstruments(payload)
+		if err != nil {
+			json.NewEncoder(w).Encode(constants.AppErrGetInstruments)
+		} else {
+			json.NewEncoder(w).Encode(resp)
+		}
+	} else if (route == constants.AppRouteAddCorporateActions) && (r.Method == http.MethodPost) {
+		/* Admin route to add/edit splits, bonus issues and dividends */
+		user, err := getUser(payload, appC)
+		if err != nil || !processor.IsAdminUser(user.UserId) {
+			json.NewEncoder(w).Encode(constants.AppErrUserUnauthorized)
+		} else {
+			msg := processor.AddCorporateActions(payload)
+			json.NewEncoder(w).Encode(msg)
+		}
+	} else if (route == constants.AppRouteGetCorporateActions) && (r.Method == http.MethodPost) {
+		/* Route to fetch corporate actions of a company or all companies */
+		resp, err := processor.GetCorporateActions(payload)
+		if err != nil {
+			json.NewEncoder(w).Encode(constants.AppErrGetCorporateActions)
+		} else {
+			json.NewEncoder(w).Encode(resp)
+		}
+	} else if (route == constants.AppRouteDataQualityReport) && (r.Method == http.MethodPost) {
+		/* Route to report gaps and suspicious jumps in price history */
+		resp, err := processor.GetDataQualityReport(payload)
+		if err != nil {
+			json.NewEncoder(w).Encode(constants.AppErrDataQualityReport)
+		} else {
+			json.NewEncoder(w).Encode(resp)
+		}
+	} else if (route == constants.AppRouteBackfillGaps) && (r.Method == http.MethodPost) {
+		/* Admin route to refetch recent gaps in price history */
+		user, err := getUser(payload, appC)
+		if err != nil || !processor.IsAdminUser(user.UserId) {
+			json.NewEncoder(w).Encode(constants.AppErrUserUnauthorized)
+		} else {
+			msg := processor.BackfillPriceGaps(payload)
+			json.NewEncoder(w).Encode(msg)
+		}
+	} else if (route == constants.AppRouteGetIngestionJob) && (r.Method == http.MethodPost) {
+		/* Route to poll status of a price ingestion job */
+		resp, err := processor.GetIngestionJob(payload)
+		if err != nil {
+			json.NewEncoder(w).Encode(constants.AppErrGetIngestionJob)
+		} else {
+			json.NewEncoder(w).Encode(resp)
+		}
+	} else if (route == constants.AppRouteGetIngestionJobs) && (r.Method == http.MethodPost) {
+		/* Route to list recent price ingestion jobs */
+		resp, err := processor.GetIngestionJobs(payload)
+		if err != nil {
+			json.NewEncoder(w).Encode(constants.AppErrGetIngestionJobs)
+		} else {
+			json.NewEncoder(w).Encode(resp)
+		}
+	} else if (route == constants.AppRouteUploadUniverse) && (r.Method == http.MethodPost) {
+		/* Admin route to load a custom universe from constituents csv */
+		user, err := getUser(payload, appC)
+		if err != nil || !processor.IsAdminUser(user.UserId) {
+			json.NewEncoder(w).Encode(constants.AppErrUserUnauthorized)
+		} else {
+			msg := processor.UploadUniverse(payload)
+			json.NewEncoder(w).Encode(msg)
+		}
+	} else if (route == constants.AppRouteGetIndexMembers) && (r.Method == http.MethodPost) {
+		/* Route to fetch members of an index as of a date */
+		resp, err := processor.GetIndexMembers(payload)
+		if err != nil {
+			json.NewEncoder(w).Encode(constants.AppErrGetIndexMembers)
+		} else {
+			json.NewEncoder(w).Encode(resp)
+		}
+	} else if (route == constants.AppRouteSearchCompanies) && (r.Method == http.MethodPost) {
+		/* Route to search companies by id, name, ISIN or industry for autocomplete */
+		resp, err := processor.SearchCompanies(payload)
+		if err != nil {
+			json.NewEncoder(w).Encode(constants.AppErrSearchCompanies)
+		} else {
+			json.NewEncoder(w).Encode(resp)
+		}
+	} else if (route == constants.AppRouteGetCacheStats) && (r.Method == http.MethodPost) {
+		/* Admin route to view cache entries and hit/miss counts */
+		user, err := getUser(payload, appC)
+		if err != nil || !processor.IsAdminUser(user.UserId) {
+			json.NewEncoder(w).Encode(constants.AppErrUserUnauthorized)
+		} else {
+			json.NewEncoder(w).Encode(processor.GetCacheStats())
+		}
 	}
 
 }
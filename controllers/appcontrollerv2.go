@@ -0,0 +1,95 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/vijayyogesh/PortfolioApis/constants"
+	"github.com/vijayyogesh/PortfolioApis/processor"
+)
+
+/* Process v2 routes having typed numbers/dates. Route is in v1 form.
+** Returns false when route has no v2 specific payload, so v1 handler can serve it */
+func processAppRequestsV2(w http.ResponseWriter, r *http.Request, route string, payload []byte) bool {
+	if r.Method != http.MethodPost {
+		return false
+	}
+
+	if route == constants.AppRouteAddUserHoldings {
+		/* Route to add user holdings/ledger transactions */
+		msg := processor.AddUserHoldingsV2(payload)
+		json.NewEncoder(w).Encode(msg)
+	} else if route == constants.AppRouteGetUserHoldings {
+		/* Route to fetch User Holdings */
+		resp, err := processor.GetUserHoldingsV2(payload)
+		encodeResponseV2(w, resp, err, constants.AppErrGetUserHoldings)
+	} else if route == constants.AppRouteGetUserTransactions {
+		/* Route to fetch User Transactions ledger */
+		resp, err := processor.GetUserTransactionsV2(payload)
+		encodeResponseV2(w, resp, err, constants.AppErrGetUserTransactions)
+	} else if route == constants.AppRouteAddModelPf {
+		/* Route to Add Model Portfolio */
+		msg := processor.AddModelPortfolioV2(payload)
+		json.NewEncoder(w).Encode(msg)
+	} else if route == constants.AppRouteGetModelPf {
+		/* Route to fetch Model Portfolio */
+		resp, err := processor.GetModelPortfolioV2(payload)
+		encodeResponseV2(w, resp, err, constants.AppErrGetModelPf)
+	} else if route == constants.AppRouteSyncPf {
+		/* Route to sync Model Pf with actual Pf */
+		resp, err := processor.GetPortfolioModelSyncV2(payload)
+		encodeResponseV2(w, resp, err, constants.AppErrGetModelPfSync)
+	} else if route == constants.AppRouteNWPeriod {
+		/* Route to display NetWorth over a timeframe */
+		resp, err := processor.FetchNetWorthOverPeriodsV2(payload)
+		encodeResponseV2(w, resp, err, constants.AppErrFetchNWOverPeriods)
+	} else if route == constants.AppRouteCalculateIndexSIPReturn {
+		/* Route to calculate SIP Index */
+		resp, err := processor.CalculateIndexSIPReturnV2(payload)
+		encodeResponseV2(w, resp, err, constants.AppErrCalculateReturn)
+	} else if route == constants.AppRouteCalculateATHforPF {
+		/* Route to calculate ATH for PF */
+		resp, err := processor.CalculateATHforPFV2(payload)
+		encodeResponseV2(w, resp, err, constants.AppErrCalculateATHforPF)
+	} else if route == constants.AppRouteCalculateXirrReturn {
+		/* Route to calculate Returns for PF */
+		resp, err := processor.CalculateXirrReturnV2(payload)
+		encodeResponseV2(w, resp, err, constants.AppErrCalculateXirrReturn)
+	} else if route == constants.AppRouteCalculateNavReturn {
+		/* Route to calculate unitized NAV of PF */
+		resp, err := processor.CalculateNavReturnV2(payload)
+		encodeResponseV2(w, resp, err, constants.AppErrCalculateNavReturn)
+	} else if route == constants.AppRouteCalculateTwrReturn {
+		/* Route to calculate time weighted Returns for PF and benchmark */
+		resp, err := processor.CalculateTwrReturnV2(payload)
+		encodeResponseV2(w, resp, err, constants.AppErrCalculateTwrReturn)
+	} else if route == constants.AppRouteAddPortfolio {
+		/* Route to add a named portfolio */
+		msg := processor.AddPortfolioV2(payload)
+		json.NewEncoder(w).Encode(msg)
+	} else if route == constants.AppRouteUpdatePortfolio {
+		/* Route to update portfolio name/target amount */
+		msg := processor.UpdatePortfolioV2(payload)
+		json.NewEncoder(w).Encode(msg)
+	} else if route == constants.AppRouteGetPortfolios {
+		/* Route to list portfolios of user */
+		resp, err := processor.GetPortfoliosV2(payload)
+		encodeResponseV2(w, resp, err, constants.AppErrGetPortfolios)
+	} else if route == constants.AppRouteHouseholdNetworth {
+		/* Route to display consolidated networth of own and shared portfolios */
+		resp, err := processor.GetHouseholdNetworthV2(payload)
+		encodeResponseV2(w, resp, err, constants.AppErrHouseholdNetworth)
+	} else {
+		return false
+	}
+	return true
+}
+
+/* Error code on failure, typed response otherwise */
+func encodeResponseV2(w http.ResponseWriter, resp interface{}, err error, errCode string) {
+	if err != nil {
+		json.NewEncoder(w).Encode(errCode)
+	} else {
+		json.NewEncoder(w).Encode(resp)
+	}
+}
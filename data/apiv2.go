@@ -0,0 +1,270 @@
+package data
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/vijayyogesh/PortfolioApis/money"
+)
+
+/* v2 JSON contract. Numbers are JSON numbers (money.Decimal) rounded per kind,
+** dates are ISO-8601 (yyyy-mm-dd). Identifiers stay strings as they accept "all". */
+
+const DateFormatISO = "2006-01-02"
+
+/* Calendar date encoded as "yyyy-mm-dd", null when zero */
+type Date struct {
+	time.Time
+}
+
+func NewDate(val time.Time) Date {
+	if val.IsZero() {
+		return Date{}
+	}
+	return Date{time.Date(val.Year(), val.Month(), val.Day(), 0, 0, 0, 0, time.UTC)}
+}
+
+/* Parse ISO date, RFC3339 timestamps from DB and yyyy/mm/dd used by v1 SIP payloads */
+func ParseDate(val string) (Date, error) {
+	str := strings.TrimSpace(val)
+	if str == "" {
+		return Date{}, nil
+	}
+	for _, layout := range []string{DateFormatISO, time.RFC3339, "2006/01/02"} {
+		parsed, err := time.Parse(layout, str)
+		if err == nil {
+			return NewDate(parsed), nil
+		}
+	}
+	return Date{}, fmt.Errorf("invalid date %q, expected yyyy-mm-dd", val)
+}
+
+/* Date in ISO format, empty when zero */
+func (d Date) String() string {
+	if d.IsZero() {
+		return ""
+	}
+	return d.Format(DateFormatISO)
+}
+
+func (d Date) MarshalJSON() ([]byte, error) {
+	if d.IsZero() {
+		return []byte("null"), nil
+	}
+	return []byte("\"" + d.String() + "\""), nil
+}
+
+func (d *Date) UnmarshalJSON(val []byte) error {
+	str := strings.Trim(string(val), "\"")
+	if str == "null" {
+		*d = Date{}
+		return nil
+	}
+	parsed, err := ParseDate(str)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+type HoldingV2 struct {
+	CompanyId    string        `json:"companyId"`
+	CompanyName  string        `json:"companyName"`
+	Quantity     money.Decimal `json:"quantity"`
+	BuyDate      Date          `json:"buyDate"`
+	BuyPrice     money.Decimal `json:"buyPrice"`
+	LTP          money.Decimal `json:"ltp"`
+	CurrentValue money.Decimal `json:"currentValue"`
+	PL           money.Decimal `json:"pl"`
+	NetPct       money.Decimal `json:"netPct"`
+	RealizedPL   money.Decimal `json:"realizedPl"`
+	Dividends    money.Decimal `json:"dividends"`
+	TxnType      string        `json:"txnType,omitempty"`
+}
+
+type HoldingNonTrackedV2 struct {
+	SecurityId   string        `json:"securityId"`
+	BuyDate      Date          `json:"buyDate"`
+	BuyValue     money.Decimal `json:"buyValue"`
+	CurrentValue money.Decimal `json:"currentValue"`
+	InterestRate money.Decimal `json:"interestRate"`
+}
+
+type AllocationV2 struct {
+	Equity money.Decimal `json:"equity"`
+	Debt   money.Decimal `json:"debt"`
+	Gold   money.Decimal `json:"gold"`
+}
+
+type TransactionV2 struct {
+	TransactionId  string        `json:"transactionId,omitempty"`
+	PortfolioId    string        `json:"portfolioId,omitempty"`
+	CompanyId      string        `json:"companyId"`
+	CompanyName    string        `json:"companyName,omitempty"`
+	TxnType        string        `json:"txnType"`
+	Quantity       money.Decimal `json:"quantity"`
+	Price          money.Decimal `json:"price"`
+	Fees           money.Decimal `json:"fees"`
+	Brokerage      money.Decimal `json:"brokerage"`
+	Taxes          money.Decimal `json:"taxes"`
+	TxnDate        Date          `json:"txnDate"`
+	SettlementDate Date          `json:"settlementDate"`
+	Notes          string        `json:"notes,omitempty"`
+}
+
+type HoldingsInputV2 struct {
+	UserID       string                `json:"userId"`
+	PortfolioId  string                `json:"portfolioId"`
+	Holdings     []HoldingV2           `json:"holdings"`
+	HoldingsNT   []HoldingNonTrackedV2 `json:"holdingsNonTracked"`
+	Transactions []TransactionV2       `json:"transactions"`
+}
+
+type HoldingsOutputV2 struct {
+	UserID      string                `json:"userId"`
+	PortfolioId string                `json:"portfolioId"`
+	Holdings    []HoldingV2           `json:"holdings"`
+	HoldingsNT  []HoldingNonTrackedV2 `json:"holdingsNonTracked"`
+	Networth    money.Decimal         `json:"networth"`
+	Allocation  AllocationV2          `json:"allocation"`
+}
+
+type TransactionsOutputV2 struct {
+	UserID       string          `json:"userId"`
+	PortfolioId  string          `json:"portfolioId"`
+	Transactions []TransactionV2 `json:"transactions"`
+}
+
+type SecurityV2 struct {
+	SecurityId         string        `json:"securityId"`
+	ReasonablePrice    money.Decimal `json:"reasonablePrice"`
+	ExpectedAllocation money.Decimal `json:"expectedAllocation"`
+	PortfolioId        string        `json:"portfolioId,omitempty"`
+}
+
+type ModelPortfolioV2 struct {
+	UserID      string       `json:"userId"`
+	PortfolioId string       `json:"portfolioId"`
+	Securities  []SecurityV2 `json:"securities"`
+}
+
+type AdjustedHoldingV2 struct {
+	PortfolioId                 string        `json:"portfolioId"`
+	SecurityId                  string        `json:"securityId"`
+	AdjustedAmount              money.Decimal `json:"adjustedAmount"`
+	BelowReasonablePrice        bool          `json:"belowReasonablePrice"`
+	PercentBelowReasonablePrice money.Decimal `json:"percentBelowReasonablePrice"`
+}
+
+type SyncedPortfolioV2 struct {
+	AdjustedHoldings []AdjustedHoldingV2 `json:"adjustedHoldings"`
+}
+
+type SIPReturnInputParamV2 struct {
+	CompanyId  string        `json:"companyId"`
+	StartDate  Date          `json:"startDate"`
+	EndDate    Date          `json:"endDate"`
+	SIPAmount  money.Decimal `json:"sipAmount"`
+	StepUpPct  money.Decimal `json:"stepUpPct"`
+	ReturnType string        `json:"returnType"`
+}
+
+type SIPReturnInputV2 struct {
+	UserID              string                `json:"userId"`
+	SIPReturnInputParam SIPReturnInputParamV2 `json:"sipParams"`
+}
+
+type SIPReturnSubPeriodV2 struct {
+	Quantity        money.Decimal `json:"quantity"`
+	EndDate         Date          `json:"endDate"`
+	TotalInvestment money.Decimal `json:"totalInvestment"`
+	TotalEndValue   money.Decimal `json:"totalEndValue"`
+	Xirr            money.Decimal `json:"xirr"`
+	BuyVal          money.Decimal `json:"buyVal"`
+}
+
+/* Percentage of sub periods falling in each xirr bracket */
+type SIPReturnBracketV2 struct {
+	LessThanZero   money.Decimal `json:"lessThanZero"`
+	ZeroToTwo      money.Decimal `json:"zeroToTwo"`
+	TwoToFive      money.Decimal `json:"twoToFive"`
+	FiveToSeven    money.Decimal `json:"fiveToSeven"`
+	SevenToTen     money.Decimal `json:"sevenToTen"`
+	GreaterThanTen money.Decimal `json:"greaterThanTen"`
+}
+
+type SIPReturnOutputV2 struct {
+	SIPReturnSubPeriod []SIPReturnSubPeriodV2 `json:"sipReturnSubPeriod"`
+	SIPReturnBracket   SIPReturnBracketV2     `json:"sipReturnBracket"`
+}
+
+type TwrPeriodReturnV2 struct {
+	Period              string        `json:"period"`
+	StartDate           Date          `json:"startDate"`
+	EndDate             Date          `json:"endDate"`
+	PortfolioReturn     money.Decimal `json:"portfolioReturn"`
+	PortfolioAnnualized money.Decimal `json:"portfolioAnnualized"`
+	BenchmarkReturn     money.Decimal `json:"benchmarkReturn"`
+	BenchmarkAnnualized money.Decimal `json:"benchmarkAnnualized"`
+}
+
+type TwrOutputV2 struct {
+	UserId      string              `json:"userId"`
+	PortfolioId string              `json:"portfolioId"`
+	ReturnType  string              `json:"returnType"`
+	BenchmarkId string              `json:"benchmarkId"`
+	Periods     []TwrPeriodReturnV2 `json:"periods"`
+}
+
+/* Point of a date wise series like networth or xirr */
+type SeriesPointV2 struct {
+	Date  Date          `json:"date"`
+	Value money.Decimal `json:"value"`
+}
+
+/* Named series sorted by date */
+type SeriesOutputV2 map[string][]SeriesPointV2
+
+type PortfolioV2 struct {
+	PortfolioId   string        `json:"portfolioId"`
+	UserId        string        `json:"userId"`
+	PortfolioName string        `json:"portfolioName"`
+	TargetAmount  money.Decimal `json:"targetAmount"`
+	CreatedDate   Date          `json:"createdDate"`
+}
+
+/* TargetAmount is nil when not provided, so an update keeps the current target */
+type PortfolioDetailsV2 struct {
+	PortfolioId   string         `json:"portfolioId"`
+	PortfolioName string         `json:"portfolioName"`
+	TargetAmount  *money.Decimal `json:"targetAmount"`
+}
+
+type PortfolioInputV2 struct {
+	UserID    string             `json:"userId"`
+	Portfolio PortfolioDetailsV2 `json:"portfolio"`
+}
+
+type PortfoliosOutputV2 struct {
+	UserID     string        `json:"userId"`
+	Portfolios []PortfolioV2 `json:"portfolios"`
+}
+
+type HouseholdPortfolioV2 struct {
+	PortfolioId   string        `json:"portfolioId"`
+	PortfolioName string        `json:"portfolioName"`
+	OwnerUserId   string        `json:"ownerUserId"`
+	Permission    string        `json:"permission,omitempty"`
+	Networth      money.Decimal `json:"networth"`
+	Allocation    AllocationV2  `json:"allocation"`
+}
+
+type HouseholdOutputV2 struct {
+	UserID     string                 `json:"userId"`
+	Networth   money.Decimal          `json:"networth"`
+	Allocation AllocationV2           `json:"allocation"`
+	Portfolios []HouseholdPortfolioV2 `json:"portfolios"`
+	Holdings   []HoldingV2            `json:"holdings"`
+}
@@ -0,0 +1,100 @@
+package data
+
+import (
+	"database/sql"
+)
+
+/* Corporate action types */
+const (
+	ActionTypeSplit    = "SPLIT"
+	ActionTypeBonus    = "BONUS"
+	ActionTypeDividend = "DIVIDEND"
+)
+
+/* Source of corporate action */
+const (
+	ActionSourceFeed  = "FEED"
+	ActionSourceAdmin = "ADMIN"
+)
+
+/* Action on a company applied to every holding as of the day before ExDate.
+** SPLIT    - RatioTo new shares for RatioFrom old shares (eg: 5:1 face value split)
+** BONUS    - RatioTo additional shares for every RatioFrom held (eg: 1:1 bonus doubles units)
+** DIVIDEND - Amount per share */
+type CorporateAction struct {
+	CompanyId  string `json:"companyId"`
+	ExDate     string `json:"exDate"`
+	ActionType string `json:"actionType"`
+	RatioTo    string `json:"ratioTo"`
+	RatioFrom  string `json:"ratioFrom"`
+	Amount     string `json:"amount"`
+	Source     string `json:"source"`
+}
+
+type CorporateActionsInputJson struct {
+	UserID           string            `json:"userId"`
+	Companyid        string            `json:"companyid"`
+	CorporateActions []CorporateAction `json:"CorporateActions"`
+}
+
+type CorporateActionsOutputJson struct {
+	CorporateActions []CorporateAction `json:"CorporateActions"`
+}
+
+/* Add or edit corporate actions entered by admin */
+func AddCorporateActionsDB(corporateActions []CorporateAction, db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, action := range corporateActions {
+		_, err := tx.Exec("INSERT INTO CORPORATE_ACTIONS(COMPANY_ID, EX_DATE, ACTION_TYPE, RATIO_TO, RATIO_FROM, AMOUNT, SOURCE) "+
+			" VALUES($1, $2, $3, $4, $5, $6, $7) ON CONFLICT(COMPANY_ID, EX_DATE, ACTION_TYPE) "+
+			" DO UPDATE SET RATIO_TO = excluded.RATIO_TO, RATIO_FROM = excluded.RATIO_FROM, AMOUNT = excluded.AMOUNT, SOURCE = excluded.SOURCE ",
+			action.CompanyId, action.ExDate, action.ActionType, action.RatioTo, action.RatioFrom, action.Amount, action.Source)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+/* Corporate actions from price feed events. Existing rows are left as is so that admin edits are kept */
+func LoadFeedCorporateActionsDB(priceEvents []PriceEvent, db *sql.DB) error {
+	for _, event := range priceEvents {
+		ratioTo, ratioFrom := event.SplitTo, event.SplitFrom
+		if event.EventType != PriceEventSplit {
+			ratioTo, ratioFrom = 1, 1
+		}
+		_, err := db.Exec("INSERT INTO CORPORATE_ACTIONS(COMPANY_ID, EX_DATE, ACTION_TYPE, RATIO_TO, RATIO_FROM, AMOUNT, SOURCE) "+
+			" VALUES($1, $2, $3, $4, $5, $6, $7) ON CONFLICT(COMPANY_ID, EX_DATE, ACTION_TYPE) DO NOTHING ",
+			event.CompanyId, event.EventDate, event.EventType, ratioTo, ratioFrom, event.Amount, ActionSourceFeed)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/* Corporate actions of all companies ordered by company and ex date */
+func GetCorporateActionsDB(db *sql.DB) ([]CorporateAction, error) {
+	var corporateActions []CorporateAction
+	records, err := db.Query("SELECT COMPANY_ID, EX_DATE, ACTION_TYPE, RATIO_TO, RATIO_FROM, AMOUNT, SOURCE " +
+		" FROM CORPORATE_ACTIONS ORDER BY COMPANY_ID, EX_DATE, ACTION_TYPE ")
+	if err != nil {
+		return corporateActions, err
+	}
+	defer records.Close()
+	for records.Next() {
+		var action CorporateAction
+		err := records.Scan(&action.CompanyId, &action.ExDate, &action.ActionType, &action.RatioTo, &action.RatioFrom,
+			&action.Amount, &action.Source)
+		if err != nil {
+			return corporateActions, err
+		}
+		corporateActions = append(corporateActions, action)
+	}
+	return corporateActions, nil
+}
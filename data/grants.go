@@ -0,0 +1,114 @@
+package data
+
+import (
+	"database/sql"
+	"time"
+)
+
+/* Access levels granted on a shared portfolio */
+const (
+	PermissionRead  = "READ"
+	PermissionWrite = "WRITE"
+)
+
+type PortfolioGrant struct {
+	PortfolioId   string `json:"portfolioId"`
+	PortfolioName string `json:"portfolioName"`
+	OwnerUserId   string `json:"ownerUserId"`
+	GranteeUserId string `json:"granteeUserId"`
+	Permission    string `json:"permission"`
+	GrantedDate   string `json:"grantedDate"`
+}
+
+type PortfolioGrantInputJson struct {
+	UserID string         `json:"userId"`
+	Grant  PortfolioGrant `json:"Grant"`
+}
+
+type PortfolioGrantsOutputJson struct {
+	UserID       string           `json:"userId"`
+	GrantedByMe  []PortfolioGrant `json:"GrantedByMe"`
+	SharedWithMe []PortfolioGrant `json:"SharedWithMe"`
+}
+
+/* Grant or update access of a portfolio to another user */
+func AddPortfolioGrantDB(portfolioId int64, granteeUserId string, permission string, db *sql.DB) error {
+	_, err := db.Exec("INSERT INTO PORTFOLIO_GRANTS(PORTFOLIO_ID, GRANTEE_USER_ID, PERMISSION, GRANTED_DATE) VALUES($1, $2, $3, $4) "+
+		" ON CONFLICT(PORTFOLIO_ID, GRANTEE_USER_ID) DO UPDATE SET PERMISSION = excluded.PERMISSION, GRANTED_DATE = excluded.GRANTED_DATE ",
+		portfolioId, granteeUserId, permission, time.Now())
+	return err
+}
+
+/* Returns false when portfolio was not shared with grantee */
+func DeletePortfolioGrantDB(portfolioId int64, granteeUserId string, db *sql.DB) (bool, error) {
+	result, err := db.Exec("DELETE FROM PORTFOLIO_GRANTS WHERE PORTFOLIO_ID = $1 AND GRANTEE_USER_ID = $2 ", portfolioId, granteeUserId)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+/* Grants given by owner on their portfolios */
+func GetGrantsByOwnerDB(ownerUserId string, db *sql.DB) ([]PortfolioGrant, error) {
+	return fetchGrantsDB("PF.USER_ID = $1 ", ownerUserId, db)
+}
+
+/* Portfolios of other users shared with grantee */
+func GetGrantsForGranteeDB(granteeUserId string, db *sql.DB) ([]PortfolioGrant, error) {
+	return fetchGrantsDB("GRANTS.GRANTEE_USER_ID = $1 ", granteeUserId, db)
+}
+
+func fetchGrantsDB(userCondition string, userId string, db *sql.DB) ([]PortfolioGrant, error) {
+	var grants []PortfolioGrant
+	records, err := db.Query("SELECT GRANTS.PORTFOLIO_ID, PF.PORTFOLIO_NAME, PF.USER_ID, GRANTS.GRANTEE_USER_ID, GRANTS.PERMISSION, GRANTS.GRANTED_DATE "+
+		"FROM PORTFOLIO_GRANTS GRANTS, PORTFOLIOS PF WHERE GRANTS.PORTFOLIO_ID = PF.PORTFOLIO_ID AND "+userCondition+
+		"ORDER BY GRANTS.PORTFOLIO_ID", userId)
+	if err != nil {
+		return grants, err
+	}
+	defer records.Close()
+	for records.Next() {
+		var grant PortfolioGrant
+		err := records.Scan(&grant.PortfolioId, &grant.PortfolioName, &grant.OwnerUserId, &grant.GranteeUserId, &grant.Permission, &grant.GrantedDate)
+		if err != nil {
+			return grants, err
+		}
+		grants = append(grants, grant)
+	}
+	return grants, nil
+}
+
+/* Permission of grantee on a portfolio. Empty when not shared */
+func GetPortfolioPermissionDB(portfolioId int64, granteeUserId string, db *sql.DB) (string, error) {
+	var permission string
+	records, err := db.Query("SELECT PERMISSION FROM PORTFOLIO_GRANTS WHERE PORTFOLIO_ID = $1 AND GRANTEE_USER_ID = $2 ", portfolioId, granteeUserId)
+	if err != nil {
+		return permission, err
+	}
+	defer records.Close()
+	for records.Next() {
+		errRead := records.Scan(&permission)
+		if errRead != nil {
+			return permission, errRead
+		}
+	}
+	return permission, nil
+}
+
+type HouseholdPortfolio struct {
+	PortfolioId   string     `json:"portfolioId"`
+	PortfolioName string     `json:"portfolioName"`
+	OwnerUserId   string     `json:"ownerUserId"`
+	Permission    string     `json:"permission,omitempty"`
+	Networth      string     `json:"networth"`
+	Allocation    Allocation `json:"allocation"`
+}
+
+type HouseholdOutputJson struct {
+	UserID     string               `json:"userId"`
+	Networth   string               `json:"Networth"`
+	Allocation Allocation           `json:"Allocation"`
+	Portfolios []HouseholdPortfolio `json:"Portfolios"`
+	Holdings   []Holdings           `json:"Holdings"`
+}
@@ -0,0 +1,150 @@
+package data
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+/* What started an ingestion run */
+const (
+	JobTriggerScheduled = "SCHEDULED"
+	JobTriggerManual    = "MANUAL"
+)
+
+/* Ingestion job status. PARTIAL when some instruments failed or were skipped */
+const (
+	JobStatusQueued    = "QUEUED"
+	JobStatusRunning   = "RUNNING"
+	JobStatusSucceeded = "SUCCEEDED"
+	JobStatusPartial   = "PARTIAL"
+	JobStatusFailed    = "FAILED"
+)
+
+/* A price ingestion run. Instruments and Errors are comma/semicolon separated */
+type IngestionJob struct {
+	JobId        string `json:"jobId"`
+	Trigger      string `json:"trigger"`
+	RequestedBy  string `json:"requestedBy"`
+	Status       string `json:"status"`
+	Instruments  string `json:"instruments"`
+	Total        string `json:"total"`
+	Succeeded    string `json:"succeeded"`
+	Failed       string `json:"failed"`
+	Skipped      string `json:"skipped"`
+	RowsInserted string `json:"rowsInserted"`
+	RowsUpdated  string `json:"rowsUpdated"`
+	RowsRejected string `json:"rowsRejected"`
+	Errors       string `json:"errors"`
+	QueuedAt     string `json:"queuedAt"`
+	StartedAt    string `json:"startedAt"`
+	FinishedAt   string `json:"finishedAt"`
+	Owner        string `json:"owner"`
+	HeartbeatAt  string `json:"heartbeatAt"`
+}
+
+type IngestionJobInput struct {
+	UserID string `json:"userId"`
+	JobId  string `json:"jobId"`
+	Limit  string `json:"limit"`
+}
+
+type IngestionJobsOutputJson struct {
+	Jobs []IngestionJob `json:"Jobs"`
+}
+
+/* Counts recorded when a job completes */
+type IngestionJobResult struct {
+	Status       string
+	Succeeded    int
+	Failed       int
+	Skipped      int
+	RowsInserted int
+	RowsUpdated  int
+	RowsRejected int
+	Errors       []string
+}
+
+const ingestionJobColumns = "JOB_ID, TRIGGER, COALESCE(REQUESTED_BY, ''), STATUS, INSTRUMENTS, TOTAL, SUCCEEDED, FAILED, SKIPPED, " +
+	" ROWS_INSERTED, ROWS_UPDATED, ROWS_REJECTED, COALESCE(ERRORS, ''), TO_CHAR(QUEUED_AT, 'YYYY-MM-DD\"T\"HH24:MI:SS'), " +
+	" COALESCE(TO_CHAR(STARTED_AT, 'YYYY-MM-DD\"T\"HH24:MI:SS'), ''), COALESCE(TO_CHAR(FINISHED_AT, 'YYYY-MM-DD\"T\"HH24:MI:SS'), ''), " +
+	" COALESCE(OWNER, ''), COALESCE(TO_CHAR(HEARTBEAT_AT, 'YYYY-MM-DD\"T\"HH24:MI:SS'), '') "
+
+/* Record a queued job owned by an instance and return its id */
+func AddIngestionJobDB(trigger string, requestedBy string, companyIds []string, owner string, db *sql.DB) (int64, error) {
+	var jobId int64
+	now := time.Now()
+	err := db.QueryRow("INSERT INTO INGESTION_JOBS(TRIGGER, REQUESTED_BY, STATUS, INSTRUMENTS, TOTAL, QUEUED_AT, OWNER, HEARTBEAT_AT) "+
+		" VALUES($1, NULLIF($2, ''), $3, $4, $5, $6, $7, $6) RETURNING JOB_ID ",
+		trigger, requestedBy, JobStatusQueued, strings.Join(companyIds, ","), len(companyIds), now, owner).Scan(&jobId)
+	return jobId, err
+}
+
+/* Owner of a queued or running job marks it alive */
+func HeartbeatIngestionJobDB(jobId int64, db *sql.DB) error {
+	_, err := db.Exec("UPDATE INGESTION_JOBS SET HEARTBEAT_AT = $1 WHERE JOB_ID = $2 AND STATUS IN ($3, $4) ",
+		time.Now(), jobId, JobStatusQueued, JobStatusRunning)
+	return err
+}
+
+/* Only a queued job starts and only a running job finishes, a job failed as interrupted stays failed */
+func StartIngestionJobDB(jobId int64, db *sql.DB) error {
+	_, err := db.Exec("UPDATE INGESTION_JOBS SET STATUS = $1, STARTED_AT = $2 WHERE JOB_ID = $3 AND STATUS = $4 ",
+		JobStatusRunning, time.Now(), jobId, JobStatusQueued)
+	return err
+}
+
+func FinishIngestionJobDB(jobId int64, result IngestionJobResult, db *sql.DB) error {
+	_, err := db.Exec("UPDATE INGESTION_JOBS SET STATUS = $1, SUCCEEDED = $2, FAILED = $3, SKIPPED = $4, ROWS_INSERTED = $5, "+
+		" ROWS_UPDATED = $6, ROWS_REJECTED = $7, ERRORS = NULLIF($8, ''), FINISHED_AT = $9 WHERE JOB_ID = $10 AND STATUS = $11 ",
+		result.Status, result.Succeeded, result.Failed, result.Skipped, result.RowsInserted, result.RowsUpdated, result.RowsRejected,
+		strings.Join(result.Errors, "; "), time.Now(), jobId, JobStatusRunning)
+	return err
+}
+
+/* Jobs left queued or running without a heartbeat since staleBefore can never complete, their instance has stopped.
+** Jobs of other replicas keep their heartbeat and are left alone */
+func FailInterruptedIngestionJobsDB(staleBefore time.Time, db *sql.DB) (int64, error) {
+	result, err := db.Exec("UPDATE INGESTION_JOBS SET STATUS = $1, ERRORS = 'Interrupted by restart', FINISHED_AT = $2 "+
+		" WHERE STATUS IN ($3, $4) AND COALESCE(HEARTBEAT_AT, QUEUED_AT) < $5 ",
+		JobStatusFailed, time.Now(), JobStatusQueued, JobStatusRunning, staleBefore)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+/* Job by id, JobId is empty when not found */
+func GetIngestionJobDB(jobId int64, db *sql.DB) (IngestionJob, error) {
+	var job IngestionJob
+	jobs, err := fetchIngestionJobsDB("WHERE JOB_ID = $1 ", jobId, db)
+	if err != nil || len(jobs) == 0 {
+		return job, err
+	}
+	return jobs[0], nil
+}
+
+/* Latest jobs first */
+func GetIngestionJobsDB(limit int, db *sql.DB) ([]IngestionJob, error) {
+	return fetchIngestionJobsDB("ORDER BY JOB_ID DESC LIMIT $1 ", limit, db)
+}
+
+func fetchIngestionJobsDB(condition string, arg interface{}, db *sql.DB) ([]IngestionJob, error) {
+	var jobs []IngestionJob
+	records, err := db.Query("SELECT "+ingestionJobColumns+" FROM INGESTION_JOBS "+condition, arg)
+	if err != nil {
+		return jobs, err
+	}
+	defer records.Close()
+	for records.Next() {
+		var job IngestionJob
+		err := records.Scan(&job.JobId, &job.Trigger, &job.RequestedBy, &job.Status, &job.Instruments, &job.Total, &job.Succeeded,
+			&job.Failed, &job.Skipped, &job.RowsInserted, &job.RowsUpdated, &job.RowsRejected, &job.Errors, &job.QueuedAt,
+			&job.StartedAt, &job.FinishedAt, &job.Owner, &job.HeartbeatAt)
+		if err != nil {
+			return jobs, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
@@ -0,0 +1,109 @@
+package data
+
+import (
+	"database/sql"
+)
+
+/* Exchanges */
+const (
+	ExchangeNSE = "NSE"
+	ExchangeBSE = "BSE"
+)
+
+/* Asset classes of an instrument */
+const (
+	AssetClassEquity = "EQUITY"
+	AssetClassETF    = "ETF"
+	AssetClassMF     = "MF"
+	AssetClassIndex  = "INDEX"
+	AssetClassBond   = "BOND"
+	AssetClassGold   = "GOLD"
+)
+
+const CurrencyINR = "INR"
+
+/* Metadata of a company/fund/index whose prices are tracked.
+** Provider and ProviderSymbol are optional, config default provider and exchange ticker rules apply when empty */
+type Instrument struct {
+	CompanyId      string `json:"companyId"`
+	CompanyName    string `json:"companyName"`
+	Exchange       string `json:"exchange"`
+	AssetClass     string `json:"assetClass"`
+	Currency       string `json:"currency"`
+	Provider       string `json:"provider"`
+	ProviderSymbol string `json:"providerSymbol"`
+	Isin           string `json:"isin"`
+}
+
+type InstrumentsInputJson struct {
+	UserID      string       `json:"userId"`
+	Instruments []Instrument `json:"Instruments"`
+}
+
+type InstrumentsOutputJson struct {
+	Instruments []Instrument `json:"Instruments"`
+}
+
+/* Add or edit instruments. Company is created when not present in master list */
+func AddInstrumentsDB(instruments []Instrument, db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, instrument := range instruments {
+		_, err := tx.Exec("INSERT INTO COMPANIES(COMPANY_ID, COMPANY_NAME) VALUES($1, $2) "+
+			" ON CONFLICT(COMPANY_ID) DO UPDATE SET COMPANY_NAME = COALESCE(NULLIF(excluded.COMPANY_NAME, ''), COMPANIES.COMPANY_NAME) ",
+			instrument.CompanyId, instrument.CompanyName)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec("INSERT INTO INSTRUMENTS(COMPANY_ID, EXCHANGE, ASSET_CLASS, CURRENCY, PROVIDER, PROVIDER_SYMBOL, ISIN) "+
+			" VALUES($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, ''), NULLIF($7, '')) "+
+			" ON CONFLICT(COMPANY_ID) DO UPDATE SET EXCHANGE = excluded.EXCHANGE, ASSET_CLASS = excluded.ASSET_CLASS, CURRENCY = excluded.CURRENCY, "+
+			" PROVIDER = excluded.PROVIDER, PROVIDER_SYMBOL = excluded.PROVIDER_SYMBOL, ISIN = excluded.ISIN ",
+			instrument.CompanyId, instrument.Exchange, instrument.AssetClass, instrument.Currency,
+			instrument.Provider, instrument.ProviderSymbol, instrument.Isin)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+/* Instruments from master list. Existing rows are left as is so that admin edits are kept */
+func LoadInstrumentsMasterListDB(instruments []Instrument, db *sql.DB) error {
+	for _, instrument := range instruments {
+		_, err := db.Exec("INSERT INTO INSTRUMENTS(COMPANY_ID, EXCHANGE, ASSET_CLASS, CURRENCY, PROVIDER_SYMBOL, ISIN) "+
+			" VALUES($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, '')) ON CONFLICT(COMPANY_ID) DO NOTHING ",
+			instrument.CompanyId, instrument.Exchange, instrument.AssetClass, instrument.Currency,
+			instrument.ProviderSymbol, instrument.Isin)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func GetInstrumentsDB(db *sql.DB) ([]Instrument, error) {
+	var instruments []Instrument
+	records, err := db.Query("SELECT INST.COMPANY_ID, COMP.COMPANY_NAME, INST.EXCHANGE, INST.ASSET_CLASS, INST.CURRENCY, " +
+		" COALESCE(INST.PROVIDER, ''), COALESCE(INST.PROVIDER_SYMBOL, ''), COALESCE(INST.ISIN, '') " +
+		" FROM INSTRUMENTS INST, COMPANIES COMP WHERE INST.COMPANY_ID = COMP.COMPANY_ID ORDER BY INST.COMPANY_ID ")
+	if err != nil {
+		return instruments, err
+	}
+	defer records.Close()
+	for records.Next() {
+		var instrument Instrument
+		err := records.Scan(&instrument.CompanyId, &instrument.CompanyName, &instrument.Exchange, &instrument.AssetClass,
+			&instrument.Currency, &instrument.Provider, &instrument.ProviderSymbol, &instrument.Isin)
+		if err != nil {
+			return instruments, err
+		}
+		instruments = append(instruments, instrument)
+	}
+	return instruments, nil
+}
@@ -3,47 +3,60 @@ package data
 import (
 	"database/sql"
 	"fmt"
-	"strconv"
 	"strings"
 	"time"
 
+	"github.com/lib/pq"
+	"github.com/vijayyogesh/PortfolioApis/money"
 	"github.com/vijayyogesh/PortfolioApis/util"
 )
 
+/* LoadDate is when prices were last refreshed successfully, zero when never loaded.
+** FullReload refetches complete history instead of refreshing from the last stored date */
 type Company struct {
 	CompanyId   string
 	CompanyName string
+	Industry    string
+	Isin        string
 	LoadDate    time.Time
+	FullReload  bool
 }
 
 type CompaniesPriceData struct {
-	CompanyId string
-	OpenVal   float64
-	HighVal   float64
-	LowVal    float64
-	CloseVal  float64
-	DateVal   time.Time
+	CompanyId   string
+	OpenVal     money.Decimal
+	HighVal     money.Decimal
+	LowVal      money.Decimal
+	CloseVal    money.Decimal
+	DateVal     time.Time
+	AdjCloseVal money.Decimal
+	Volume      int64
 }
 
 type User struct {
 	UserId       string
 	StartDate    time.Time
-	TargetAmount float64 `json:",string"`
-	Password     string  `json:"password"`
+	TargetAmount money.Decimal
+	Password     string `json:"password"`
+	PortfolioId  string `json:"portfolioId"`
+	ReturnType   string `json:"returnType"`
 }
 
 type HoldingsInputJson struct {
-	UserID     string               `json:"userId"`
-	Holdings   []Holdings           `json:"Holdings"`
-	HoldingsNT []HoldingsNonTracked `json:"HoldingsNonTracked"`
+	UserID       string               `json:"userId"`
+	PortfolioId  string               `json:"portfolioId"`
+	Holdings     []Holdings           `json:"Holdings"`
+	HoldingsNT   []HoldingsNonTracked `json:"HoldingsNonTracked"`
+	Transactions []Transaction        `json:"Transactions"`
 }
 
 type HoldingsOutputJson struct {
-	UserID     string               `json:"userId"`
-	Holdings   []Holdings           `json:"Holdings"`
-	HoldingsNT []HoldingsNonTracked `json:"HoldingsNonTracked"`
-	Networth   string               `json:"Networth"`
-	Allocation Allocation           `json:"Allocation"`
+	UserID      string               `json:"userId"`
+	PortfolioId string               `json:"portfolioId"`
+	Holdings    []Holdings           `json:"Holdings"`
+	HoldingsNT  []HoldingsNonTracked `json:"HoldingsNonTracked"`
+	Networth    string               `json:"Networth"`
+	Allocation  Allocation           `json:"Allocation"`
 }
 
 type Holdings struct {
@@ -56,11 +69,15 @@ type Holdings struct {
 	CurrentValue string `json:"currentValue"`
 	PL           string `json:"pl"`
 	NetPct       string `json:"netPct"`
+	RealizedPL   string `json:"realizedPl,omitempty"`
+	Dividends    string `json:"dividends,omitempty"`
+	TxnType      string `json:"txnType,omitempty"`
 }
 
 type Allocation struct {
 	Equity string `json:"equity"`
 	Debt   string `json:"debt"`
+	Gold   string `json:"gold,omitempty"`
 }
 
 type HoldingsNonTracked struct {
@@ -72,14 +89,16 @@ type HoldingsNonTracked struct {
 }
 
 type ModelPortfolio struct {
-	UserID     string       `json:"userId"`
-	Securities []Securities `json:"Securities"`
+	UserID      string       `json:"userId"`
+	PortfolioId string       `json:"portfolioId"`
+	Securities  []Securities `json:"Securities"`
 }
 
 type Securities struct {
 	Securityid         string `json:"securityid"`
 	ReasonablePrice    string `json:"reasonablePrice"`
 	ExpectedAllocation string `json:"expectedAllocation"`
+	PortfolioId        string `json:"portfolioId,omitempty"`
 }
 
 type SyncedPortfolio struct {
@@ -87,6 +106,7 @@ type SyncedPortfolio struct {
 }
 
 type AdjustedHolding struct {
+	PortfolioId                 string `json:"portfolioId"`
 	Securityid                  string `json:"securityid"`
 	AdjustedAmount              string `json:"adjustedAmount"`
 	BelowReasonablePrice        string `json:"belowReasonablePrice"`
@@ -102,6 +122,80 @@ type NetworthOnADate struct {
 	Networth string `json:"networth"`
 }
 
+/* Counters of an in-memory cache */
+type CacheStats struct {
+	Name          string `json:"name"`
+	Entries       string `json:"entries"`
+	Capacity      string `json:"capacity"`
+	Hits          string `json:"hits"`
+	Misses        string `json:"misses"`
+	Loads         string `json:"loads"`
+	Evictions     string `json:"evictions"`
+	Invalidations string `json:"invalidations"`
+}
+
+type CacheStatsOutputJson struct {
+	Caches []CacheStats `json:"Caches"`
+}
+
+/* Company search query. Page starts at 1 */
+type CompanySearchInputJson struct {
+	UserID   string `json:"userId"`
+	Query    string `json:"query"`
+	Page     string `json:"page"`
+	PageSize string `json:"pageSize"`
+}
+
+type CompanySearchResult struct {
+	CompanyId   string `json:"companyId"`
+	CompanyName string `json:"companyName"`
+	Industry    string `json:"industry"`
+	Isin        string `json:"isin"`
+	Score       string `json:"score"`
+}
+
+/* Page of matches ranked by score. Total is the count of all matches */
+type CompanySearchOutputJson struct {
+	Query     string                `json:"query"`
+	Total     string                `json:"total"`
+	Page      string                `json:"page"`
+	PageSize  string                `json:"pageSize"`
+	Companies []CompanySearchResult `json:"Companies"`
+}
+
+/* Unitized return of a portfolio. NAV starts at base value on first transaction */
+type NavReturnOutputJson struct {
+	UserID           string `json:"userId"`
+	PortfolioId      string `json:"portfolioId"`
+	StartDate        string `json:"startDate"`
+	Nav              string `json:"nav"`
+	Units            string `json:"units"`
+	CurrentValue     string `json:"currentValue"`
+	NetInvested      string `json:"netInvested"`
+	AbsoluteReturn   string `json:"absoluteReturn"`
+	AnnualizedReturn string `json:"annualizedReturn"`
+}
+
+/* Time weighted return of portfolio and benchmark over a period. Periods over a year are also annualized */
+type TwrPeriodReturn struct {
+	Period              string `json:"period"`
+	StartDate           string `json:"startDate"`
+	EndDate             string `json:"endDate"`
+	PortfolioReturn     string `json:"portfolioReturn"`
+	PortfolioAnnualized string `json:"portfolioAnnualized"`
+	BenchmarkReturn     string `json:"benchmarkReturn"`
+	BenchmarkAnnualized string `json:"benchmarkAnnualized"`
+}
+
+/* Periods the portfolio was held for, along with since inception */
+type TwrOutputJson struct {
+	UserID      string            `json:"userId"`
+	PortfolioId string            `json:"portfolioId"`
+	ReturnType  string            `json:"returnType"`
+	BenchmarkId string            `json:"benchmarkId"`
+	Periods     []TwrPeriodReturn `json:"periods"`
+}
+
 type CompaniesInput struct {
 	UserID  string    `json:"userId"`
 	Company []Company `json:"Company"`
@@ -113,6 +207,8 @@ type SIPReturnInputParam struct {
 	EndDate   string `json:"enddate"`
 	SIPAmount string `json:"sipamount"`
 	StepUpPct string `json:"stepuppct"`
+	/* price (default) or total */
+	ReturnType string `json:"returntype"`
 }
 
 type SIPReturnInput struct {
@@ -143,37 +239,87 @@ type SIPReturnBracket struct {
 	GreaterThanTenCount string `json:"greaterThanTenCount"`
 }
 
-func LoadPriceDataDB(dailyPriceRecords []CompaniesPriceData, db *sql.DB) error {
+/* Postgres allows 65535 bind parameters per statement. 8 parameters per price row */
+const priceInsertChunkRows = 65535 / 8
+
+/* Upsert price rows in chunks within one transaction. Returns count of inserted and updated rows */
+func LoadPriceDataDB(dailyPriceRecords []CompaniesPriceData, db *sql.DB) (int, int, error) {
+	var inserted, updated int
+	tx, err := db.Begin()
+	if err != nil {
+		return inserted, updated, err
+	}
+	defer tx.Rollback()
+
+	for start := 0; start < len(dailyPriceRecords); start += priceInsertChunkRows {
+		end := start + priceInsertChunkRows
+		if end > len(dailyPriceRecords) {
+			end = len(dailyPriceRecords)
+		}
+		chunkInserted, chunkUpdated, err := loadPriceDataChunkTx(dailyPriceRecords[start:end], tx)
+		if err != nil {
+			return inserted, updated, err
+		}
+		inserted += chunkInserted
+		updated += chunkUpdated
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, 0, err
+	}
+	return inserted, updated, nil
+}
+
+/* Single multi row upsert. xmax is zero only for rows inserted by this statement */
+func loadPriceDataChunkTx(dailyPriceRecords []CompaniesPriceData, tx *sql.Tx) (int, int, error) {
+	var inserted, updated int
 	valueStrings := make([]string, 0, len(dailyPriceRecords))
-	valueArgs := make([]interface{}, 0, len(dailyPriceRecords)*6)
+	valueArgs := make([]interface{}, 0, len(dailyPriceRecords)*8)
 
-	/* Loop and Bulk Insert Records */
+	/* Loop and Bulk Insert Records. Adj close is left null when feed does not have it */
 	for k, v := range dailyPriceRecords {
-		valueStrings = append(valueStrings, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d)", k*6+1, k*6+2, k*6+3, k*6+4, k*6+5, k*6+6))
-		valueArgs = append(valueArgs, v.CompanyId, v.OpenVal, v.HighVal, v.LowVal, v.CloseVal, v.DateVal)
+		valueStrings = append(valueStrings, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d)",
+			k*8+1, k*8+2, k*8+3, k*8+4, k*8+5, k*8+6, k*8+7, k*8+8))
+		adjCloseVal := money.NullDecimal{Decimal: v.AdjCloseVal, Valid: v.AdjCloseVal.Sign() > 0}
+		valueArgs = append(valueArgs, v.CompanyId, v.OpenVal, v.HighVal, v.LowVal, v.CloseVal, v.DateVal, adjCloseVal, v.Volume)
 	}
-	stmt := fmt.Sprintf("INSERT INTO COMPANIES_PRICE_DATA(COMPANY_ID, OPEN_VAL,HIGH_VAL, LOW_VAL, CLOSE_VAL, DATE_VAL) VALUES %s "+
-		" ON CONFLICT(COMPANY_ID, DATE_VAL) DO UPDATE SET CLOSE_VAL = excluded.CLOSE_VAL ", strings.Join(valueStrings, ","))
+	stmt := fmt.Sprintf("INSERT INTO COMPANIES_PRICE_DATA(COMPANY_ID, OPEN_VAL,HIGH_VAL, LOW_VAL, CLOSE_VAL, DATE_VAL, ADJ_CLOSE_VAL, VOLUME) VALUES %s "+
+		" ON CONFLICT(COMPANY_ID, DATE_VAL) DO UPDATE SET OPEN_VAL = excluded.OPEN_VAL, HIGH_VAL = excluded.HIGH_VAL, "+
+		" LOW_VAL = excluded.LOW_VAL, CLOSE_VAL = excluded.CLOSE_VAL, ADJ_CLOSE_VAL = excluded.ADJ_CLOSE_VAL, VOLUME = excluded.VOLUME "+
+		" RETURNING (xmax = 0) AS INSERTED ", strings.Join(valueStrings, ","))
 
-	_, err := db.Exec(stmt, valueArgs...)
+	records, err := tx.Query(stmt, valueArgs...)
 	if err != nil {
-		return err
+		return inserted, updated, err
 	}
-
-	return nil
+	defer records.Close()
+	for records.Next() {
+		var isInserted bool
+		err := records.Scan(&isInserted)
+		if err != nil {
+			return inserted, updated, err
+		}
+		if isInserted {
+			inserted++
+		} else {
+			updated++
+		}
+	}
+	return inserted, updated, records.Err()
 }
 
 /* Fetch All Price Data for a given company */
 func FetchCompaniesCompletePriceDataDB(companyid string, db *sql.DB) []CompaniesPriceData {
 	var dailyPriceRecords []CompaniesPriceData
-	records, err := db.Query("SELECT DATE_VAL, CLOSE_VAL FROM COMPANIES_PRICE_DATA WHERE COMPANY_ID = $1 ", companyid)
+	records, err := db.Query("SELECT DATE_VAL, CLOSE_VAL, COALESCE(ADJ_CLOSE_VAL, CLOSE_VAL) FROM COMPANIES_PRICE_DATA WHERE COMPANY_ID = $1 ", companyid)
 	if err != nil {
 		panic(err.Error())
 	}
 	defer records.Close()
 	for records.Next() {
 		var dailyRecord CompaniesPriceData
-		err := records.Scan(&dailyRecord.DateVal, &dailyRecord.CloseVal)
+		err := records.Scan(&dailyRecord.DateVal, &dailyRecord.CloseVal, &dailyRecord.AdjCloseVal)
 		if err != nil {
 			fmt.Println(err.Error(), "Error scanning record ")
 		}
@@ -222,33 +368,56 @@ func FetchCompaniesLatestPriceDataDB(companyid string, db *sql.DB) (CompaniesPri
 /* Fetch Unique Company Ids */
 func FetchCompaniesDB(db *sql.DB) ([]Company, error) {
 	var companies []Company
-	records, err := db.Query("SELECT COMPANY_ID, COMPANY_NAME, LOAD_DATE FROM COMPANIES ")
+	records, err := db.Query("SELECT COMPANY_ID, COMPANY_NAME, COALESCE(INDUSTRY, ''), COALESCE(ISIN, ''), LOAD_DATE FROM COMPANIES ")
 	if err != nil {
 		return companies, err
 	}
 	defer records.Close()
 	for records.Next() {
 		var company Company
-		err := records.Scan(&company.CompanyId, &company.CompanyName, &company.LoadDate)
+		var loadDate sql.NullTime
+		err := records.Scan(&company.CompanyId, &company.CompanyName, &company.Industry, &company.Isin, &loadDate)
 		if err != nil {
 			return companies, err
 		}
+		company.LoadDate = loadDate.Time
 		companies = append(companies, company)
 	}
 	return companies, nil
 }
 
-func UpdateLoadDate(db *sql.DB, companyId string, loadDate time.Time) {
-	db.Exec("UPDATE COMPANIES SET LOAD_DATE = $1 WHERE COMPANY_ID = $2 ", loadDate, companyId)
+/* Latest stored price date by company. Companies without prices are absent */
+func FetchLastPriceDatesDB(db *sql.DB) (map[string]time.Time, error) {
+	lastPriceDates := make(map[string]time.Time)
+	records, err := db.Query("SELECT COMPANY_ID, MAX(DATE_VAL) FROM COMPANIES_PRICE_DATA GROUP BY COMPANY_ID ")
+	if err != nil {
+		return lastPriceDates, err
+	}
+	defer records.Close()
+	for records.Next() {
+		var companyId string
+		var lastPriceDate time.Time
+		err := records.Scan(&companyId, &lastPriceDate)
+		if err != nil {
+			return lastPriceDates, err
+		}
+		lastPriceDates[companyId] = lastPriceDate
+	}
+	return lastPriceDates, nil
+}
+
+func UpdateLoadDate(db *sql.DB, companyId string, loadDate time.Time) error {
+	_, err := db.Exec("UPDATE COMPANIES SET LOAD_DATE = $1 WHERE COMPANY_ID = $2 ", loadDate, companyId)
+	return err
 }
 
 func LoadCompaniesMasterListDB(companiesMasterList []Company, appUtil *util.AppUtil) error {
 
 	/* Loop and Insert Records */
 	for k, v := range companiesMasterList {
-		_, err := appUtil.Db.Exec("INSERT INTO COMPANIES(COMPANY_ID, COMPANY_NAME, LOAD_DATE) VALUES($1, $2, $3) "+
-			" ON CONFLICT(COMPANY_ID) DO NOTHING ",
-			v.CompanyId, v.CompanyName, v.LoadDate)
+		_, err := appUtil.Db.Exec("INSERT INTO COMPANIES(COMPANY_ID, COMPANY_NAME, INDUSTRY, ISIN) VALUES($1, $2, NULLIF($3, ''), NULLIF($4, '')) "+
+			" ON CONFLICT(COMPANY_ID) DO UPDATE SET INDUSTRY = COALESCE(excluded.INDUSTRY, COMPANIES.INDUSTRY), ISIN = COALESCE(excluded.ISIN, COMPANIES.ISIN) ",
+			v.CompanyId, v.CompanyName, v.Industry, v.Isin)
 
 		/* Ignoring data errors for now */
 		if err != nil {
@@ -260,53 +429,65 @@ func LoadCompaniesMasterListDB(companiesMasterList []Company, appUtil *util.AppU
 	return nil
 }
 
+/* Add user along with a default portfolio holding the target amount */
 func AddUserDB(user User, db *sql.DB) error {
-	_, err := db.Exec("INSERT INTO USERS(USER_ID, START_DATE, TARGET_AMOUNT, PASSWORD) VALUES($1, $2, $3, $4) ",
-		user.UserId, user.StartDate, user.TargetAmount, user.Password)
+	tx, err := db.Begin()
 	if err != nil {
 		return err
 	}
-	return nil
+	defer tx.Rollback()
+
+	_, err = tx.Exec("INSERT INTO USERS(USER_ID, START_DATE, PASSWORD) VALUES($1, $2, $3) ",
+		user.UserId, user.StartDate, user.Password)
+	if err != nil {
+		return err
+	}
+
+	_, err = addPortfolioTx(user.UserId, DefaultPortfolioName, user.TargetAmount, tx)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-func AddUserHoldingsDB(userHoldings HoldingsInputJson, db *sql.DB) error {
+/* Add ledger transactions and non tracked assets in a single DB transaction */
+func AddUserHoldingsDB(portfolioId int64, userHoldings HoldingsInputJson, db *sql.DB) error {
 	userId := userHoldings.UserID
-	/* Add Tracked assets */
-	for _, company := range userHoldings.Holdings {
-		buyPrice, parseErr := strconv.ParseFloat(company.BuyPrice, 64)
-		if parseErr != nil {
-			return parseErr
-		}
 
-		_, err := db.Exec("INSERT INTO USER_HOLDINGS(USER_ID, COMPANY_ID, QUANTITY, BUY_DATE, BUY_PRICE) VALUES($1, $2, $3, $4, $5) ",
-			userId, company.Companyid, company.Quantity, company.BuyDate, buyPrice)
-		if err != nil {
-			return err
-		}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	/* Add Tracked assets as ledger entries */
+	errTxn := addUserTransactionsTx(userId, portfolioId, userHoldings.Transactions, tx)
+	if errTxn != nil {
+		return errTxn
 	}
 
 	/* Add Non Tracked assets */
 	for _, security := range userHoldings.HoldingsNT {
-		buyValue, bvParseErr := strconv.ParseFloat(security.BuyValue, 64)
+		buyValue, bvParseErr := money.Parse(security.BuyValue)
 		if bvParseErr != nil {
 			return bvParseErr
 		}
-		currentValue, cvParseErr := strconv.ParseFloat(security.CurrentValue, 64)
+		currentValue, cvParseErr := money.Parse(security.CurrentValue)
 		if cvParseErr != nil {
 			return cvParseErr
 		}
-		interestRate, irParseErr := strconv.ParseFloat(security.InterestRate, 64)
+		interestRate, irParseErr := money.Parse(security.InterestRate)
 		if irParseErr != nil {
 			return irParseErr
 		}
 
-		_, err := db.Exec("INSERT INTO USER_HOLDINGS_NT(USER_ID, SECURITY_ID, BUY_DATE, BUY_VALUE, CURRENT_VALUE, INTEREST_RATE) VALUES($1, $2, $3, $4, $5, $6) ",
-			userId, security.SecurityId, security.BuyDate, buyValue, currentValue, interestRate)
+		_, err := tx.Exec("INSERT INTO USER_HOLDINGS_NT(USER_ID, PORTFOLIO_ID, SECURITY_ID, BUY_DATE, BUY_VALUE, CURRENT_VALUE, INTEREST_RATE) VALUES($1, $2, $3, $4, $5, $6, $7) ",
+			userId, portfolioId, security.SecurityId, security.BuyDate, buyValue, currentValue, interestRate)
 		if err != nil {
 			return err
 		}
 	}
-	return nil
+	return tx.Commit()
 }
 
 func FetchUniqueUsersDB(db *sql.DB) ([]User, error) {
@@ -327,34 +508,14 @@ func FetchUniqueUsersDB(db *sql.DB) ([]User, error) {
 	return users, nil
 }
 
-func GetUserHoldingsDB(userid string, db *sql.DB) (HoldingsOutputJson, error) {
-	var holdingsOutputJson HoldingsOutputJson
-	holdingsOutputJson.UserID = userid
-
-	/* Tracked Data */
-	records, err := db.Query("SELECT HOLDINGS.USER_ID, HOLDINGS.COMPANY_ID, HOLDINGS.QUANTITY, HOLDINGS.BUY_DATE, HOLDINGS.BUY_PRICE, COMPANIES.COMPANY_NAME "+
-		"FROM USERS USERS, USER_HOLDINGS HOLDINGS, COMPANIES COMPANIES "+
-		"WHERE USERS.USER_ID = HOLDINGS.USER_ID AND HOLDINGS.COMPANY_ID = COMPANIES.COMPANY_ID AND USERS.USER_ID = $1 ORDER BY BUY_DATE", userid)
-	if err != nil {
-		return holdingsOutputJson, err
-	}
-	defer records.Close()
-
-	for records.Next() {
-		var holdings Holdings
-		var userid string
-		err := records.Scan(&userid, &holdings.Companyid, &holdings.Quantity, &holdings.BuyDate, &holdings.BuyPrice, &holdings.CompanyName)
-		if err != nil {
-			return holdingsOutputJson, err
-		}
-		holdingsOutputJson.Holdings = append(holdingsOutputJson.Holdings, holdings)
-	}
+/* Tracked holdings are derived from USER_TRANSACTIONS, only Non Tracked data is read here */
+func GetUserHoldingsNTDB(portfolioIds []int64, db *sql.DB) ([]HoldingsNonTracked, error) {
+	var holdingsNTList []HoldingsNonTracked
 
-	/* Non Tracked Data*/
-	recordsNT, errNT := db.Query("SELECT HOLDINGS_NT.USER_ID, HOLDINGS_NT.SECURITY_ID, HOLDINGS_NT.BUY_VALUE, HOLDINGS_NT.BUY_DATE, HOLDINGS_NT.CURRENT_VALUE FROM USERS USERS, USER_HOLDINGS_NT HOLDINGS_NT "+
-		"WHERE USERS.USER_ID = HOLDINGS_NT.USER_ID AND USERS.USER_ID = $1", userid)
+	recordsNT, errNT := db.Query("SELECT HOLDINGS_NT.USER_ID, HOLDINGS_NT.SECURITY_ID, HOLDINGS_NT.BUY_VALUE, HOLDINGS_NT.BUY_DATE, HOLDINGS_NT.CURRENT_VALUE FROM USER_HOLDINGS_NT HOLDINGS_NT "+
+		"WHERE HOLDINGS_NT.PORTFOLIO_ID = ANY($1) ORDER BY HOLDINGS_NT.BUY_DATE", pq.Array(portfolioIds))
 	if errNT != nil {
-		return holdingsOutputJson, errNT
+		return holdingsNTList, errNT
 	}
 	defer recordsNT.Close()
 
@@ -363,30 +524,30 @@ func GetUserHoldingsDB(userid string, db *sql.DB) (HoldingsOutputJson, error) {
 		var userid string
 		err := recordsNT.Scan(&userid, &holdingsNT.SecurityId, &holdingsNT.BuyValue, &holdingsNT.BuyDate, &holdingsNT.CurrentValue)
 		if err != nil {
-			return holdingsOutputJson, err
+			return holdingsNTList, err
 		}
-		holdingsOutputJson.HoldingsNT = append(holdingsOutputJson.HoldingsNT, holdingsNT)
+		holdingsNTList = append(holdingsNTList, holdingsNT)
 	}
 
-	return holdingsOutputJson, nil
+	return holdingsNTList, nil
 }
 
-func AddModelPortfolioDB(userHoldings ModelPortfolio, db *sql.DB) error {
+func AddModelPortfolioDB(portfolioId int64, userHoldings ModelPortfolio, db *sql.DB) error {
 	userId := userHoldings.UserID
 	for _, security := range userHoldings.Securities {
-		reasonablePrice, parseErr := strconv.ParseFloat(security.ReasonablePrice, 64)
+		reasonablePrice, parseErr := money.Parse(security.ReasonablePrice)
 		if parseErr != nil {
 			return parseErr
 		}
 
-		expAlloc, parseErr := strconv.ParseFloat(security.ExpectedAllocation, 64)
+		expAlloc, parseErr := money.Parse(security.ExpectedAllocation)
 		if parseErr != nil {
 			return parseErr
 		}
 
-		_, err := db.Exec("INSERT INTO USER_MODEL_PF(USER_ID, SECURITY_ID, REASONABLE_PRICE, EXP_ALLOC) VALUES($1, $2, $3, $4) "+
-			" ON CONFLICT(USER_ID, SECURITY_ID) DO UPDATE SET REASONABLE_PRICE = excluded.REASONABLE_PRICE, EXP_ALLOC =  excluded.EXP_ALLOC ",
-			userId, security.Securityid, reasonablePrice, expAlloc)
+		_, err := db.Exec("INSERT INTO USER_MODEL_PF(USER_ID, PORTFOLIO_ID, SECURITY_ID, REASONABLE_PRICE, EXP_ALLOC) VALUES($1, $2, $3, $4, $5) "+
+			" ON CONFLICT(PORTFOLIO_ID, SECURITY_ID) DO UPDATE SET REASONABLE_PRICE = excluded.REASONABLE_PRICE, EXP_ALLOC =  excluded.EXP_ALLOC ",
+			userId, portfolioId, security.Securityid, reasonablePrice, expAlloc)
 		if err != nil {
 			return err
 		}
@@ -394,12 +555,12 @@ func AddModelPortfolioDB(userHoldings ModelPortfolio, db *sql.DB) error {
 	return nil
 }
 
-func GetModelPortfolioDB(userid string, db *sql.DB) (ModelPortfolio, error) {
+func GetModelPortfolioDB(userid string, portfolioIds []int64, db *sql.DB) (ModelPortfolio, error) {
 	var modelPf ModelPortfolio
 	modelPf.UserID = userid
 
-	records, err := db.Query("SELECT SECURITY_ID, REASONABLE_PRICE, EXP_ALLOC FROM USER_MODEL_PF  "+
-		"WHERE USER_ID = $1", userid)
+	records, err := db.Query("SELECT PORTFOLIO_ID, SECURITY_ID, REASONABLE_PRICE, EXP_ALLOC FROM USER_MODEL_PF  "+
+		"WHERE PORTFOLIO_ID = ANY($1) ORDER BY PORTFOLIO_ID", pq.Array(portfolioIds))
 	if err != nil {
 		return modelPf, err
 	}
@@ -407,7 +568,7 @@ func GetModelPortfolioDB(userid string, db *sql.DB) (ModelPortfolio, error) {
 
 	for records.Next() {
 		var security Securities
-		err := records.Scan(&security.Securityid, &security.ReasonablePrice, &security.ExpectedAllocation)
+		err := records.Scan(&security.PortfolioId, &security.Securityid, &security.ReasonablePrice, &security.ExpectedAllocation)
 		if err != nil {
 			return modelPf, err
 		}
@@ -417,9 +578,9 @@ func GetModelPortfolioDB(userid string, db *sql.DB) (ModelPortfolio, error) {
 	return modelPf, nil
 }
 
-func GetTargetAmountDB(userid string, db *sql.DB) (float64, error) {
-	var targetAmount float64
-	records, err := db.Query("SELECT TARGET_AMOUNT FROM USERS WHERE USER_ID = $1 ", userid)
+func GetTargetAmountDB(portfolioId int64, db *sql.DB) (money.Decimal, error) {
+	var targetAmount money.Decimal
+	records, err := db.Query("SELECT COALESCE(TARGET_AMOUNT, 0) FROM PORTFOLIOS WHERE PORTFOLIO_ID = $1 ", portfolioId)
 	if err != nil {
 		return targetAmount, err
 	}
@@ -0,0 +1,93 @@
+package data
+
+import (
+	"database/sql"
+	"strconv"
+	"time"
+
+	"github.com/vijayyogesh/PortfolioApis/money"
+)
+
+/* Default portfolio created for every new user */
+const DefaultPortfolioName = "Default"
+
+type Portfolio struct {
+	PortfolioId   string `json:"portfolioId"`
+	UserId        string `json:"userId"`
+	PortfolioName string `json:"portfolioName"`
+	TargetAmount  string `json:"targetAmount"`
+	CreatedDate   string `json:"createdDate"`
+}
+
+type PortfolioInputJson struct {
+	UserID    string    `json:"userId"`
+	Portfolio Portfolio `json:"Portfolio"`
+}
+
+type PortfoliosOutputJson struct {
+	UserID     string      `json:"userId"`
+	Portfolios []Portfolio `json:"Portfolios"`
+}
+
+/* Insert portfolio as part of an open DB transaction and return generated id */
+func addPortfolioTx(userId string, portfolioName string, targetAmount money.Decimal, tx *sql.Tx) (int64, error) {
+	var portfolioId int64
+	err := tx.QueryRow("INSERT INTO PORTFOLIOS(USER_ID, PORTFOLIO_NAME, TARGET_AMOUNT, CREATED_DATE) VALUES($1, $2, $3, $4) RETURNING PORTFOLIO_ID ",
+		userId, portfolioName, targetAmount, time.Now()).Scan(&portfolioId)
+	return portfolioId, err
+}
+
+func AddPortfolioDB(userId string, portfolio Portfolio, db *sql.DB) (int64, error) {
+	targetAmount, parseErr := parseOptionalDecimal(portfolio.TargetAmount)
+	if parseErr != nil {
+		return 0, parseErr
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	portfolioId, err := addPortfolioTx(userId, portfolio.PortfolioName, targetAmount, tx)
+	if err != nil {
+		return 0, err
+	}
+	return portfolioId, tx.Commit()
+}
+
+/* Name and target amount are updated only when provided */
+func UpdatePortfolioDB(portfolioId int64, portfolio Portfolio, db *sql.DB) error {
+	targetAmount, parseErr := parseOptionalDecimal(portfolio.TargetAmount)
+	if parseErr != nil {
+		return parseErr
+	}
+	_, err := db.Exec("UPDATE PORTFOLIOS SET PORTFOLIO_NAME = COALESCE(NULLIF($1, ''), PORTFOLIO_NAME), TARGET_AMOUNT = COALESCE($2, TARGET_AMOUNT) "+
+		" WHERE PORTFOLIO_ID = $3 ", portfolio.PortfolioName, money.NullDecimal{Decimal: targetAmount, Valid: portfolio.TargetAmount != ""}, portfolioId)
+	return err
+}
+
+/* Fetch portfolios owned by user, oldest first */
+func GetPortfoliosDB(userid string, db *sql.DB) ([]Portfolio, error) {
+	var portfolios []Portfolio
+	records, err := db.Query("SELECT PORTFOLIO_ID, USER_ID, PORTFOLIO_NAME, TARGET_AMOUNT, CREATED_DATE FROM PORTFOLIOS "+
+		"WHERE USER_ID = $1 ORDER BY PORTFOLIO_ID", userid)
+	if err != nil {
+		return portfolios, err
+	}
+	defer records.Close()
+	for records.Next() {
+		var portfolio Portfolio
+		err := records.Scan(&portfolio.PortfolioId, &portfolio.UserId, &portfolio.PortfolioName, &portfolio.TargetAmount, &portfolio.CreatedDate)
+		if err != nil {
+			return portfolios, err
+		}
+		portfolios = append(portfolios, portfolio)
+	}
+	return portfolios, nil
+}
+
+/* Convert portfolio id from payload/DB to int */
+func ParsePortfolioId(portfolioId string) (int64, error) {
+	return strconv.ParseInt(portfolioId, 10, 64)
+}
@@ -0,0 +1,70 @@
+package data
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/vijayyogesh/PortfolioApis/money"
+)
+
+/* Corporate events published by price feed */
+const (
+	PriceEventDividend = "DIVIDEND"
+	PriceEventSplit    = "SPLIT"
+)
+
+/* Returns computed on close prices or on adjusted close which includes dividends and splits */
+const (
+	ReturnTypePrice = "price"
+	ReturnTypeTotal = "total"
+)
+
+/* Dividend per share in Amount, or split of SplitFrom shares into SplitTo shares */
+type PriceEvent struct {
+	CompanyId string
+	EventDate time.Time
+	EventType string
+	Amount    money.Decimal
+	SplitTo   int64
+	SplitFrom int64
+}
+
+/* Upsert events of feed within one transaction */
+func LoadPriceEventsDB(priceEvents []PriceEvent, db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, event := range priceEvents {
+		_, err := tx.Exec("INSERT INTO PRICE_EVENTS(COMPANY_ID, EVENT_DATE, EVENT_TYPE, AMOUNT, SPLIT_TO, SPLIT_FROM) "+
+			" VALUES($1, $2, $3, $4, NULLIF($5, 0), NULLIF($6, 0)) ON CONFLICT(COMPANY_ID, EVENT_DATE, EVENT_TYPE) "+
+			" DO UPDATE SET AMOUNT = excluded.AMOUNT, SPLIT_TO = excluded.SPLIT_TO, SPLIT_FROM = excluded.SPLIT_FROM ",
+			event.CompanyId, event.EventDate, event.EventType, event.Amount, event.SplitTo, event.SplitFrom)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+/* Events of a company ordered by date */
+func GetPriceEventsDB(companyId string, db *sql.DB) ([]PriceEvent, error) {
+	var priceEvents []PriceEvent
+	records, err := db.Query("SELECT COMPANY_ID, EVENT_DATE, EVENT_TYPE, COALESCE(AMOUNT, 0), COALESCE(SPLIT_TO, 0), COALESCE(SPLIT_FROM, 0) "+
+		" FROM PRICE_EVENTS WHERE COMPANY_ID = $1 ORDER BY EVENT_DATE ", companyId)
+	if err != nil {
+		return priceEvents, err
+	}
+	defer records.Close()
+	for records.Next() {
+		var event PriceEvent
+		err := records.Scan(&event.CompanyId, &event.EventDate, &event.EventType, &event.Amount, &event.SplitTo, &event.SplitFrom)
+		if err != nil {
+			return priceEvents, err
+		}
+		priceEvents = append(priceEvents, event)
+	}
+	return priceEvents, nil
+}
@@ -0,0 +1,96 @@
+package data
+
+import (
+	"database/sql"
+)
+
+/* Data quality flag types */
+const (
+	QualityFlagJump = "JUMP"
+	QualityFlagGap  = "GAP"
+)
+
+/* Finding on price history of a company. Gap flags are dated on first missing day of the range */
+type PriceQualityFlag struct {
+	CompanyId string `json:"companyId"`
+	DateVal   string `json:"date"`
+	FlagType  string `json:"flagType"`
+	Detail    string `json:"detail"`
+}
+
+type DataQualityInput struct {
+	UserID    string `json:"userId"`
+	Companyid string `json:"companyid"`
+}
+
+/* Missing trading days from From to To, both inclusive */
+type PriceGap struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Days string `json:"days"`
+}
+
+type PriceJump struct {
+	Date      string `json:"date"`
+	PrevClose string `json:"prevClose"`
+	Close     string `json:"close"`
+	ChangePct string `json:"changePct"`
+}
+
+type CompanyDataQuality struct {
+	CompanyId   string      `json:"companyId"`
+	FirstDate   string      `json:"firstDate"`
+	LastDate    string      `json:"lastDate"`
+	Rows        string      `json:"rows"`
+	MissingDays string      `json:"missingDays"`
+	Stale       bool        `json:"stale"`
+	Gaps        []PriceGap  `json:"gaps"`
+	Jumps       []PriceJump `json:"jumps"`
+}
+
+type DataQualityReport struct {
+	Companies []CompanyDataQuality `json:"Companies"`
+}
+
+/* Replace flags of a company with latest findings */
+func ReplacePriceQualityFlagsDB(companyId string, flags []PriceQualityFlag, db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("DELETE FROM PRICE_QUALITY_FLAGS WHERE COMPANY_ID = $1 ", companyId)
+	if err != nil {
+		return err
+	}
+	for _, flag := range flags {
+		_, err := tx.Exec("INSERT INTO PRICE_QUALITY_FLAGS(COMPANY_ID, DATE_VAL, FLAG_TYPE, DETAIL) VALUES($1, $2, $3, $4) "+
+			" ON CONFLICT(COMPANY_ID, DATE_VAL, FLAG_TYPE) DO UPDATE SET DETAIL = excluded.DETAIL ",
+			flag.CompanyId, flag.DateVal, flag.FlagType, flag.Detail)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+/* Close and adjusted close of a company ordered by date */
+func FetchPriceHistoryDB(companyId string, db *sql.DB) ([]CompaniesPriceData, error) {
+	var dailyPriceRecords []CompaniesPriceData
+	records, err := db.Query("SELECT DATE_VAL, CLOSE_VAL, COALESCE(ADJ_CLOSE_VAL, CLOSE_VAL) FROM COMPANIES_PRICE_DATA "+
+		" WHERE COMPANY_ID = $1 ORDER BY DATE_VAL ", companyId)
+	if err != nil {
+		return dailyPriceRecords, err
+	}
+	defer records.Close()
+	for records.Next() {
+		dailyRecord := CompaniesPriceData{CompanyId: companyId}
+		err := records.Scan(&dailyRecord.DateVal, &dailyRecord.CloseVal, &dailyRecord.AdjCloseVal)
+		if err != nil {
+			return dailyPriceRecords, err
+		}
+		dailyPriceRecords = append(dailyPriceRecords, dailyRecord)
+	}
+	return dailyPriceRecords, nil
+}
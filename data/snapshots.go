@@ -0,0 +1,147 @@
+package data
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/vijayyogesh/PortfolioApis/money"
+)
+
+/* Valuation of consolidated portfolio of a user on a day. Values not available on the day are not Valid */
+type PortfolioSnapshot struct {
+	SnapshotDate   string
+	MarketValue    money.NullDecimal
+	Invested       money.NullDecimal
+	EquityValue    money.NullDecimal
+	DebtValue      money.NullDecimal
+	BenchmarkValue money.NullDecimal
+	Xirr           sql.NullFloat64
+	BenchmarkXirr  sql.NullFloat64
+}
+
+/* Version of snapshots of user, incremented on invalidation */
+func GetSnapshotVersionDB(userId string, db *sql.DB) (int64, error) {
+	var version int64
+	err := db.QueryRow("SELECT VERSION FROM PORTFOLIO_SNAPSHOT_VERSIONS WHERE USER_ID = $1", userId).Scan(&version)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return version, err
+}
+
+/* Save snapshots computed at version. Nothing is saved when snapshots were invalidated meanwhile */
+func SavePortfolioSnapshotsDB(userId string, version int64, snapshots []PortfolioSnapshot, db *sql.DB) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	/* Row lock makes invalidation wait till snapshots are saved */
+	_, err = tx.Exec("INSERT INTO PORTFOLIO_SNAPSHOT_VERSIONS(USER_ID) VALUES($1) ON CONFLICT(USER_ID) DO NOTHING", userId)
+	if err != nil {
+		return false, err
+	}
+	var currentVersion int64
+	err = tx.QueryRow("SELECT VERSION FROM PORTFOLIO_SNAPSHOT_VERSIONS WHERE USER_ID = $1 FOR UPDATE", userId).Scan(&currentVersion)
+	if err != nil {
+		return false, err
+	}
+	if currentVersion != version {
+		return false, nil
+	}
+
+	for _, snapshot := range snapshots {
+		_, err := tx.Exec("INSERT INTO PORTFOLIO_SNAPSHOTS(USER_ID, SNAPSHOT_DATE, MARKET_VALUE, INVESTED, EQUITY_VALUE, DEBT_VALUE, BENCHMARK_VALUE, "+
+			" XIRR, BENCHMARK_XIRR, COMPUTED_AT) VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, NOW()) "+
+			" ON CONFLICT(USER_ID, SNAPSHOT_DATE) DO UPDATE SET MARKET_VALUE = EXCLUDED.MARKET_VALUE, INVESTED = EXCLUDED.INVESTED, "+
+			" EQUITY_VALUE = EXCLUDED.EQUITY_VALUE, DEBT_VALUE = EXCLUDED.DEBT_VALUE, BENCHMARK_VALUE = EXCLUDED.BENCHMARK_VALUE, "+
+			" XIRR = EXCLUDED.XIRR, BENCHMARK_XIRR = EXCLUDED.BENCHMARK_XIRR, COMPUTED_AT = NOW() ",
+			userId, snapshot.SnapshotDate, snapshot.MarketValue, snapshot.Invested, snapshot.EquityValue, snapshot.DebtValue,
+			snapshot.BenchmarkValue, snapshot.Xirr, snapshot.BenchmarkXirr)
+		if err != nil {
+			return false, err
+		}
+	}
+	return true, tx.Commit()
+}
+
+/* Snapshots of user in date order */
+func GetPortfolioSnapshotsDB(userId string, db *sql.DB) ([]PortfolioSnapshot, error) {
+	var snapshots []PortfolioSnapshot
+	records, err := db.Query("SELECT TO_CHAR(SNAPSHOT_DATE, 'YYYY-MM-DD'), MARKET_VALUE, INVESTED, EQUITY_VALUE, DEBT_VALUE, BENCHMARK_VALUE, "+
+		" XIRR, BENCHMARK_XIRR FROM PORTFOLIO_SNAPSHOTS WHERE USER_ID = $1 ORDER BY SNAPSHOT_DATE ", userId)
+	if err != nil {
+		return snapshots, err
+	}
+	defer records.Close()
+	for records.Next() {
+		var snapshot PortfolioSnapshot
+		err := records.Scan(&snapshot.SnapshotDate, &snapshot.MarketValue, &snapshot.Invested, &snapshot.EquityValue, &snapshot.DebtValue,
+			&snapshot.BenchmarkValue, &snapshot.Xirr, &snapshot.BenchmarkXirr)
+		if err != nil {
+			return snapshots, err
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots, nil
+}
+
+/* Last snapshot date of user, zero when there are no snapshots */
+func GetLastSnapshotDateDB(userId string, db *sql.DB) (time.Time, error) {
+	var lastDate sql.NullTime
+	err := db.QueryRow("SELECT MAX(SNAPSHOT_DATE) FROM PORTFOLIO_SNAPSHOTS WHERE USER_ID = $1", userId).Scan(&lastDate)
+	if err != nil || !lastDate.Valid {
+		return time.Time{}, err
+	}
+	return lastDate.Time, nil
+}
+
+/* Delete snapshots of user from date, as holdings changed on it */
+func InvalidatePortfolioSnapshotsDB(userId string, fromDate time.Time, db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("INSERT INTO PORTFOLIO_SNAPSHOT_VERSIONS(USER_ID, VERSION) VALUES($1, 1) "+
+		" ON CONFLICT(USER_ID) DO UPDATE SET VERSION = PORTFOLIO_SNAPSHOT_VERSIONS.VERSION + 1 ", userId)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("DELETE FROM PORTFOLIO_SNAPSHOTS WHERE USER_ID = $1 AND SNAPSHOT_DATE >= $2", userId, fromDate)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+/* Owner of a portfolio holding a company, with first transaction date of the company across portfolios of owner */
+type CompanyHolder struct {
+	UserId       string
+	CompanyId    string
+	FirstTxnDate time.Time
+}
+
+/* Users whose portfolios have transactions in any of the companies */
+func GetCompanyHoldersDB(companyIds []string, db *sql.DB) ([]CompanyHolder, error) {
+	var holders []CompanyHolder
+	records, err := db.Query("SELECT P.USER_ID, TXN.COMPANY_ID, MIN(TXN.TXN_DATE) FROM USER_TRANSACTIONS TXN "+
+		" JOIN PORTFOLIOS P ON P.PORTFOLIO_ID = TXN.PORTFOLIO_ID WHERE TXN.COMPANY_ID = ANY($1) "+
+		" GROUP BY P.USER_ID, TXN.COMPANY_ID ", pq.Array(companyIds))
+	if err != nil {
+		return holders, err
+	}
+	defer records.Close()
+	for records.Next() {
+		var holder CompanyHolder
+		err := records.Scan(&holder.UserId, &holder.CompanyId, &holder.FirstTxnDate)
+		if err != nil {
+			return holders, err
+		}
+		holders = append(holders, holder)
+	}
+	return holders, records.Err()
+}
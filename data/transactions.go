@@ -0,0 +1,135 @@
+package data
+
+import (
+	"database/sql"
+
+	"github.com/lib/pq"
+	"github.com/vijayyogesh/PortfolioApis/money"
+)
+
+/* Ledger transaction types */
+const (
+	TxnTypeBuy      = "BUY"
+	TxnTypeSell     = "SELL"
+	TxnTypeDividend = "DIVIDEND"
+	TxnTypeSplit    = "SPLIT"
+)
+
+/* Single ledger entry. Quantity is always positive, TxnType decides the direction.
+** BUY/SELL     - Quantity units at Price per unit
+** DIVIDEND     - Quantity units entitled at Price (dividend) per unit
+** SPLIT        - Quantity is the ratio of new units per old unit, Price is ignored. Entries derived from corporate
+**                actions keep it as new/old (eg: 4/3) when the ratio has no exact decimal */
+type Transaction struct {
+	TransactionId  string `json:"transactionId"`
+	PortfolioId    string `json:"portfolioId"`
+	Companyid      string `json:"companyid"`
+	CompanyName    string `json:"companyName"`
+	TxnType        string `json:"txnType"`
+	Quantity       string `json:"quantity"`
+	Price          string `json:"price"`
+	Fees           string `json:"fees"`
+	Brokerage      string `json:"brokerage"`
+	Taxes          string `json:"taxes"`
+	TxnDate        string `json:"txnDate"`
+	SettlementDate string `json:"settlementDate"`
+	Notes          string `json:"notes"`
+}
+
+type TransactionsOutputJson struct {
+	UserID       string        `json:"userId"`
+	PortfolioId  string        `json:"portfolioId"`
+	Transactions []Transaction `json:"Transactions"`
+}
+
+/* Insert ledger entries as part of an open DB transaction */
+func addUserTransactionsTx(userId string, portfolioId int64, transactions []Transaction, tx *sql.Tx) error {
+	for _, txn := range transactions {
+		quantity, parseErr := money.Parse(txn.Quantity)
+		if parseErr != nil {
+			return parseErr
+		}
+		price, parseErr := parseOptionalDecimal(txn.Price)
+		if parseErr != nil {
+			return parseErr
+		}
+		fees, parseErr := parseOptionalDecimal(txn.Fees)
+		if parseErr != nil {
+			return parseErr
+		}
+		brokerage, parseErr := parseOptionalDecimal(txn.Brokerage)
+		if parseErr != nil {
+			return parseErr
+		}
+		taxes, parseErr := parseOptionalDecimal(txn.Taxes)
+		if parseErr != nil {
+			return parseErr
+		}
+
+		/* Settlement date defaults to the trade date */
+		settlementDate := txn.SettlementDate
+		if settlementDate == "" {
+			settlementDate = txn.TxnDate
+		}
+
+		_, err := tx.Exec("INSERT INTO USER_TRANSACTIONS(USER_ID, PORTFOLIO_ID, COMPANY_ID, TXN_TYPE, QUANTITY, PRICE, FEES, BROKERAGE, TAXES, TXN_DATE, SETTLEMENT_DATE, NOTES) "+
+			"VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) ",
+			userId, portfolioId, txn.Companyid, txn.TxnType, quantity, price, fees, brokerage, taxes, txn.TxnDate, settlementDate, txn.Notes)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/* Fetch complete ledger for given portfolios ordered by trade date */
+func GetUserTransactionsDB(portfolioIds []int64, db *sql.DB) ([]Transaction, error) {
+	var transactions []Transaction
+
+	records, err := db.Query("SELECT TXN.TRANSACTION_ID, TXN.PORTFOLIO_ID, TXN.COMPANY_ID, COALESCE(COMPANIES.COMPANY_NAME, ''), TXN.TXN_TYPE, TXN.QUANTITY, TXN.PRICE, "+
+		"TXN.FEES, TXN.BROKERAGE, TXN.TAXES, TXN.TXN_DATE, COALESCE(TXN.SETTLEMENT_DATE, TXN.TXN_DATE), COALESCE(TXN.NOTES, '') "+
+		"FROM USER_TRANSACTIONS TXN LEFT JOIN COMPANIES COMPANIES ON TXN.COMPANY_ID = COMPANIES.COMPANY_ID "+
+		"WHERE TXN.PORTFOLIO_ID = ANY($1) ORDER BY TXN.TXN_DATE, TXN.TRANSACTION_ID", pq.Array(portfolioIds))
+	if err != nil {
+		return transactions, err
+	}
+	defer records.Close()
+
+	for records.Next() {
+		var txn Transaction
+		err := records.Scan(&txn.TransactionId, &txn.PortfolioId, &txn.Companyid, &txn.CompanyName, &txn.TxnType, &txn.Quantity, &txn.Price,
+			&txn.Fees, &txn.Brokerage, &txn.Taxes, &txn.TxnDate, &txn.SettlementDate, &txn.Notes)
+		if err != nil {
+			return transactions, err
+		}
+		transactions = append(transactions, txn)
+	}
+	return transactions, nil
+}
+
+/* Companies traded in any portfolio */
+func GetTradedCompanyIdsDB(db *sql.DB) ([]string, error) {
+	var companyIds []string
+	records, err := db.Query("SELECT DISTINCT COMPANY_ID FROM USER_TRANSACTIONS ")
+	if err != nil {
+		return companyIds, err
+	}
+	defer records.Close()
+	for records.Next() {
+		var companyId string
+		err := records.Scan(&companyId)
+		if err != nil {
+			return companyIds, err
+		}
+		companyIds = append(companyIds, companyId)
+	}
+	return companyIds, nil
+}
+
+/* Empty optional amounts are treated as zero */
+func parseOptionalDecimal(val string) (money.Decimal, error) {
+	if val == "" {
+		return money.Zero, nil
+	}
+	return money.Parse(val)
+}
@@ -0,0 +1,95 @@
+package data
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+/* Company in an index/universe from EffectiveFrom till EffectiveTo, EffectiveTo is empty while it is a member */
+type IndexMember struct {
+	IndexId       string `json:"indexId"`
+	CompanyId     string `json:"companyId"`
+	CompanyName   string `json:"companyName"`
+	Industry      string `json:"industry"`
+	Isin          string `json:"isin"`
+	EffectiveFrom string `json:"effectiveFrom"`
+	EffectiveTo   string `json:"effectiveTo"`
+}
+
+/* Constituents csv uploaded by admin as a custom universe */
+type UniverseInputJson struct {
+	UserID  string `json:"userId"`
+	IndexId string `json:"indexid"`
+	Csv     string `json:"csv"`
+}
+
+type IndexMembersInputJson struct {
+	UserID  string `json:"userId"`
+	IndexId string `json:"indexid"`
+	Date    string `json:"date"`
+}
+
+type IndexMembersOutputJson struct {
+	IndexId string        `json:"indexId"`
+	Date    string        `json:"date"`
+	Members []IndexMember `json:"Members"`
+}
+
+/* Make companyIds the members of index from asOf. Members not in list leave the index on asOf */
+func LoadIndexMembershipDB(indexId string, companyIds []string, asOf time.Time, db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("UPDATE INDEX_MEMBERSHIP SET EFFECTIVE_TO = $1 WHERE INDEX_ID = $2 AND EFFECTIVE_TO IS NULL "+
+		" AND NOT (COMPANY_ID = ANY($3)) ", asOf, indexId, pq.Array(companyIds))
+	if err != nil {
+		return err
+	}
+
+	/* A company which left on asOf and is back in list stays a member */
+	_, err = tx.Exec("UPDATE INDEX_MEMBERSHIP SET EFFECTIVE_TO = NULL WHERE INDEX_ID = $1 AND EFFECTIVE_TO = $2 "+
+		" AND COMPANY_ID = ANY($3) ", indexId, asOf, pq.Array(companyIds))
+	if err != nil {
+		return err
+	}
+
+	for _, companyId := range companyIds {
+		_, err := tx.Exec("INSERT INTO INDEX_MEMBERSHIP(INDEX_ID, COMPANY_ID, EFFECTIVE_FROM) SELECT $1, $2, $3 "+
+			" WHERE NOT EXISTS (SELECT 1 FROM INDEX_MEMBERSHIP WHERE INDEX_ID = $1 AND COMPANY_ID = $2 AND EFFECTIVE_TO IS NULL) "+
+			" ON CONFLICT(INDEX_ID, COMPANY_ID, EFFECTIVE_FROM) DO UPDATE SET EFFECTIVE_TO = NULL ",
+			indexId, companyId, asOf)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+/* Members of index on a date */
+func GetIndexMembersDB(indexId string, asOf time.Time, db *sql.DB) ([]IndexMember, error) {
+	var members []IndexMember
+	records, err := db.Query("SELECT MEMBERS.INDEX_ID, MEMBERS.COMPANY_ID, COALESCE(COMP.COMPANY_NAME, ''), COALESCE(COMP.INDUSTRY, ''), "+
+		" COALESCE(COMP.ISIN, ''), TO_CHAR(MEMBERS.EFFECTIVE_FROM, 'YYYY-MM-DD'), COALESCE(TO_CHAR(MEMBERS.EFFECTIVE_TO, 'YYYY-MM-DD'), '') "+
+		" FROM INDEX_MEMBERSHIP MEMBERS LEFT JOIN COMPANIES COMP ON MEMBERS.COMPANY_ID = COMP.COMPANY_ID "+
+		" WHERE MEMBERS.INDEX_ID = $1 AND MEMBERS.EFFECTIVE_FROM <= $2 AND (MEMBERS.EFFECTIVE_TO IS NULL OR MEMBERS.EFFECTIVE_TO > $2) "+
+		" ORDER BY MEMBERS.COMPANY_ID ", indexId, asOf)
+	if err != nil {
+		return members, err
+	}
+	defer records.Close()
+	for records.Next() {
+		var member IndexMember
+		err := records.Scan(&member.IndexId, &member.CompanyId, &member.CompanyName, &member.Industry, &member.Isin,
+			&member.EffectiveFrom, &member.EffectiveTo)
+		if err != nil {
+			return members, err
+		}
+		members = append(members, member)
+	}
+	return members, nil
+}
@@ -8,7 +8,10 @@ require github.com/robfig/cron/v3 v3.0.0
 
 require github.com/dgrijalva/jwt-go v3.2.0+incompatible
 
-require github.com/alpeb/go-finance v0.0.0-20211202201625-e4f601ef4382
+require (
+	github.com/alpeb/go-finance v0.0.0-20211202201625-e4f601ef4382
+	github.com/spf13/viper v1.10.1
+)
 
 require (
 	github.com/fsnotify/fsnotify v1.5.1 // indirect
@@ -20,7 +23,6 @@ require (
 	github.com/spf13/cast v1.4.1 // indirect
 	github.com/spf13/jwalterweatherman v1.1.0 // indirect
 	github.com/spf13/pflag v1.0.5 // indirect
-	github.com/spf13/viper v1.10.1 // indirect
 	github.com/subosito/gotenv v1.2.0 // indirect
 	golang.org/x/sys v0.0.0-20211210111614-af8b64212486 // indirect
 	golang.org/x/text v0.3.7 // indirect
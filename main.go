@@ -4,10 +4,10 @@ import (
 	"fmt"
 	"net/http"
 
-	"github.com/robfig/cron/v3"
 	"github.com/vijayyogesh/PortfolioApis/constants"
 	"github.com/vijayyogesh/PortfolioApis/controllers"
 	"github.com/vijayyogesh/PortfolioApis/processor"
+	"github.com/vijayyogesh/PortfolioApis/scheduler"
 	"github.com/vijayyogesh/PortfolioApis/util"
 
 	_ "github.com/lib/pq"
@@ -43,6 +43,37 @@ func main() {
 	http.Handle(constants.AppRouteCalculateIndexSIPReturn, *appC)
 	http.Handle(constants.AppRouteCalculateATHforPF, *appC)
 	http.Handle(constants.AppRouteCalculateXirrReturn, *appC)
+	http.Handle(constants.AppRouteGetUserTransactions, *appC)
+	http.Handle(constants.AppRouteAddPortfolio, *appC)
+	http.Handle(constants.AppRouteUpdatePortfolio, *appC)
+	http.Handle(constants.AppRouteGetPortfolios, *appC)
+	http.Handle(constants.AppRouteSharePortfolio, *appC)
+	http.Handle(constants.AppRouteRevokePortfolioShare, *appC)
+	http.Handle(constants.AppRouteGetPortfolioShares, *appC)
+	http.Handle(constants.AppRouteHouseholdNetworth, *appC)
+	http.Handle(constants.AppRouteAddInstruments, *appC)
+	http.Handle(constants.AppRouteGetInstruments, *appC)
+	http.Handle(constants.AppRouteAddCorporateActions, *appC)
+	http.Handle(constants.AppRouteGetCorporateActions, *appC)
+	http.Handle(constants.AppRouteDataQualityReport, *appC)
+	http.Handle(constants.AppRouteBackfillGaps, *appC)
+	http.Handle(constants.AppRouteGetIngestionJob, *appC)
+	http.Handle(constants.AppRouteGetIngestionJobs, *appC)
+	http.Handle(constants.AppRouteUploadUniverse, *appC)
+	http.Handle(constants.AppRouteGetIndexMembers, *appC)
+	http.Handle(constants.AppRouteSearchCompanies, *appC)
+	http.Handle(constants.AppRouteGetCacheStats, *appC)
+	http.Handle(constants.AppRouteCalculateNavReturn, *appC)
+	http.Handle(constants.AppRouteCalculateTwrReturn, *appC)
+
+	/* Typed v2 API for all routes above */
+	http.Handle(constants.AppRouteV2Prefix+"/", *appC)
+
+	/* Offline stand-in for Yahoo/NSE serving fixture files */
+	if appUtil.Config.StandInPort != 0 {
+		go http.ListenAndServe(fmt.Sprintf(":%d", appUtil.Config.StandInPort), processor.NewStandInServer(appUtil.Config.FixtureDir))
+		appUtil.AppLogger.Println("Stand-in price server listening in Port = ", appUtil.Config.StandInPort)
+	}
 
 	appUtil.AppLogger.Println("----- STARTED PORTFOLIO APIS -----")
 
@@ -59,16 +90,58 @@ func init() {
 
 	processor.InitProcessor(appC.AppUtil)
 
+	/* Exchange holidays used by scheduling and analytics */
+	errCalendar := processor.LoadCalendars()
+	if errCalendar != nil {
+		appUtil.AppLogger.Println(errCalendar)
+	}
+
+	/* Jobs of previous run can not complete anymore */
+	errJobs := processor.FailInterruptedIngestionJobs()
+	if errJobs != nil {
+		appUtil.AppLogger.Println(errJobs)
+	}
+
 	/* Start scheduled jobs */
 	startCronJobs()
 }
 
+/* Register background jobs on schedules from config.
+** There is no alerts job yet as alert rules and their delivery do not exist, a configured alerts schedule is ignored */
 func startCronJobs() {
-	cronJob := cron.New()
-	cronJob.AddFunc("@hourly", func() {
-		msg := processor.FetchAndUpdatePrices(appUtil.Db)
-		appUtil.AppLogger.Println(msg)
-	})
-	cronJob.Start()
+	schedules, err := scheduler.ParseSchedules(appUtil.Config.JobSchedules)
+	if err != nil {
+		appUtil.AppLogger.Println(err)
+	}
+	scheduleOf := func(job string, defaultSchedule string) string {
+		if schedule, isPresent := schedules[job]; isPresent {
+			return schedule
+		}
+		return defaultSchedule
+	}
+
+	jobScheduler := scheduler.New(appUtil.Db, appUtil.AppLogger)
+	jobs := []struct {
+		name     string
+		schedule string
+		run      func() string
+	}{
+		{constants.AppJobPriceRefresh, constants.AppJobPriceRefreshSchedule, func() string { return processor.FetchAndUpdatePrices(appUtil.Db) }},
+		{constants.AppJobMasterListRefresh, constants.AppJobMasterListRefreshSchedule, processor.FetchAndUpdateCompaniesMasterList},
+		{constants.AppJobCacheWarmup, constants.AppJobCacheWarmupSchedule, processor.WarmupCaches},
+		{constants.AppJobSnapshots, constants.AppJobSnapshotsSchedule, processor.RefreshPortfolioSnapshots},
+	}
+	for _, job := range jobs {
+		schedule := scheduleOf(job.name, job.schedule)
+		delete(schedules, job.name)
+		err := jobScheduler.Register(job.name, schedule, job.run)
+		if err != nil {
+			appUtil.AppLogger.Println(err)
+		}
+	}
+	for name := range schedules {
+		appUtil.AppLogger.Println("Ignoring schedule of unknown job " + name)
+	}
+	jobScheduler.Start()
 	appUtil.AppLogger.Println("Scheduled Cron Jobs")
 }
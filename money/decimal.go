@@ -0,0 +1,401 @@
+package money
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+/* Number of fractional digits held by Decimal.
+** Values are stored as int64 units of 10^-6, giving a range of about +/-9.2 trillion
+** which is well above any price, quantity or networth handled by the app. */
+const Scale = 6
+
+/* Rounding rules applied at the API boundary (half away from zero) */
+const (
+	AmountPlaces   = 2
+	PricePlaces    = 2
+	QuantityPlaces = 4
+	PercentPlaces  = 2
+)
+
+const scaleFactor int64 = 1000000
+
+var pow10 = [...]int64{1, 10, 100, 1000, 10000, 100000, 1000000}
+
+/* Fixed point decimal used for money and quantities. Zero value is 0 */
+type Decimal struct {
+	units int64
+}
+
+var Zero = Decimal{}
+
+/* -------------------------------------- */
+/* CONSTRUCTORS */
+
+func NewFromInt(val int64) Decimal {
+	if val > math.MaxInt64/scaleFactor || val < math.MinInt64/scaleFactor {
+		panic(fmt.Sprintf("money: %d overflows decimal range", val))
+	}
+	return Decimal{val * scaleFactor}
+}
+
+/* Float conversion rounds to Scale digits. NaN/Inf are treated as zero */
+func NewFromFloat(val float64) Decimal {
+	if math.IsNaN(val) || math.IsInf(val, 0) {
+		return Zero
+	}
+	d, err := Parse(strconv.FormatFloat(val, 'f', Scale, 64))
+	if err != nil {
+		panic(err.Error())
+	}
+	return d
+}
+
+/* Parse plain decimal strings like "-1234.5678". Digits beyond Scale are rounded */
+func Parse(val string) (Decimal, error) {
+	str := strings.TrimSpace(val)
+	if str == "" {
+		return Zero, fmt.Errorf("money: cannot parse empty value")
+	}
+
+	/* Exponent notation is rare (user input) - go via float */
+	if strings.ContainsAny(str, "eE") {
+		floatVal, err := strconv.ParseFloat(str, 64)
+		if err != nil || math.IsNaN(floatVal) || math.IsInf(floatVal, 0) {
+			return Zero, fmt.Errorf("money: cannot parse %q", val)
+		}
+		if math.Abs(floatVal) >= float64(math.MaxInt64/scaleFactor) {
+			return Zero, fmt.Errorf("money: %q overflows decimal range", val)
+		}
+		return NewFromFloat(floatVal), nil
+	}
+
+	negative := false
+	if str[0] == '-' || str[0] == '+' {
+		negative = str[0] == '-'
+		str = str[1:]
+	}
+
+	intPart, fracPart := str, ""
+	if idx := strings.IndexByte(str, '.'); idx >= 0 {
+		intPart, fracPart = str[:idx], str[idx+1:]
+	}
+	if intPart == "" && fracPart == "" {
+		return Zero, fmt.Errorf("money: cannot parse %q", val)
+	}
+	if !isDigits(intPart) || !isDigits(fracPart) {
+		return Zero, fmt.Errorf("money: cannot parse %q", val)
+	}
+
+	var intVal uint64
+	if intPart != "" {
+		var err error
+		intVal, err = strconv.ParseUint(intPart, 10, 64)
+		if err != nil || intVal > uint64(math.MaxInt64/scaleFactor) {
+			return Zero, fmt.Errorf("money: %q overflows decimal range", val)
+		}
+	}
+
+	/* Round half away from zero on the first dropped digit */
+	roundUp := len(fracPart) > Scale && fracPart[Scale] >= '5'
+	if len(fracPart) > Scale {
+		fracPart = fracPart[:Scale]
+	}
+	fracPart = fracPart + strings.Repeat("0", Scale-len(fracPart))
+	fracVal, _ := strconv.ParseUint(fracPart, 10, 64)
+
+	units := intVal*uint64(scaleFactor) + fracVal
+	if roundUp {
+		units++
+	}
+	if units > math.MaxInt64 {
+		return Zero, fmt.Errorf("money: %q overflows decimal range", val)
+	}
+
+	if negative {
+		return Decimal{-int64(units)}, nil
+	}
+	return Decimal{int64(units)}, nil
+}
+
+func isDigits(str string) bool {
+	for _, ch := range str {
+		if ch < '0' || ch > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+/* -------------------------------------- */
+/* ARITHMETIC */
+
+func (d Decimal) Add(other Decimal) Decimal {
+	sum := d.units + other.units
+	if (d.units > 0 && other.units > 0 && sum < 0) || (d.units < 0 && other.units < 0 && sum >= 0) {
+		panic("money: decimal overflow in Add")
+	}
+	return Decimal{sum}
+}
+
+func (d Decimal) Sub(other Decimal) Decimal {
+	return d.Add(other.Neg())
+}
+
+func (d Decimal) Neg() Decimal {
+	return Decimal{-d.units}
+}
+
+func (d Decimal) Abs() Decimal {
+	if d.units < 0 {
+		return d.Neg()
+	}
+	return d
+}
+
+func (d Decimal) Mul(other Decimal) Decimal {
+	return Decimal{mulDivRound(d.units, other.units, scaleFactor)}
+}
+
+/* Division rounds half away from zero. Panics on zero divisor like integer division */
+func (d Decimal) Div(other Decimal) Decimal {
+	return Decimal{mulDivRound(d.units, scaleFactor, other.units)}
+}
+
+/* d * num / den with a 128 bit intermediate, so large products do not overflow */
+func (d Decimal) MulDiv(num Decimal, den Decimal) Decimal {
+	return Decimal{mulDivRound(d.units, num.units, den.units)}
+}
+
+func (d Decimal) MulInt(val int64) Decimal {
+	return Decimal{mulDivRound(d.units, val, 1)}
+}
+
+/* Percentage of part over whole, zero when whole is zero */
+func Percent(part Decimal, whole Decimal) Decimal {
+	if whole.IsZero() {
+		return Zero
+	}
+	return part.MulDiv(NewFromInt(100), whole)
+}
+
+/* Computes a*b/c rounded half away from zero */
+func mulDivRound(a int64, b int64, c int64) int64 {
+	if c == 0 {
+		panic("money: division by zero")
+	}
+	negative := (a < 0) != (b < 0) != (c < 0)
+	ua, ub, uc := absUint(a), absUint(b), absUint(c)
+
+	hi, lo := bits.Mul64(ua, ub)
+	if hi >= uc {
+		panic("money: decimal overflow")
+	}
+	quo, rem := bits.Div64(hi, lo, uc)
+	if rem >= uc-rem {
+		quo++
+	}
+	if quo > math.MaxInt64 {
+		panic("money: decimal overflow")
+	}
+
+	if negative {
+		return -int64(quo)
+	}
+	return int64(quo)
+}
+
+func absUint(val int64) uint64 {
+	if val < 0 {
+		return uint64(-val)
+	}
+	return uint64(val)
+}
+
+/* -------------------------------------- */
+/* COMPARISON */
+
+func (d Decimal) Cmp(other Decimal) int {
+	if d.units < other.units {
+		return -1
+	} else if d.units > other.units {
+		return 1
+	}
+	return 0
+}
+
+func (d Decimal) Sign() int {
+	return d.Cmp(Zero)
+}
+
+func (d Decimal) IsZero() bool {
+	return d.units == 0
+}
+
+func (d Decimal) Equal(other Decimal) bool {
+	return d.units == other.units
+}
+
+func (d Decimal) GreaterThan(other Decimal) bool {
+	return d.units > other.units
+}
+
+func (d Decimal) LessThan(other Decimal) bool {
+	return d.units < other.units
+}
+
+/* -------------------------------------- */
+/* ROUNDING & FORMATTING */
+
+/* Round to given fractional places, half away from zero */
+func (d Decimal) Round(places int) Decimal {
+	if places >= Scale {
+		return d
+	}
+	if places < 0 {
+		places = 0
+	}
+	factor := pow10[Scale-places]
+	quo, rem := d.units/factor, d.units%factor
+	if rem < 0 {
+		rem = -rem
+	}
+	if rem*2 >= factor {
+		if d.units < 0 {
+			quo--
+		} else {
+			quo++
+		}
+	}
+	return Decimal{quo * factor}
+}
+
+/* Rounded value with exactly given fractional places */
+func (d Decimal) StringFixed(places int) string {
+	if places > Scale {
+		places = Scale
+	}
+	rounded := d.Round(places)
+
+	sign := ""
+	if rounded.units < 0 {
+		sign = "-"
+	}
+	abs := absUint(rounded.units)
+	intPart := abs / uint64(scaleFactor)
+	if places == 0 {
+		return sign + strconv.FormatUint(intPart, 10)
+	}
+	fracPart := fmt.Sprintf("%06d", abs%uint64(scaleFactor))
+	return sign + strconv.FormatUint(intPart, 10) + "." + fracPart[:places]
+}
+
+/* Full precision without trailing zeros */
+func (d Decimal) String() string {
+	str := d.StringFixed(Scale)
+	str = strings.TrimRight(str, "0")
+	return strings.TrimSuffix(str, ".")
+}
+
+/* Float only for statistical routines like XIRR and chart output */
+func (d Decimal) Float64() float64 {
+	return float64(d.units) / float64(scaleFactor)
+}
+
+func FormatAmount(d Decimal) string {
+	return d.StringFixed(AmountPlaces)
+}
+
+func FormatPrice(d Decimal) string {
+	return d.StringFixed(PricePlaces)
+}
+
+func FormatPercent(d Decimal) string {
+	return d.StringFixed(PercentPlaces)
+}
+
+/* Quantities drop trailing zeros so whole units print as integers */
+func FormatQuantity(d Decimal) string {
+	return d.Round(QuantityPlaces).String()
+}
+
+/* -------------------------------------- */
+/* DB & JSON */
+
+/* Implements sql.Scanner for numeric columns */
+func (d *Decimal) Scan(src interface{}) error {
+	switch val := src.(type) {
+	case nil:
+		*d = Zero
+	case []byte:
+		parsed, err := Parse(string(val))
+		if err != nil {
+			return err
+		}
+		*d = parsed
+	case string:
+		parsed, err := Parse(val)
+		if err != nil {
+			return err
+		}
+		*d = parsed
+	case float64:
+		*d = NewFromFloat(val)
+	case int64:
+		*d = NewFromInt(val)
+	default:
+		return fmt.Errorf("money: cannot scan %T into Decimal", src)
+	}
+	return nil
+}
+
+/* Implements driver.Valuer, sent as text to keep numeric precision */
+func (d Decimal) Value() (driver.Value, error) {
+	return d.String(), nil
+}
+
+/* Decimal of a nullable numeric column, Valid is false for NULL */
+type NullDecimal struct {
+	Decimal Decimal
+	Valid   bool
+}
+
+func (n *NullDecimal) Scan(src interface{}) error {
+	if src == nil {
+		n.Decimal, n.Valid = Zero, false
+		return nil
+	}
+	n.Valid = true
+	return n.Decimal.Scan(src)
+}
+
+func (n NullDecimal) Value() (driver.Value, error) {
+	if !n.Valid {
+		return nil, nil
+	}
+	return n.Decimal.Value()
+}
+
+/* Encoded as a JSON number */
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+/* Accepts both JSON numbers and quoted strings */
+func (d *Decimal) UnmarshalJSON(val []byte) error {
+	str := strings.Trim(string(val), "\"")
+	if str == "null" || str == "" {
+		*d = Zero
+		return nil
+	}
+	parsed, err := Parse(str)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
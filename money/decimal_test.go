@@ -0,0 +1,157 @@
+package money
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{"0", "0", false},
+		{"1234.5678", "1234.5678", false},
+		{"-1234.5678", "-1234.5678", false},
+		{"+12", "12", false},
+		{" 7.25 ", "7.25", false},
+		{".5", "0.5", false},
+		{"5.", "5", false},
+		{"0.0000004", "0", false},
+		{"0.0000005", "0.000001", false},
+		{"-0.0000005", "-0.000001", false},
+		{"1.2345675", "1.234568", false},
+		{"1e3", "1000", false},
+		{"2.5E-2", "0.025", false},
+		{"9223372036854", "9223372036854", false},
+		{"9223372036855", "", true},
+		{"1e20", "", true},
+		{"", "", true},
+		{"-", "", true},
+		{".", "", true},
+		{"1.2.3", "", true},
+		{"12a", "", true},
+		{"NaN", "", true},
+	}
+	for _, test := range tests {
+		got, err := Parse(test.input)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("Parse(%q) = %s, want error", test.input, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Parse(%q) error %v", test.input, err)
+			continue
+		}
+		if got.String() != test.want {
+			t.Errorf("Parse(%q) = %s, want %s", test.input, got, test.want)
+		}
+	}
+}
+
+func TestRound(t *testing.T) {
+	tests := []struct {
+		input  string
+		places int
+		want   string
+	}{
+		{"1.005", 2, "1.01"},
+		{"1.004999", 2, "1.00"},
+		{"-1.005", 2, "-1.01"},
+		{"-1.004999", 2, "-1.00"},
+		{"2.5", 0, "3"},
+		{"-2.5", 0, "-3"},
+		{"0.125", 2, "0.13"},
+		{"12.3456", 4, "12.3456"},
+		{"12.34567", 6, "12.345670"},
+		{"99.995", 2, "100.00"},
+	}
+	for _, test := range tests {
+		d, _ := Parse(test.input)
+		if got := d.StringFixed(test.places); got != test.want {
+			t.Errorf("%s rounded to %d = %s, want %s", test.input, test.places, got, test.want)
+		}
+	}
+}
+
+func TestArithmetic(t *testing.T) {
+	parse := func(val string) Decimal {
+		d, err := Parse(val)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	tests := []struct {
+		name string
+		got  Decimal
+		want string
+	}{
+		{"add", parse("0.1").Add(parse("0.2")), "0.3"},
+		{"sub", parse("1").Sub(parse("1.000001")), "-0.000001"},
+		{"mul", parse("1.5").Mul(parse("-2.25")), "-3.375"},
+		{"mul rounds", parse("0.000001").Mul(parse("0.5")), "0.000001"},
+		{"div", parse("10").Div(parse("3")), "3.333333"},
+		{"div rounds half up", parse("2").Div(parse("3")), "0.666667"},
+		{"div negative", parse("-2").Div(parse("3")), "-0.666667"},
+		{"muldiv 128 bit", parse("9000000000").MulDiv(parse("9000000000"), parse("9000000000")), "9000000000"},
+		{"muldiv large product", parse("5000000").MulDiv(parse("4000000"), parse("1000000")), "20000000"},
+		{"percent", Percent(parse("1"), parse("3")), "33.333333"},
+		{"percent zero whole", Percent(parse("1"), Zero), "0"},
+		{"float", NewFromFloat(1.0000005), "1.000001"},
+		{"float nan", NewFromFloat(math.NaN()), "0"},
+		{"float inf", NewFromFloat(math.Inf(1)), "0"},
+	}
+	for _, test := range tests {
+		if test.got.String() != test.want {
+			t.Errorf("%s = %s, want %s", test.name, test.got, test.want)
+		}
+	}
+}
+
+func TestMulDivRound(t *testing.T) {
+	tests := []struct {
+		a, b, c int64
+		want    int64
+	}{
+		{7, 1, 2, 4},
+		{-7, 1, 2, -4},
+		{7, -1, 2, -4},
+		{-7, -1, -2, -4},
+		{5, 1, 3, 2},
+		{math.MaxInt64, 2, 2, math.MaxInt64},
+		{math.MaxInt64, math.MaxInt64, math.MaxInt64, math.MaxInt64},
+		{1 << 62, 1 << 10, 1 << 20, 1 << 52},
+	}
+	for _, test := range tests {
+		if got := mulDivRound(test.a, test.b, test.c); got != test.want {
+			t.Errorf("mulDivRound(%d, %d, %d) = %d, want %d", test.a, test.b, test.c, got, test.want)
+		}
+	}
+}
+
+func TestPanics(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   func()
+	}{
+		{"div by zero", func() { NewFromInt(1).Div(Zero) }},
+		{"muldiv by zero", func() { NewFromInt(1).MulDiv(NewFromInt(1), Zero) }},
+		{"mul overflow", func() { NewFromInt(9000000000).Mul(NewFromInt(9000000000)) }},
+		{"div overflow", func() { NewFromInt(9000000000).Div(Decimal{1}) }},
+		{"int overflow", func() { NewFromInt(math.MaxInt64 / 1000) }},
+	}
+	for _, test := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s did not panic", test.name)
+				}
+			}()
+			test.fn()
+		}()
+	}
+}
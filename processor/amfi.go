@@ -0,0 +1,306 @@
+package processor
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vijayyogesh/PortfolioApis/constants"
+	"github.com/vijayyogesh/PortfolioApis/data"
+	"github.com/vijayyogesh/PortfolioApis/money"
+)
+
+/* NAV of a mutual fund scheme on a day from AMFI files */
+type amfiNav struct {
+	SchemeCode string
+	Isins      []string
+	Nav        money.Decimal
+	Date       time.Time
+}
+
+/* Mutual fund NAVs published by AMFI. Latest NAVs come from NAVAll.txt and older ones from NAV history report.
+** Instrument is mapped to a scheme by AMFI scheme code in provider symbol or by ISIN.
+** Files are read from Dir instead of AMFI when Dir is set, for offline runs */
+type AMFIPriceProvider struct {
+	NavUrl     string
+	HistoryUrl string
+	Dir        string
+
+	runMutex sync.Mutex
+	run      *amfiRun
+}
+
+/* Downloads of an ingestion run. AMFI files carry every scheme, so each is downloaded once
+** keeping NAVs of all schemes in the run */
+type amfiRun struct {
+	companyIds map[string]bool
+	isScheme   func(amfiNav) bool
+	mutex      sync.Mutex
+	downloads  map[string]*amfiDownload
+}
+
+type amfiDownload struct {
+	once sync.Once
+	navs []amfiNav
+	err  error
+}
+
+func NewAMFIPriceProvider(navUrl string, historyUrl string, dir string) *AMFIPriceProvider {
+	if navUrl == "" {
+		navUrl = constants.AppAmfiNavUrl
+	}
+	if historyUrl == "" {
+		historyUrl = constants.AppAmfiHistoryUrl
+	}
+	return &AMFIPriceProvider{NavUrl: navUrl, HistoryUrl: historyUrl, Dir: dir}
+}
+
+func (provider *AMFIPriceProvider) Name() string {
+	return constants.AppProviderAMFI
+}
+
+/* Share downloads among companies of the run priced from AMFI */
+func (provider *AMFIPriceProvider) StartRun(companyIds []string) {
+	run := &amfiRun{companyIds: make(map[string]bool), downloads: make(map[string]*amfiDownload)}
+	var instruments []data.Instrument
+	for _, companyId := range companyIds {
+		companyProvider, err := priceProviderFor(companyId)
+		if err != nil || companyProvider.Name() != constants.AppProviderAMFI {
+			continue
+		}
+		run.companyIds[companyId] = true
+		instruments = append(instruments, getInstrument(companyId))
+	}
+	run.isScheme = amfiSchemeMatcher(instruments)
+
+	provider.runMutex.Lock()
+	provider.run = run
+	provider.runMutex.Unlock()
+}
+
+func (provider *AMFIPriceProvider) EndRun() {
+	provider.runMutex.Lock()
+	provider.run = nil
+	provider.runMutex.Unlock()
+}
+
+/* NAVs of scheme from fromTime as price csv. NAV is used as open, high, low, close and adj close */
+func (provider *AMFIPriceProvider) FetchPrices(companyId string, fromTime time.Time) (io.ReadCloser, error) {
+	instrument := getInstrument(companyId)
+	if instrument.ProviderSymbol == "" && instrument.Isin == "" {
+		return nil, fmt.Errorf("AMFI scheme code or ISIN missing for company %s", companyId)
+	}
+	isScheme := amfiSchemeMatcher([]data.Instrument{instrument})
+
+	navs := make(map[string]money.Decimal)
+	fromDate := fromTime.Truncate(24 * time.Hour)
+	collect := func(fileNavs []amfiNav, err error) error {
+		for _, nav := range fileNavs {
+			if isScheme(nav) && !nav.Date.Before(fromDate) {
+				navs[nav.Date.Format("2006-01-02")] = nav.Nav
+			}
+		}
+		return err
+	}
+
+	if provider.Dir != "" {
+		err := collect(readAmfiFile(filepath.Join(provider.Dir, constants.AppAmfiHistoryFile), isScheme))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		err = collect(readAmfiFile(filepath.Join(provider.Dir, constants.AppAmfiNavFile), isScheme))
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		/* History report is limited to a window of days per request. Windows are counted from start of history
+		** so that instruments fetched from different dates share them */
+		historyStart := time.Date(constants.AppAmfiHistoryStartYear, 4, 1, 0, 0, 0, 0, time.UTC)
+		for windowStart := historyStart; windowStart.Before(time.Now()); windowStart = windowStart.AddDate(0, 0, constants.AppAmfiHistoryWindowDays) {
+			windowEnd := windowStart.AddDate(0, 0, constants.AppAmfiHistoryWindowDays-1)
+			if windowEnd.Before(fromDate) {
+				continue
+			}
+			url := provider.HistoryUrl + fmt.Sprintf(constants.AppAmfiHistoryUrlQuery, windowStart.Format("02-Jan-2006"), windowEnd.Format("02-Jan-2006"))
+			err := collect(provider.fetchNavs(url, companyId, isScheme))
+			if err != nil {
+				return nil, err
+			}
+		}
+		err := collect(provider.fetchNavs(provider.NavUrl, companyId, isScheme))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return io.NopCloser(navPriceCsv(navs)), nil
+}
+
+/* NAVs in AMFI file at url. Within an ingestion run the file is downloaded once for all companies of the run */
+func (provider *AMFIPriceProvider) fetchNavs(url string, companyId string, isScheme func(amfiNav) bool) ([]amfiNav, error) {
+	provider.runMutex.Lock()
+	run := provider.run
+	provider.runMutex.Unlock()
+	if run == nil || !run.companyIds[companyId] {
+		return downloadAmfiNavs(url, companyId, isScheme)
+	}
+
+	run.mutex.Lock()
+	download, isPresent := run.downloads[url]
+	if !isPresent {
+		download = &amfiDownload{}
+		run.downloads[url] = download
+	}
+	run.mutex.Unlock()
+	download.once.Do(func() {
+		download.navs, download.err = downloadAmfiNavs(url, companyId, run.isScheme)
+	})
+	return download.navs, download.err
+}
+
+func downloadAmfiNavs(url string, companyId string, isScheme func(amfiNav) bool) ([]amfiNav, error) {
+	appUtil.AppLogger.Println("Hitting url " + url + " for company - " + companyId)
+	reader, err := httpGetBody(url)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return ReadAmfiNavs(reader, isScheme)
+}
+
+func readAmfiFile(path string, isScheme func(amfiNav) bool) ([]amfiNav, error) {
+	reader, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return ReadAmfiNavs(reader, isScheme)
+}
+
+/* Matches NAVs of instruments by scheme code in provider symbol, or by ISIN when scheme code is not set */
+func amfiSchemeMatcher(instruments []data.Instrument) func(amfiNav) bool {
+	schemeCodes := make(map[string]bool)
+	isins := make(map[string]bool)
+	for _, instrument := range instruments {
+		if instrument.ProviderSymbol != "" {
+			schemeCodes[instrument.ProviderSymbol] = true
+		} else if instrument.Isin != "" {
+			isins[instrument.Isin] = true
+		}
+	}
+	return func(nav amfiNav) bool {
+		if schemeCodes[nav.SchemeCode] {
+			return true
+		}
+		for _, isin := range nav.Isins {
+			if isins[isin] {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+/* Price csv of NAVs in date order */
+func navPriceCsv(navs map[string]money.Decimal) *bytes.Buffer {
+	var dates []string
+	for dateStr := range navs {
+		dates = append(dates, dateStr)
+	}
+	sort.Strings(dates)
+
+	var buffer bytes.Buffer
+	csvWriter := csv.NewWriter(&buffer)
+	csvWriter.Write([]string{"Date", "Open", "High", "Low", "Close", "Adj Close", "Volume"})
+	for _, dateStr := range dates {
+		nav := navs[dateStr].String()
+		csvWriter.Write([]string{dateStr, nav, nav, nav, nav, nav, "0"})
+	}
+	csvWriter.Flush()
+	return &buffer
+}
+
+/* Parse AMFI NAVAll.txt or NAV history report and return NAVs of schemes accepted by isScheme.
+** Files are ; separated with a header naming the columns, followed by fund house/category lines without ;
+** which are skipped. Rows with NAV not available are skipped too */
+func ReadAmfiNavs(reader io.Reader, isScheme func(amfiNav) bool) ([]amfiNav, error) {
+	var navs []amfiNav
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var columns map[string]int
+	var isinColumns []int
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.Contains(line, ";") {
+			continue
+		}
+		fields := strings.Split(line, ";")
+		if columns == nil {
+			columns, isinColumns = amfiColumns(fields)
+			for _, name := range []string{"Scheme Code", "Net Asset Value", "Date"} {
+				if _, isPresent := columns[name]; !isPresent {
+					return navs, fmt.Errorf("column %s missing in AMFI file", name)
+				}
+			}
+			continue
+		}
+		if len(fields) <= columns["Date"] || len(fields) <= columns["Net Asset Value"] {
+			continue
+		}
+
+		nav := amfiNav{SchemeCode: strings.TrimSpace(fields[columns["Scheme Code"]])}
+		for _, key := range isinColumns {
+			if key < len(fields) {
+				if isin := strings.TrimSpace(fields[key]); isin != "" && isin != "-" {
+					nav.Isins = append(nav.Isins, isin)
+				}
+			}
+		}
+		if !isScheme(nav) {
+			continue
+		}
+		navVal, err := money.Parse(strings.TrimSpace(fields[columns["Net Asset Value"]]))
+		if err != nil || navVal.Sign() <= 0 {
+			continue
+		}
+		navDate, err := time.Parse("02-Jan-2006", strings.TrimSpace(fields[columns["Date"]]))
+		if err != nil {
+			appUtil.AppLogger.Printf("Rejected NAV of scheme %s : %v ", nav.SchemeCode, err)
+			continue
+		}
+		nav.Nav = navVal
+		nav.Date = navDate
+		navs = append(navs, nav)
+	}
+	return navs, scanner.Err()
+}
+
+/* Column positions of AMFI header. ISIN columns differ between NAVAll and history report */
+func amfiColumns(header []string) (map[string]int, []int) {
+	columns := make(map[string]int)
+	var isinColumns []int
+	for key, name := range header {
+		name = strings.TrimSpace(name)
+		columns[name] = key
+		if strings.Contains(name, "ISIN") {
+			isinColumns = append(isinColumns, key)
+		}
+	}
+	return columns, isinColumns
+}
+
+/* AMFI scheme code or ISIN is needed to map an instrument to a scheme */
+func validateAmfiInstrument(instrument data.Instrument) error {
+	if instrument.Provider == constants.AppProviderAMFI && instrument.ProviderSymbol == "" && instrument.Isin == "" {
+		return fmt.Errorf("AMFI scheme code as provider symbol or ISIN required for instrument %s", instrument.CompanyId)
+	}
+	return nil
+}
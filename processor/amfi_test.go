@@ -0,0 +1,65 @@
+package processor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/vijayyogesh/PortfolioApis/data"
+)
+
+func TestReadAmfiNavs(t *testing.T) {
+	setupTestPrices(t, nil)
+	isScheme := amfiSchemeMatcher([]data.Instrument{{ProviderSymbol: "100"}, {Isin: "INF000B01011"}})
+
+	tests := []struct {
+		name     string
+		file     string
+		wantNavs []string
+		wantErr  bool
+	}{
+		{
+			name: "schemes by code and isin",
+			file: "Scheme Code;ISIN Div Payout/ ISIN Growth;ISIN Div Reinvestment;Scheme Name;Net Asset Value;Date\n" +
+				"Open Ended Schemes(Equity Scheme - Large Cap Fund)\n" +
+				"100;INF000A01011;-;Fund A;12.5;02-Jan-2024\n" +
+				"200;INF000B01011;-;Fund B;20.25;02-Jan-2024\n" +
+				"300;INF000C01011;-;Fund C;30;02-Jan-2024\n" +
+				"100;INF000A01011;-;Fund A;N.A.;03-Jan-2024\n",
+			wantNavs: []string{"100 12.5 2024-01-02", "200 20.25 2024-01-02"},
+		},
+		{
+			name:    "date column missing",
+			file:    "Scheme Code;Scheme Name;Net Asset Value\n100;Fund A;12.5\n",
+			wantErr: true,
+		},
+		{
+			name:    "nav column missing",
+			file:    "Scheme Code;Scheme Name;Repurchase Price;Date\n100;Fund A;12.5;02-Jan-2024\n",
+			wantErr: true,
+		},
+		{
+			name:    "scheme code column missing",
+			file:    "Code;Scheme Name;Net Asset Value;Date\n100;Fund A;12.5;02-Jan-2024\n",
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		navs, err := ReadAmfiNavs(strings.NewReader(test.file), isScheme)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%s: read %v, want error", test.name, navs)
+			}
+			continue
+		}
+		if err != nil || len(navs) != len(test.wantNavs) {
+			t.Errorf("%s: read %v %v, want %v", test.name, navs, err, test.wantNavs)
+			continue
+		}
+		for key, nav := range navs {
+			if got := nav.SchemeCode + " " + nav.Nav.String() + " " + nav.Date.Format("2006-01-02"); got != test.wantNavs[key] {
+				t.Errorf("%s: nav %d = %s, want %s", test.name, key, got, test.wantNavs[key])
+			}
+		}
+	}
+}
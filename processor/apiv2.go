@@ -0,0 +1,446 @@
+package processor
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/vijayyogesh/PortfolioApis/constants"
+	"github.com/vijayyogesh/PortfolioApis/data"
+	"github.com/vijayyogesh/PortfolioApis/money"
+)
+
+/* v2 API handlers. Payloads are converted to/from the v1 string typed structs
+** so both versions are served by the same processing logic. */
+
+/* -------------------------------------- */
+/* ROUTER METHODS V2 */
+
+func AddUserHoldingsV2(userInput []byte) string {
+	var holdingsInput data.HoldingsInputV2
+	err := json.Unmarshal(userInput, &holdingsInput)
+	if err != nil {
+		appUtil.AppLogger.Println(err)
+		return constants.AppErrInvalidPayload
+	}
+
+	holdingsInputV1 := data.HoldingsInputJson{
+		UserID:      holdingsInput.UserID,
+		PortfolioId: holdingsInput.PortfolioId,
+	}
+	for _, holding := range holdingsInput.Holdings {
+		holdingsInputV1.Holdings = append(holdingsInputV1.Holdings, data.Holdings{
+			Companyid: holding.CompanyId,
+			Quantity:  holding.Quantity.String(),
+			BuyDate:   holding.BuyDate.String(),
+			BuyPrice:  holding.BuyPrice.String(),
+		})
+	}
+	for _, holdingNT := range holdingsInput.HoldingsNT {
+		holdingsInputV1.HoldingsNT = append(holdingsInputV1.HoldingsNT, data.HoldingsNonTracked{
+			SecurityId:   holdingNT.SecurityId,
+			BuyDate:      holdingNT.BuyDate.String(),
+			BuyValue:     holdingNT.BuyValue.String(),
+			CurrentValue: holdingNT.CurrentValue.String(),
+			InterestRate: holdingNT.InterestRate.String(),
+		})
+	}
+	for _, txn := range holdingsInput.Transactions {
+		holdingsInputV1.Transactions = append(holdingsInputV1.Transactions, data.Transaction{
+			Companyid:      txn.CompanyId,
+			TxnType:        txn.TxnType,
+			Quantity:       txn.Quantity.String(),
+			Price:          txn.Price.String(),
+			Fees:           txn.Fees.String(),
+			Brokerage:      txn.Brokerage.String(),
+			Taxes:          txn.Taxes.String(),
+			TxnDate:        txn.TxnDate.String(),
+			SettlementDate: txn.SettlementDate.String(),
+			Notes:          txn.Notes,
+		})
+	}
+	return addUserHoldings(holdingsInputV1)
+}
+
+func GetUserHoldingsV2(userInput []byte) (data.HoldingsOutputV2, error) {
+	userHoldings, err := GetUserHoldings(userInput, true)
+	if err != nil {
+		return data.HoldingsOutputV2{}, err
+	}
+	return toHoldingsOutputV2(userHoldings), nil
+}
+
+func GetUserTransactionsV2(userInput []byte) (data.TransactionsOutputV2, error) {
+	transactionsOutput, err := GetUserTransactions(userInput)
+	if err != nil {
+		return data.TransactionsOutputV2{}, err
+	}
+
+	transactionsOutputV2 := data.TransactionsOutputV2{
+		UserID:       transactionsOutput.UserID,
+		PortfolioId:  transactionsOutput.PortfolioId,
+		Transactions: []data.TransactionV2{},
+	}
+	for _, txn := range transactionsOutput.Transactions {
+		transactionsOutputV2.Transactions = append(transactionsOutputV2.Transactions, data.TransactionV2{
+			TransactionId:  txn.TransactionId,
+			PortfolioId:    txn.PortfolioId,
+			CompanyId:      txn.Companyid,
+			CompanyName:    txn.CompanyName,
+			TxnType:        txn.TxnType,
+			Quantity:       toNumberV2(txn.Quantity, money.QuantityPlaces),
+			Price:          toNumberV2(txn.Price, money.PricePlaces),
+			Fees:           toNumberV2(txn.Fees, money.AmountPlaces),
+			Brokerage:      toNumberV2(txn.Brokerage, money.AmountPlaces),
+			Taxes:          toNumberV2(txn.Taxes, money.AmountPlaces),
+			TxnDate:        toDateV2(txn.TxnDate),
+			SettlementDate: toDateV2(txn.SettlementDate),
+			Notes:          txn.Notes,
+		})
+	}
+	return transactionsOutputV2, nil
+}
+
+func AddModelPortfolioV2(userInput []byte) string {
+	var modelPf data.ModelPortfolioV2
+	err := json.Unmarshal(userInput, &modelPf)
+	if err != nil {
+		appUtil.AppLogger.Println(err)
+		return constants.AppErrInvalidPayload
+	}
+
+	modelPfV1 := data.ModelPortfolio{
+		UserID:      modelPf.UserID,
+		PortfolioId: modelPf.PortfolioId,
+	}
+	for _, security := range modelPf.Securities {
+		modelPfV1.Securities = append(modelPfV1.Securities, data.Securities{
+			Securityid:         security.SecurityId,
+			ReasonablePrice:    security.ReasonablePrice.String(),
+			ExpectedAllocation: security.ExpectedAllocation.String(),
+		})
+	}
+	return addModelPortfolio(modelPfV1)
+}
+
+func GetModelPortfolioV2(userInput []byte) (data.ModelPortfolioV2, error) {
+	modelPf, err := GetModelPortfolio(userInput)
+	if err != nil {
+		return data.ModelPortfolioV2{}, err
+	}
+
+	modelPfV2 := data.ModelPortfolioV2{
+		UserID:      modelPf.UserID,
+		PortfolioId: modelPf.PortfolioId,
+		Securities:  []data.SecurityV2{},
+	}
+	for _, security := range modelPf.Securities {
+		modelPfV2.Securities = append(modelPfV2.Securities, data.SecurityV2{
+			SecurityId:         security.Securityid,
+			ReasonablePrice:    toNumberV2(security.ReasonablePrice, money.PricePlaces),
+			ExpectedAllocation: toNumberV2(security.ExpectedAllocation, money.PercentPlaces),
+			PortfolioId:        security.PortfolioId,
+		})
+	}
+	return modelPfV2, nil
+}
+
+func GetPortfolioModelSyncV2(userInput []byte) (data.SyncedPortfolioV2, error) {
+	syncedPf, err := GetPortfolioModelSync(userInput)
+	if err != nil {
+		return data.SyncedPortfolioV2{}, err
+	}
+
+	syncedPfV2 := data.SyncedPortfolioV2{AdjustedHoldings: []data.AdjustedHoldingV2{}}
+	for _, adjustedHolding := range syncedPf.AdjustedHoldings {
+		syncedPfV2.AdjustedHoldings = append(syncedPfV2.AdjustedHoldings, data.AdjustedHoldingV2{
+			PortfolioId:                 adjustedHolding.PortfolioId,
+			SecurityId:                  adjustedHolding.Securityid,
+			AdjustedAmount:              toNumberV2(adjustedHolding.AdjustedAmount, money.AmountPlaces),
+			BelowReasonablePrice:        adjustedHolding.BelowReasonablePrice == "Y",
+			PercentBelowReasonablePrice: toNumberV2(adjustedHolding.PercentBelowReasonablePrice, money.PercentPlaces),
+		})
+	}
+	return syncedPfV2, nil
+}
+
+func FetchNetWorthOverPeriodsV2(userInput []byte) (data.SeriesOutputV2, error) {
+	networthSeries, err := FetchNetWorthOverPeriods(userInput)
+	if err != nil {
+		return data.SeriesOutputV2{}, err
+	}
+	return toSeriesV2(networthSeries, money.AmountPlaces), nil
+}
+
+func CalculateXirrReturnV2(userInput []byte) (data.SeriesOutputV2, error) {
+	xirrSeries, err := CalculateXirrReturn(userInput)
+	if err != nil {
+		return data.SeriesOutputV2{}, err
+	}
+	return toSeriesV2(xirrSeries, money.PercentPlaces), nil
+}
+
+func CalculateNavReturnV2(userInput []byte) (data.SeriesOutputV2, error) {
+	navSeries, err := CalculateNavReturn(userInput)
+	if err != nil {
+		return data.SeriesOutputV2{}, err
+	}
+	return toSeriesV2(navSeries, money.PricePlaces), nil
+}
+
+func CalculateTwrReturnV2(userInput []byte) (data.TwrOutputV2, error) {
+	twrOutput, err := CalculateTwrReturn(userInput)
+	if err != nil {
+		return data.TwrOutputV2{}, err
+	}
+
+	twrOutputV2 := data.TwrOutputV2{
+		UserId:      twrOutput.UserID,
+		PortfolioId: twrOutput.PortfolioId,
+		ReturnType:  twrOutput.ReturnType,
+		BenchmarkId: twrOutput.BenchmarkId,
+		Periods:     []data.TwrPeriodReturnV2{},
+	}
+	for _, periodReturn := range twrOutput.Periods {
+		twrOutputV2.Periods = append(twrOutputV2.Periods, data.TwrPeriodReturnV2{
+			Period:              periodReturn.Period,
+			StartDate:           toDateV2(periodReturn.StartDate),
+			EndDate:             toDateV2(periodReturn.EndDate),
+			PortfolioReturn:     toNumberV2(periodReturn.PortfolioReturn, money.PercentPlaces),
+			PortfolioAnnualized: toNumberV2(periodReturn.PortfolioAnnualized, money.PercentPlaces),
+			BenchmarkReturn:     toNumberV2(periodReturn.BenchmarkReturn, money.PercentPlaces),
+			BenchmarkAnnualized: toNumberV2(periodReturn.BenchmarkAnnualized, money.PercentPlaces),
+		})
+	}
+	return twrOutputV2, nil
+}
+
+func CalculateIndexSIPReturnV2(userInput []byte) (data.SIPReturnOutputV2, error) {
+	var sipReturnInput data.SIPReturnInputV2
+	err := json.Unmarshal(userInput, &sipReturnInput)
+	if err != nil {
+		appUtil.AppLogger.Println(err)
+		return data.SIPReturnOutputV2{}, err
+	}
+
+	sipParams := sipReturnInput.SIPReturnInputParam
+	sipReturnOutput, err := calculateIndexSIPReturn(data.SIPReturnInput{
+		UserID: sipReturnInput.UserID,
+		SIPReturnInputParam: data.SIPReturnInputParam{
+			Companyid:  sipParams.CompanyId,
+			StartDate:  sipParams.StartDate.Format("2006/01/02"),
+			EndDate:    sipParams.EndDate.Format("2006/01/02"),
+			SIPAmount:  sipParams.SIPAmount.String(),
+			StepUpPct:  sipParams.StepUpPct.String(),
+			ReturnType: sipParams.ReturnType,
+		},
+	})
+	if err != nil {
+		return data.SIPReturnOutputV2{}, err
+	}
+
+	sipReturnOutputV2 := data.SIPReturnOutputV2{SIPReturnSubPeriod: []data.SIPReturnSubPeriodV2{}}
+	for _, subPeriod := range sipReturnOutput.SIPReturnSubPeriod {
+		sipReturnOutputV2.SIPReturnSubPeriod = append(sipReturnOutputV2.SIPReturnSubPeriod, data.SIPReturnSubPeriodV2{
+			Quantity:        toNumberV2(subPeriod.Quantity, money.QuantityPlaces),
+			EndDate:         toDateV2(subPeriod.EndDate),
+			TotalInvestment: toNumberV2(subPeriod.TotalInvestment, money.AmountPlaces),
+			TotalEndValue:   toNumberV2(subPeriod.TotalEndValue, money.AmountPlaces),
+			Xirr:            toNumberV2(subPeriod.Xirr, money.PercentPlaces),
+			BuyVal:          toNumberV2(subPeriod.BuyVal, money.PricePlaces),
+		})
+	}
+	bracket := sipReturnOutput.SIPReturnBracket
+	sipReturnOutputV2.SIPReturnBracket = data.SIPReturnBracketV2{
+		LessThanZero:   toNumberV2(bracket.LessThanZeroCount, money.PercentPlaces),
+		ZeroToTwo:      toNumberV2(bracket.ZeroToTwoCount, money.PercentPlaces),
+		TwoToFive:      toNumberV2(bracket.TwoToFiveCount, money.PercentPlaces),
+		FiveToSeven:    toNumberV2(bracket.FiveToSevenCount, money.PercentPlaces),
+		SevenToTen:     toNumberV2(bracket.SevenToTenCount, money.PercentPlaces),
+		GreaterThanTen: toNumberV2(bracket.GreaterThanTenCount, money.PercentPlaces),
+	}
+	return sipReturnOutputV2, nil
+}
+
+func CalculateATHforPFV2(userInput []byte) (data.HoldingsOutputV2, error) {
+	athHoldings, err := CalculateATHforPF(userInput)
+	if err != nil {
+		return data.HoldingsOutputV2{}, err
+	}
+	return toHoldingsOutputV2(athHoldings), nil
+}
+
+func AddPortfolioV2(userInput []byte) string {
+	portfolioInput, err := toPortfolioInputV1(userInput)
+	if err != nil {
+		return constants.AppErrInvalidPayload
+	}
+	return addPortfolio(portfolioInput)
+}
+
+func UpdatePortfolioV2(userInput []byte) string {
+	portfolioInput, err := toPortfolioInputV1(userInput)
+	if err != nil {
+		return constants.AppErrInvalidPayload
+	}
+	return updatePortfolio(portfolioInput)
+}
+
+func GetPortfoliosV2(userInput []byte) (data.PortfoliosOutputV2, error) {
+	portfoliosOutput, err := GetPortfolios(userInput)
+	if err != nil {
+		return data.PortfoliosOutputV2{}, err
+	}
+
+	portfoliosOutputV2 := data.PortfoliosOutputV2{UserID: portfoliosOutput.UserID, Portfolios: []data.PortfolioV2{}}
+	for _, portfolio := range portfoliosOutput.Portfolios {
+		portfoliosOutputV2.Portfolios = append(portfoliosOutputV2.Portfolios, data.PortfolioV2{
+			PortfolioId:   portfolio.PortfolioId,
+			UserId:        portfolio.UserId,
+			PortfolioName: portfolio.PortfolioName,
+			TargetAmount:  toNumberV2(portfolio.TargetAmount, money.AmountPlaces),
+			CreatedDate:   toDateV2(portfolio.CreatedDate),
+		})
+	}
+	return portfoliosOutputV2, nil
+}
+
+func GetHouseholdNetworthV2(userInput []byte) (data.HouseholdOutputV2, error) {
+	householdOutput, err := GetHouseholdNetworth(userInput)
+	if err != nil {
+		return data.HouseholdOutputV2{}, err
+	}
+
+	householdOutputV2 := data.HouseholdOutputV2{
+		UserID:     householdOutput.UserID,
+		Networth:   toNumberV2(householdOutput.Networth, money.AmountPlaces),
+		Allocation: toAllocationV2(householdOutput.Allocation),
+		Portfolios: []data.HouseholdPortfolioV2{},
+		Holdings:   toHoldingsV2(householdOutput.Holdings),
+	}
+	for _, member := range householdOutput.Portfolios {
+		householdOutputV2.Portfolios = append(householdOutputV2.Portfolios, data.HouseholdPortfolioV2{
+			PortfolioId:   member.PortfolioId,
+			PortfolioName: member.PortfolioName,
+			OwnerUserId:   member.OwnerUserId,
+			Permission:    member.Permission,
+			Networth:      toNumberV2(member.Networth, money.AmountPlaces),
+			Allocation:    toAllocationV2(member.Allocation),
+		})
+	}
+	return householdOutputV2, nil
+}
+
+/* ROUTER METHODS V2 END */
+/* -------------------------------------- */
+
+/* v1 numeric string to v2 number rounded to given places. Empty/invalid values are zero */
+func toNumberV2(val string, places int) money.Decimal {
+	if val == "" {
+		return money.Zero
+	}
+	number, err := money.Parse(val)
+	if err != nil {
+		appUtil.AppLogger.Println(err)
+		return money.Zero
+	}
+	return number.Round(places)
+}
+
+/* v1 date string (plain or RFC3339) to v2 date. Invalid values are null */
+func toDateV2(val string) data.Date {
+	date, err := data.ParseDate(val)
+	if err != nil {
+		appUtil.AppLogger.Println(err)
+	}
+	return date
+}
+
+func toAllocationV2(allocation data.Allocation) data.AllocationV2 {
+	return data.AllocationV2{
+		Equity: toNumberV2(allocation.Equity, money.PercentPlaces),
+		Debt:   toNumberV2(allocation.Debt, money.PercentPlaces),
+		Gold:   toNumberV2(allocation.Gold, money.PercentPlaces),
+	}
+}
+
+func toHoldingsV2(holdings []data.Holdings) []data.HoldingV2 {
+	holdingsV2 := []data.HoldingV2{}
+	for _, holding := range holdings {
+		holdingsV2 = append(holdingsV2, data.HoldingV2{
+			CompanyId:    holding.Companyid,
+			CompanyName:  holding.CompanyName,
+			Quantity:     toNumberV2(holding.Quantity, money.QuantityPlaces),
+			BuyDate:      toDateV2(holding.BuyDate),
+			BuyPrice:     toNumberV2(holding.BuyPrice, money.PricePlaces),
+			LTP:          toNumberV2(holding.LTP, money.PricePlaces),
+			CurrentValue: toNumberV2(holding.CurrentValue, money.AmountPlaces),
+			PL:           toNumberV2(holding.PL, money.AmountPlaces),
+			NetPct:       toNumberV2(holding.NetPct, money.PercentPlaces),
+			RealizedPL:   toNumberV2(holding.RealizedPL, money.AmountPlaces),
+			Dividends:    toNumberV2(holding.Dividends, money.AmountPlaces),
+			TxnType:      holding.TxnType,
+		})
+	}
+	return holdingsV2
+}
+
+func toHoldingsOutputV2(userHoldings data.HoldingsOutputJson) data.HoldingsOutputV2 {
+	holdingsOutputV2 := data.HoldingsOutputV2{
+		UserID:      userHoldings.UserID,
+		PortfolioId: userHoldings.PortfolioId,
+		Holdings:    toHoldingsV2(userHoldings.Holdings),
+		HoldingsNT:  []data.HoldingNonTrackedV2{},
+		Networth:    toNumberV2(userHoldings.Networth, money.AmountPlaces),
+		Allocation:  toAllocationV2(userHoldings.Allocation),
+	}
+	for _, holdingNT := range userHoldings.HoldingsNT {
+		holdingsOutputV2.HoldingsNT = append(holdingsOutputV2.HoldingsNT, data.HoldingNonTrackedV2{
+			SecurityId:   holdingNT.SecurityId,
+			BuyDate:      toDateV2(holdingNT.BuyDate),
+			BuyValue:     toNumberV2(holdingNT.BuyValue, money.AmountPlaces),
+			CurrentValue: toNumberV2(holdingNT.CurrentValue, money.AmountPlaces),
+			InterestRate: toNumberV2(holdingNT.InterestRate, money.PercentPlaces),
+		})
+	}
+	return holdingsOutputV2
+}
+
+/* Date keyed maps to date sorted series */
+func toSeriesV2(seriesMap map[string]map[string]float64, places int) data.SeriesOutputV2 {
+	seriesOutput := make(data.SeriesOutputV2)
+	for name, values := range seriesMap {
+		series := []data.SeriesPointV2{}
+		for dateStr, val := range values {
+			series = append(series, data.SeriesPointV2{
+				Date:  toDateV2(dateStr),
+				Value: money.NewFromFloat(val).Round(places),
+			})
+		}
+		sort.Slice(series, func(i, j int) bool {
+			return series[i].Date.Before(series[j].Date.Time)
+		})
+		seriesOutput[name] = series
+	}
+	return seriesOutput
+}
+
+func toPortfolioInputV1(userInput []byte) (data.PortfolioInputJson, error) {
+	var portfolioInput data.PortfolioInputV2
+	err := json.Unmarshal(userInput, &portfolioInput)
+	if err != nil {
+		appUtil.AppLogger.Println(err)
+		return data.PortfolioInputJson{}, err
+	}
+	targetAmount := ""
+	if portfolioInput.Portfolio.TargetAmount != nil {
+		targetAmount = portfolioInput.Portfolio.TargetAmount.String()
+	}
+	return data.PortfolioInputJson{
+		UserID: portfolioInput.UserID,
+		Portfolio: data.Portfolio{
+			PortfolioId:   portfolioInput.Portfolio.PortfolioId,
+			PortfolioName: portfolioInput.Portfolio.PortfolioName,
+			TargetAmount:  targetAmount,
+		},
+	}, nil
+}
@@ -0,0 +1,97 @@
+package processor
+
+import (
+	"strconv"
+
+	"github.com/vijayyogesh/PortfolioApis/cache"
+	"github.com/vijayyogesh/PortfolioApis/constants"
+	"github.com/vijayyogesh/PortfolioApis/data"
+)
+
+/* Close and total return series of company over full history. Bounded as each entry holds decades of prices */
+var dailyPriceCache = cache.New("dailyprice", constants.AppPriceCacheSize)
+
+var dailyPriceCacheLatest = cache.New("latestprice", 0)
+
+/* Caches below hold a single snapshot under cacheKeyAll which is replaced as a whole on reload */
+var companiesATHPriceCache = cache.New("ath", 0)
+
+var companiesCache = cache.New("companies", 0)
+
+var usersCache = cache.New("users", 0)
+
+var instrumentsCache = cache.New("instruments", 0)
+
+/* Corporate actions grouped by company ordered by ex date */
+var corporateActionsCache = cache.New("corporateactions", 0)
+
+/* Search index over master list */
+var companySearchIndex = cache.New("companysearch", 0)
+
+const cacheKeyAll = "all"
+
+var allCaches = []*cache.Cache{dailyPriceCache, dailyPriceCacheLatest, companiesATHPriceCache,
+	companiesCache, usersCache, instrumentsCache, corporateActionsCache, companySearchIndex}
+
+/* Size price cache from config */
+func initCaches() {
+	if appUtil.Config != nil && appUtil.Config.PriceCacheSize > 0 {
+		dailyPriceCache.SetCapacity(appUtil.Config.PriceCacheSize)
+	}
+}
+
+/* Prices of companies changed by ingestion/backfill. ATH is a single snapshot of all companies and is reloaded */
+func invalidatePriceCaches(companyIds []string) {
+	if len(companyIds) == 0 {
+		return
+	}
+	dailyPriceCache.Invalidate(companyIds...)
+	dailyPriceCacheLatest.Invalidate(companyIds...)
+	companiesATHPriceCache.Invalidate()
+}
+
+/* Companies or instruments added/edited */
+func invalidateMasterListCaches() {
+	companiesCache.Invalidate()
+	instrumentsCache.Invalidate()
+	companySearchIndex.Invalidate()
+}
+
+func invalidateCorporateActionsCache() {
+	corporateActionsCache.Invalidate()
+}
+
+func invalidateUsersCache() {
+	usersCache.Invalidate()
+}
+
+/* Latest price of company loading it into cache first time */
+func getLatestPrice(companyId string) (data.CompaniesPriceData, error) {
+	value, err := dailyPriceCacheLatest.GetOrLoad(companyId, func() (interface{}, error) {
+		return data.FetchCompaniesLatestPriceDataDB(companyId, appUtil.Db)
+	})
+	if err != nil {
+		appUtil.AppLogger.Println(err)
+		return data.CompaniesPriceData{}, err
+	}
+	return value.(data.CompaniesPriceData), nil
+}
+
+/* Admin route to view entries, capacity and hit/miss/eviction counts of caches */
+func GetCacheStats() data.CacheStatsOutputJson {
+	var statsOutput data.CacheStatsOutputJson
+	for _, appCache := range allCaches {
+		stats := appCache.Stats()
+		statsOutput.Caches = append(statsOutput.Caches, data.CacheStats{
+			Name:          stats.Name,
+			Entries:       strconv.Itoa(stats.Entries),
+			Capacity:      strconv.Itoa(stats.Capacity),
+			Hits:          strconv.FormatUint(stats.Hits, 10),
+			Misses:        strconv.FormatUint(stats.Misses, 10),
+			Loads:         strconv.FormatUint(stats.Loads, 10),
+			Evictions:     strconv.FormatUint(stats.Evictions, 10),
+			Invalidations: strconv.FormatUint(stats.Invalidations, 10),
+		})
+	}
+	return statsOutput
+}
@@ -0,0 +1,336 @@
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vijayyogesh/PortfolioApis/constants"
+	"github.com/vijayyogesh/PortfolioApis/data"
+	"github.com/vijayyogesh/PortfolioApis/money"
+)
+
+/* Notes of ledger entries derived from corporate actions */
+const corporateActionNotes = "Corporate action"
+
+/* Admin route to add or edit corporate actions */
+func AddCorporateActions(userInput []byte) string {
+	var actionsInput data.CorporateActionsInputJson
+	err := json.Unmarshal(userInput, &actionsInput)
+	if err != nil || len(actionsInput.CorporateActions) == 0 {
+		appUtil.AppLogger.Println(err)
+		return constants.AppErrInvalidCorporateAction
+	}
+
+	for key := range actionsInput.CorporateActions {
+		errValidate := normalizeCorporateAction(&actionsInput.CorporateActions[key])
+		if errValidate != nil {
+			appUtil.AppLogger.Println(errValidate)
+			return constants.AppErrInvalidCorporateAction
+		}
+	}
+
+	err = data.AddCorporateActionsDB(actionsInput.CorporateActions, appUtil.Db)
+	if err != nil {
+		appUtil.AppLogger.Println(err)
+		return constants.AppErrAddCorporateActions
+	}
+	invalidateCorporateActionsCache()
+
+	/* Back dated actions change holdings from their ex date */
+	changedFrom := make(map[string]time.Time)
+	for _, action := range actionsInput.CorporateActions {
+		exDate, _ := parseTxnDate(action.ExDate)
+		if earliest, isPresent := changedFrom[action.CompanyId]; !isPresent || exDate.Before(earliest) {
+			changedFrom[action.CompanyId] = exDate
+		}
+	}
+	invalidateCompanySnapshots(changedFrom)
+	return constants.AppSuccessAddCorporateActions
+}
+
+/* Corporate actions of a company, or of all companies when companyid is not provided */
+func GetCorporateActions(userInput []byte) (data.CorporateActionsOutputJson, error) {
+	var actionsOutput data.CorporateActionsOutputJson
+	var actionsInput data.CorporateActionsInputJson
+	json.Unmarshal(userInput, &actionsInput)
+
+	corporateActions, err := loadCorporateActions()
+	if err != nil {
+		return actionsOutput, err
+	}
+	if actionsInput.Companyid != "" {
+		actionsOutput.CorporateActions = corporateActions[actionsInput.Companyid]
+		return actionsOutput, nil
+	}
+	for _, companyActions := range corporateActions {
+		actionsOutput.CorporateActions = append(actionsOutput.CorporateActions, companyActions...)
+	}
+	sort.SliceStable(actionsOutput.CorporateActions, func(i, j int) bool {
+		return actionsOutput.CorporateActions[i].CompanyId < actionsOutput.CorporateActions[j].CompanyId
+	})
+	return actionsOutput, nil
+}
+
+/* Validate type, ratio/amount and date. Ratio defaults to 1:1 and amount to 0 */
+func normalizeCorporateAction(action *data.CorporateAction) error {
+	action.CompanyId = strings.TrimSpace(action.CompanyId)
+	action.ActionType = strings.ToUpper(strings.TrimSpace(action.ActionType))
+	action.Source = data.ActionSourceAdmin
+	if action.RatioTo == "" {
+		action.RatioTo = "1"
+	}
+	if action.RatioFrom == "" {
+		action.RatioFrom = "1"
+	}
+	if action.Amount == "" {
+		action.Amount = "0"
+	}
+
+	if action.CompanyId == "" {
+		return fmt.Errorf("company id missing for corporate action")
+	}
+	if _, err := parseTxnDate(action.ExDate); err != nil {
+		return err
+	}
+	ratioTo, ratioFrom, amount, err := parseCorporateAction(*action)
+	if err != nil {
+		return err
+	}
+
+	switch action.ActionType {
+	case data.ActionTypeSplit, data.ActionTypeBonus:
+		if ratioTo.Sign() <= 0 || ratioFrom.Sign() <= 0 {
+			return fmt.Errorf("ratio must be positive for %s of company %s", action.ActionType, action.CompanyId)
+		}
+	case data.ActionTypeDividend:
+		if amount.Sign() <= 0 {
+			return fmt.Errorf("amount must be positive for dividend of company %s", action.CompanyId)
+		}
+	default:
+		return fmt.Errorf("invalid corporate action type %s for company %s", action.ActionType, action.CompanyId)
+	}
+	return nil
+}
+
+/* Ratio and amount of a corporate action */
+func parseCorporateAction(action data.CorporateAction) (money.Decimal, money.Decimal, money.Decimal, error) {
+	ratioTo, err := money.Parse(action.RatioTo)
+	if err != nil {
+		return money.Zero, money.Zero, money.Zero, err
+	}
+	ratioFrom, err := money.Parse(action.RatioFrom)
+	if err != nil {
+		return money.Zero, money.Zero, money.Zero, err
+	}
+	amount, err := money.Parse(action.Amount)
+	if err != nil {
+		return money.Zero, money.Zero, money.Zero, err
+	}
+	return ratioTo, ratioFrom, amount, nil
+}
+
+/* Corporate actions by company, loaded from DB into cache first time */
+func loadCorporateActions() (map[string][]data.CorporateAction, error) {
+	corporateActions, err := corporateActionsCache.GetOrLoad(cacheKeyAll, func() (interface{}, error) {
+		corporateActions, err := data.GetCorporateActionsDB(appUtil.Db)
+		if err != nil {
+			return nil, err
+		}
+		companyActions := make(map[string][]data.CorporateAction)
+		for _, action := range corporateActions {
+			companyActions[action.CompanyId] = append(companyActions[action.CompanyId], action)
+		}
+		return companyActions, nil
+	})
+	if err != nil {
+		appUtil.AppLogger.Println(err)
+		return nil, err
+	}
+	return corporateActions.(map[string][]data.CorporateAction), nil
+}
+
+/* Ledger with entries derived from corporate actions for every position held on ex date.
+** SPLIT/BONUS scale units keeping cost basis and DIVIDEND credits amount per unit held.
+** Actions already recorded by user as SPLIT/DIVIDEND on ex date are not applied again. Transactions must be date ordered */
+func applyCorporateActions(transactions []data.Transaction) ([]data.Transaction, error) {
+	corporateActions, err := loadCorporateActions()
+	if err != nil {
+		return transactions, err
+	}
+
+	/* Ledger entries per position in date order */
+	var order []string
+	positionTxns := make(map[string][]data.Transaction)
+	recorded := make(map[string]bool)
+	for _, txn := range transactions {
+		key := positionKey(txn)
+		if _, isPresent := positionTxns[key]; !isPresent {
+			order = append(order, key)
+		}
+		positionTxns[key] = append(positionTxns[key], txn)
+		if txn.TxnType == data.TxnTypeSplit || txn.TxnType == data.TxnTypeDividend {
+			txnDate, _ := parseTxnDate(txn.TxnDate)
+			recorded[key+":"+txn.TxnType+":"+txnDate.Format("2006-01-02")] = true
+		}
+	}
+
+	var adjusted []data.Transaction
+	today := time.Now()
+	for _, key := range order {
+		txns := positionTxns[key]
+		actions := corporateActions[txns[0].Companyid]
+		quantity := money.Zero
+		actionKey := 0
+
+		/* Entitlement is the quantity held before ex date */
+		applyActionsUpto := func(uptoDate time.Time) error {
+			for ; actionKey < len(actions); actionKey++ {
+				exDate, err := parseTxnDate(actions[actionKey].ExDate)
+				if err != nil {
+					return err
+				}
+				if exDate.After(uptoDate) {
+					return nil
+				}
+				txn, isApplied, err := corporateActionTransaction(actions[actionKey], exDate, txns[0], quantity, recorded)
+				if err != nil {
+					return err
+				}
+				if isApplied {
+					if txn.TxnType == data.TxnTypeSplit {
+						quantity, err = splitQuantity(quantity, txn)
+						if err != nil {
+							return err
+						}
+					}
+					adjusted = append(adjusted, txn)
+				}
+			}
+			return nil
+		}
+
+		for _, txn := range txns {
+			txnDate, err := parseTxnDate(txn.TxnDate)
+			if err != nil {
+				return transactions, err
+			}
+			err = applyActionsUpto(txnDate)
+			if err != nil {
+				return transactions, err
+			}
+			adjusted = append(adjusted, txn)
+
+			qty, _, _, err := parseTransaction(txn)
+			if err != nil {
+				return transactions, err
+			}
+			switch txn.TxnType {
+			case data.TxnTypeBuy:
+				quantity = quantity.Add(qty)
+			case data.TxnTypeSell:
+				quantity = quantity.Sub(qty)
+			case data.TxnTypeSplit:
+				quantity, err = splitQuantity(quantity, txn)
+				if err != nil {
+					return transactions, err
+				}
+			}
+		}
+		err := applyActionsUpto(today)
+		if err != nil {
+			return transactions, err
+		}
+	}
+
+	sortTransactions(adjusted)
+	return adjusted, nil
+}
+
+/* Ledger entry of corporate action for a position holding quantity units. Not applied when nothing is held or already recorded */
+func corporateActionTransaction(action data.CorporateAction, exDate time.Time, positionTxn data.Transaction, quantity money.Decimal,
+	recorded map[string]bool) (data.Transaction, bool, error) {
+	txn := data.Transaction{
+		PortfolioId: positionTxn.PortfolioId,
+		Companyid:   positionTxn.Companyid,
+		CompanyName: positionTxn.CompanyName,
+		TxnDate:     exDate.Format("2006-01-02T15:04:05Z"),
+		Notes:       corporateActionNotes,
+	}
+	if quantity.Sign() <= 0 {
+		return txn, false, nil
+	}
+
+	ratioTo, ratioFrom, amount, err := parseCorporateAction(action)
+	if err != nil {
+		return txn, false, err
+	}
+	switch action.ActionType {
+	case data.ActionTypeSplit:
+		txn.TxnType = data.TxnTypeSplit
+		txn.Quantity = ratioTo.String() + "/" + ratioFrom.String()
+	case data.ActionTypeBonus:
+		txn.TxnType = data.TxnTypeSplit
+		txn.Quantity = ratioTo.Add(ratioFrom).String() + "/" + ratioFrom.String()
+	case data.ActionTypeDividend:
+		txn.TxnType = data.TxnTypeDividend
+		txn.Quantity = quantity.String()
+		txn.Price = amount.String()
+	default:
+		return txn, false, nil
+	}
+
+	if recorded[positionKey(txn)+":"+txn.TxnType+":"+exDate.Format("2006-01-02")] {
+		return txn, false, nil
+	}
+	return txn, true, nil
+}
+
+/* Dividend cash flows of ledger net of charges by date */
+func dividendCashFlows(transactions []data.Transaction) (map[string]money.Decimal, error) {
+	cashFlows := make(map[string]money.Decimal)
+	for _, txn := range transactions {
+		if txn.TxnType != data.TxnTypeDividend {
+			continue
+		}
+		value, err := transactionProceeds(txn)
+		if err != nil {
+			return cashFlows, err
+		}
+		txnDate, err := parseTxnDate(txn.TxnDate)
+		if err != nil {
+			return cashFlows, err
+		}
+		dateStr := txnDate.Format("2006-01-02")
+		cashFlows[dateStr] = cashFlows[dateStr].Add(value)
+	}
+	return cashFlows, nil
+}
+
+/* Ledger of portfolios adjusted for corporate actions */
+func getPortfoliosTransactions(portfolioIds []int64) ([]data.Transaction, error) {
+	transactions, err := data.GetUserTransactionsDB(portfolioIds, appUtil.Db)
+	if err != nil {
+		return transactions, err
+	}
+	return applyCorporateActions(transactions)
+}
+
+/* Dividend cash flows of the portfolio(s) requested by user */
+func getUserDividendCashFlows(user data.User) (map[string]money.Decimal, error) {
+	isUserPresent, err := verifyUserId(user.UserId, appUtil.Db)
+	if err != nil || !isUserPresent {
+		return map[string]money.Decimal{}, err
+	}
+	portfolioIds, err := resolvePortfolioIds(user.UserId, user.PortfolioId)
+	if err != nil {
+		return map[string]money.Decimal{}, err
+	}
+	transactions, err := getPortfoliosTransactions(portfolioIds)
+	if err != nil {
+		return map[string]money.Decimal{}, err
+	}
+	return dividendCashFlows(transactions)
+}
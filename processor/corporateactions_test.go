@@ -0,0 +1,112 @@
+package processor
+
+import (
+	"testing"
+
+	"github.com/vijayyogesh/PortfolioApis/data"
+	"github.com/vijayyogesh/PortfolioApis/money"
+)
+
+/* Corporate actions are served from cache instead of DB */
+func setupTestCorporateActions(t *testing.T, actions []data.CorporateAction) {
+	companyActions := make(map[string][]data.CorporateAction)
+	for _, action := range actions {
+		companyActions[action.CompanyId] = append(companyActions[action.CompanyId], action)
+	}
+	corporateActionsCache.Set(cacheKeyAll, companyActions)
+	t.Cleanup(func() { corporateActionsCache.Invalidate() })
+}
+
+/* Ratios without an exact decimal scale units exactly, so the whole holding can be sold after them */
+func TestApplyCorporateActionsRatio(t *testing.T) {
+	setupTestPrices(t, map[string]map[string]string{
+		"BON": {"2024-01-01": "100", "2024-01-02": "75", "2024-01-03": "75"},
+		"REV": {"2024-01-01": "100", "2024-01-02": "300", "2024-01-03": "300"},
+	})
+	setupTestCorporateActions(t, []data.CorporateAction{
+		{CompanyId: "BON", ExDate: "2024-01-02", ActionType: data.ActionTypeBonus, RatioTo: "1", RatioFrom: "3", Amount: "0"},
+		{CompanyId: "REV", ExDate: "2024-01-02", ActionType: data.ActionTypeSplit, RatioTo: "1", RatioFrom: "3", Amount: "0"},
+	})
+
+	tests := []struct {
+		name      string
+		companyId string
+		wantQty   string
+		sellQty   string
+	}{
+		{"1:3 bonus", "BON", "400", "400"},
+		{"1:3 reverse split", "REV", "100", "100"},
+	}
+
+	for _, test := range tests {
+		held, err := applyCorporateActions([]data.Transaction{testTxn(test.companyId, data.TxnTypeBuy, "300", "100", "2024-01-01")})
+		if err != nil {
+			t.Errorf("%s: error %v", test.name, err)
+			continue
+		}
+		positions, order, err := BuildPositions(held)
+		if err != nil || len(order) != 1 || positions[order[0]].Quantity.String() != test.wantQty {
+			t.Errorf("%s: positions %v %v, want quantity %s", test.name, positions, err, test.wantQty)
+		}
+		holdings, err := TransactionsToHoldings(held)
+		if err != nil {
+			t.Errorf("%s: holdings error %v", test.name, err)
+		}
+		heldQty := money.Zero
+		for _, holding := range holdings {
+			qty, _ := money.Parse(holding.Quantity)
+			heldQty = heldQty.Add(qty)
+		}
+		if heldQty.String() != test.wantQty {
+			t.Errorf("%s: holdings quantity = %s, want %s", test.name, heldQty, test.wantQty)
+		}
+
+		sold, err := applyCorporateActions([]data.Transaction{
+			testTxn(test.companyId, data.TxnTypeBuy, "300", "100", "2024-01-01"),
+			testTxn(test.companyId, data.TxnTypeSell, test.sellQty, "75", "2024-01-03"),
+		})
+		if err != nil {
+			t.Errorf("%s: error %v", test.name, err)
+			continue
+		}
+		positions, order, err = BuildPositions(sold)
+		if err != nil || !positions[order[0]].Quantity.IsZero() {
+			t.Errorf("%s: selling %s after action gave %v %v", test.name, test.sellQty, positions, err)
+		}
+
+		series, err := buildNavSeries(held, data.ReturnTypePrice, testDate("2024-01-04"))
+		if err != nil || series.latestNav.String() != "10" || series.value.String() != "30000" {
+			t.Errorf("%s: nav %s value %s err %v, want 10 and 30000", test.name, series.latestNav, series.value, err)
+		}
+	}
+}
+
+func TestParseSplitRatio(t *testing.T) {
+	tests := []struct {
+		input    string
+		wantTo   string
+		wantFrom string
+		wantErr  bool
+	}{
+		{"2", "2", "1", false},
+		{"0.5", "0.5", "1", false},
+		{"4/3", "4", "3", false},
+		{"1/3", "1", "3", false},
+		{"1/0", "", "", true},
+		{"0", "", "", true},
+		{"1/2/3", "", "", true},
+		{"a/3", "", "", true},
+	}
+	for _, test := range tests {
+		ratioTo, ratioFrom, err := parseSplitRatio(test.input)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("parseSplitRatio(%q) = %s/%s, want error", test.input, ratioTo, ratioFrom)
+			}
+			continue
+		}
+		if err != nil || ratioTo.String() != test.wantTo || ratioFrom.String() != test.wantFrom {
+			t.Errorf("parseSplitRatio(%q) = %s/%s %v, want %s/%s", test.input, ratioTo, ratioFrom, err, test.wantTo, test.wantFrom)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/vijayyogesh/PortfolioApis/constants"
+)
+
+/* Returned without hitting provider while circuit breaker is open */
+var errCircuitOpen = errors.New("provider circuit breaker open")
+
+/* Provider responded with a status that is not OK */
+type httpStatusError struct {
+	StatusCode int
+	Status     string
+	RetryAfter time.Duration
+}
+
+func (err *httpStatusError) Error() string {
+	return "bad status: " + err.Status
+}
+
+/* Rate limits and server errors are worth retrying, other statuses will fail again */
+func (err *httpStatusError) retryable() bool {
+	return err.StatusCode == http.StatusTooManyRequests || err.StatusCode >= http.StatusInternalServerError
+}
+
+/* -------------------------------------- */
+/* RATE LIMITER */
+
+/* Token bucket refilled at rate tokens per second holding upto burst tokens */
+type rateLimiter struct {
+	mutex    sync.Mutex
+	rate     float64
+	burst    float64
+	tokens   float64
+	lastFill time.Time
+}
+
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{rate: rate, burst: float64(burst), tokens: float64(burst), lastFill: time.Now()}
+}
+
+/* Block till a token is available. Non positive rate means no limit */
+func (limiter *rateLimiter) Wait() {
+	if limiter.rate <= 0 {
+		return
+	}
+	for {
+		limiter.mutex.Lock()
+		now := time.Now()
+		limiter.tokens += now.Sub(limiter.lastFill).Seconds() * limiter.rate
+		if limiter.tokens > limiter.burst {
+			limiter.tokens = limiter.burst
+		}
+		limiter.lastFill = now
+		if limiter.tokens >= 1 {
+			limiter.tokens--
+			limiter.mutex.Unlock()
+			return
+		}
+		wait := time.Duration((1 - limiter.tokens) / limiter.rate * float64(time.Second))
+		limiter.mutex.Unlock()
+		time.Sleep(wait)
+	}
+}
+
+/* -------------------------------------- */
+/* CIRCUIT BREAKER */
+
+/* Opens after threshold consecutive failures and lets one trial request through after cooldown */
+type circuitBreaker struct {
+	mutex     sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	openUntil time.Time
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown}
+}
+
+func (breaker *circuitBreaker) Allow() error {
+	breaker.mutex.Lock()
+	defer breaker.mutex.Unlock()
+	if breaker.threshold <= 0 || breaker.failures < breaker.threshold {
+		return nil
+	}
+	if time.Now().Before(breaker.openUntil) {
+		return errCircuitOpen
+	}
+	/* Half open, next failure opens it again for another cooldown */
+	breaker.openUntil = time.Now().Add(breaker.cooldown)
+	return nil
+}
+
+func (breaker *circuitBreaker) Success() {
+	breaker.mutex.Lock()
+	defer breaker.mutex.Unlock()
+	breaker.failures = 0
+}
+
+func (breaker *circuitBreaker) Failure() {
+	breaker.mutex.Lock()
+	defer breaker.mutex.Unlock()
+	breaker.failures++
+	if breaker.threshold > 0 && breaker.failures >= breaker.threshold {
+		breaker.openUntil = time.Now().Add(breaker.cooldown)
+	}
+}
+
+/* -------------------------------------- */
+/* PROVIDER CLIENT */
+
+/* HTTP client shared by providers. Requests are rate limited and retried with jittered exponential backoff on 429/5xx */
+type providerClient struct {
+	httpClient  *http.Client
+	limiter     *rateLimiter
+	breaker     *circuitBreaker
+	maxRetries  int
+	baseBackoff time.Duration
+	maxBackoff  time.Duration
+}
+
+/* Client from config, constants are used for settings not configured */
+func newProviderClient() *providerClient {
+	config := appUtil.Config
+	rate := config.ProviderRatePerSec
+	if rate == 0 {
+		rate = constants.AppProviderRatePerSec
+	}
+	burst := config.ProviderBurst
+	if burst <= 0 {
+		burst = constants.AppProviderBurst
+	}
+	maxRetries := config.ProviderMaxRetries
+	if maxRetries <= 0 {
+		maxRetries = constants.AppProviderMaxRetries
+	}
+	threshold := config.ProviderBreakerThreshold
+	if threshold <= 0 {
+		threshold = constants.AppProviderBreakerThreshold
+	}
+	cooldownSecs := config.ProviderBreakerCooldownSecs
+	if cooldownSecs <= 0 {
+		cooldownSecs = constants.AppProviderBreakerCooldownSecs
+	}
+	return &providerClient{
+		httpClient:  &http.Client{Timeout: 60 * time.Second},
+		limiter:     newRateLimiter(rate, burst),
+		breaker:     newCircuitBreaker(threshold, time.Duration(cooldownSecs)*time.Second),
+		maxRetries:  maxRetries,
+		baseBackoff: time.Second,
+		maxBackoff:  30 * time.Second,
+	}
+}
+
+/* GET url and return body when status is OK */
+func (client *providerClient) Get(url string) (io.ReadCloser, error) {
+	var err error
+	for attempt := 0; attempt <= client.maxRetries; attempt++ {
+		if attempt > 0 {
+			wait := client.backoff(attempt, err)
+			appUtil.AppLogger.Printf("Retrying url %s in %v after attempt %d failed: %v ", url, wait, attempt, err)
+			time.Sleep(wait)
+		}
+		if errBreaker := client.breaker.Allow(); errBreaker != nil {
+			return nil, errBreaker
+		}
+		client.limiter.Wait()
+
+		var body io.ReadCloser
+		body, err = client.get(url)
+		if err == nil {
+			client.breaker.Success()
+			return body, nil
+		}
+
+		var statusErr *httpStatusError
+		if errors.As(err, &statusErr) && !statusErr.retryable() {
+			/* Provider is up, the request itself is bad (eg: unknown ticker) */
+			client.breaker.Success()
+			return nil, err
+		}
+		client.breaker.Failure()
+	}
+	return nil, fmt.Errorf("giving up on url %s after %d attempts: %v", url, client.maxRetries+1, err)
+}
+
+func (client *providerClient) get(url string) (io.ReadCloser, error) {
+	resp, err := client.httpClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+
+	/* Check server response */
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		statusErr := &httpStatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+		if seconds, errParse := strconv.Atoi(resp.Header.Get("Retry-After")); errParse == nil && seconds > 0 {
+			statusErr.RetryAfter = time.Duration(seconds) * time.Second
+		}
+		return nil, statusErr
+	}
+	return resp.Body, nil
+}
+
+/* Full jitter over exponential backoff, Retry-After of provider is honoured when longer */
+func (client *providerClient) backoff(attempt int, err error) time.Duration {
+	backoff := client.baseBackoff << uint(attempt-1)
+	if backoff > client.maxBackoff || backoff <= 0 {
+		backoff = client.maxBackoff
+	}
+	wait := time.Duration(rand.Int63n(int64(backoff)) + 1)
+
+	var statusErr *httpStatusError
+	if errors.As(err, &statusErr) && statusErr.RetryAfter > wait {
+		wait = statusErr.RetryAfter
+	}
+	return wait
+}
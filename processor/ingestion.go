@@ -0,0 +1,458 @@
+package processor
+
+import (
+	"compress/gzip"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vijayyogesh/PortfolioApis/constants"
+	"github.com/vijayyogesh/PortfolioApis/data"
+	"github.com/vijayyogesh/PortfolioApis/money"
+)
+
+/* Counts of a price ingestion run for a company */
+type ingestStats struct {
+	Rows     int
+	Loaded   int
+	Rejected int
+	Inserted int
+	Updated  int
+}
+
+/* Outcome of an ingestion run. Skipped companies were not attempted as provider circuit breaker was open */
+type IngestSummary struct {
+	Succeeded []string
+	Failed    []string
+	Skipped   []string
+	Errors    []string
+	Inserted  int
+	Updated   int
+	Rejected  int
+}
+
+/* Stream prices of companies from provider straight into DB. No files are written unless archiving is enabled.
+** Companies are downloaded in parallel, hits to provider are paced by the shared rate limiter */
+func IngestPrices(companiesData []data.Company) IngestSummary {
+	var summary IngestSummary
+	var summaryMutex sync.Mutex
+
+	/* Prices and feed corporate actions written from these dates */
+	changedFrom := make(map[string]time.Time)
+
+	/* Refresh window starts from prices actually stored, not from when the company was last attempted */
+	lastPriceDates, err := data.FetchLastPriceDatesDB(appUtil.Db)
+	if err != nil {
+		appUtil.AppLogger.Println(err.Error(), " Error while fetching last price dates")
+		for _, company := range companiesData {
+			summary.Failed = append(summary.Failed, company.CompanyId)
+		}
+		summary.Errors = append(summary.Errors, err.Error())
+		return summary
+	}
+
+	var companyIds []string
+	for _, company := range companiesData {
+		companyIds = append(companyIds, company.CompanyId)
+	}
+
+	/* Caches read by workers are loaded upfront */
+	loadInstruments()
+	initProviders()
+	for _, provider := range priceProviders {
+		if runProvider, isRunScoped := provider.(RunScopedProvider); isRunScoped {
+			runProvider.StartRun(companyIds)
+			defer runProvider.EndRun()
+		}
+	}
+
+	concurrency := appUtil.Config.ProviderConcurrency
+	if concurrency <= 0 {
+		concurrency = constants.AppProviderConcurrency
+	}
+	companies := make(chan data.Company)
+	var wg sync.WaitGroup
+	for worker := 0; worker < concurrency; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for company := range companies {
+				companyId := company.CompanyId
+				fromTime := refreshFrom(company, lastPriceDates)
+				stats, err := ingestCompanyPrices(companyId, fromTime)
+
+				summaryMutex.Lock()
+				summary.Inserted += stats.Inserted
+				summary.Updated += stats.Updated
+				summary.Rejected += stats.Rejected
+				if stats.Inserted+stats.Updated > 0 {
+					changedFrom[companyId] = fromTime
+				}
+				switch {
+				case errors.Is(err, errCircuitOpen):
+					summary.Skipped = append(summary.Skipped, companyId)
+				case err != nil:
+					summary.Failed = append(summary.Failed, companyId)
+					summary.Errors = append(summary.Errors, companyId+": "+err.Error())
+				default:
+					summary.Succeeded = append(summary.Succeeded, companyId)
+				}
+				summaryMutex.Unlock()
+
+				if err != nil {
+					appUtil.AppLogger.Println(err.Error(), " Error while ingesting prices for CompanyId: "+companyId)
+				}
+				appUtil.AppLogger.Printf("CompanyId - %s Rows - %d Loaded - %d Rejected - %d Inserted - %d Updated - %d ",
+					companyId, stats.Rows, stats.Loaded, stats.Rejected, stats.Inserted, stats.Updated)
+			}
+		}()
+	}
+	for _, company := range companiesData {
+		companies <- company
+	}
+	close(companies)
+	wg.Wait()
+
+	appUtil.AppLogger.Printf("Ingestion completed. Succeeded - %d Failed - %d %v Skipped - %d %v Inserted - %d Updated - %d Rejected - %d ",
+		len(summary.Succeeded), len(summary.Failed), summary.Failed, len(summary.Skipped), summary.Skipped,
+		summary.Inserted, summary.Updated, summary.Rejected)
+
+	/* Refetch recent gaps left by this run */
+	if appUtil.Config.DQBackfill {
+		backfillPriceGaps(companyIds)
+	}
+	invalidatePriceCaches(companyIds)
+	invalidateCorporateActionsCache()
+	invalidateCompanySnapshots(changedFrom)
+	return summary
+}
+
+/* Last stored date is fetched again as its close may have been captured during market hours.
+** Complete history is fetched for new companies and on full reload, existing rows are upserted */
+func refreshFrom(company data.Company, lastPriceDates map[string]time.Time) time.Time {
+	lastPriceDate, isPresent := lastPriceDates[company.CompanyId]
+	if !isPresent || company.FullReload {
+		return time.Date(constants.AppPriceHistoryStartYear, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	return lastPriceDate
+}
+
+/* Fetch prices of company from its provider and write them in batches as the response is read */
+func ingestCompanyPrices(companyId string, fromTime time.Time) (ingestStats, error) {
+	var stats ingestStats
+	provider, err := priceProviderFor(companyId)
+	if err != nil {
+		return stats, err
+	}
+
+	prices, err := provider.FetchPrices(companyId, fromTime)
+	if err != nil {
+		return stats, err
+	}
+	defer prices.Close()
+
+	/* Raw payload is copied to archive while it is parsed */
+	var reader io.Reader = prices
+	if appUtil.Config.ArchiveDir != "" {
+		archive, err := openPriceArchive(companyId)
+		if err != nil {
+			return stats, err
+		}
+		defer archive.Close()
+		reader = io.TeeReader(prices, archive)
+	}
+
+	batchSize := appUtil.Config.IngestBatchSize
+	if batchSize <= 0 {
+		batchSize = constants.AppIngestBatchSize
+	}
+	var inserted, updated int
+	stats, err = StreamPriceCsv(reader, companyId, batchSize, func(batch []data.CompaniesPriceData) error {
+		batchInserted, batchUpdated, err := data.LoadPriceDataDB(batch, appUtil.Db)
+		inserted += batchInserted
+		updated += batchUpdated
+		return err
+	})
+	stats.Inserted = inserted
+	stats.Updated = updated
+	if err != nil {
+		return stats, err
+	}
+
+	/* Events are best effort, prices already loaded are kept when they fail */
+	if eventProvider, isPresent := provider.(PriceEventProvider); isPresent {
+		for _, eventType := range []string{data.PriceEventDividend, data.PriceEventSplit} {
+			errEvents := ingestCompanyEvents(eventProvider, companyId, eventType, fromTime)
+			if errEvents != nil {
+				appUtil.AppLogger.Println(errEvents.Error(), " Error while ingesting "+eventType+" events for CompanyId: "+companyId)
+			}
+		}
+	}
+
+	/* Load date records last successful refresh, set only after every batch is committed */
+	err = data.UpdateLoadDate(appUtil.Db, companyId, time.Now())
+	if err != nil {
+		return stats, err
+	}
+	appUtil.AppLogger.Println("Completed ingesting prices from " + provider.Name() + " for company " + companyId)
+	return stats, nil
+}
+
+/* Parse price csv row by row and hand over valid rows to writeBatch in batches of batchSize.
+** Rows with unparseable date or prices (eg: null rows on holidays) are rejected and logged */
+func StreamPriceCsv(reader io.Reader, companyId string, batchSize int, writeBatch func([]data.CompaniesPriceData) error) (ingestStats, error) {
+	var stats ingestStats
+	csvReader := csv.NewReader(reader)
+	csvReader.ReuseRecord = true
+
+	header, err := csvReader.Read()
+	if err == io.EOF {
+		return stats, nil
+	}
+	if err != nil {
+		return stats, fmt.Errorf("error while reading csv header for company %s: %v", companyId, err)
+	}
+	columns, err := priceCsvColumns(header)
+	if err != nil {
+		return stats, fmt.Errorf("%v for company %s", err, companyId)
+	}
+
+	batch := make([]data.CompaniesPriceData, 0, batchSize)
+	for {
+		record, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		stats.Rows++
+		if errors.Is(err, csv.ErrFieldCount) {
+			stats.Rejected++
+			appUtil.AppLogger.Printf("Rejected record %d for Company - %s : %v ", stats.Rows, companyId, err)
+			continue
+		}
+		if err != nil {
+			return stats, fmt.Errorf("error while reading csv for company %s: %v", companyId, err)
+		}
+
+		priceData, errRow := parsePriceRow(record, columns, companyId)
+		if errRow != nil {
+			stats.Rejected++
+			appUtil.AppLogger.Printf("Rejected record %d for Company - %s : %v ", stats.Rows, companyId, errRow)
+			continue
+		}
+
+		batch = append(batch, priceData)
+		if len(batch) == batchSize {
+			if err := writeBatch(batch); err != nil {
+				return stats, err
+			}
+			stats.Loaded += len(batch)
+			batch = batch[:0]
+		}
+	}
+
+	if len(batch) > 0 {
+		if err := writeBatch(batch); err != nil {
+			return stats, err
+		}
+		stats.Loaded += len(batch)
+	}
+	return stats, nil
+}
+
+/* Fetch dividend or split feed of company and upsert its events */
+func ingestCompanyEvents(provider PriceEventProvider, companyId string, eventType string, fromTime time.Time) error {
+	events, err := provider.FetchEvents(companyId, eventType, fromTime)
+	if err != nil {
+		return err
+	}
+	defer events.Close()
+
+	priceEvents, err := ReadPriceEventsCsv(events, companyId, eventType)
+	if err != nil {
+		return err
+	}
+	if len(priceEvents) == 0 {
+		return nil
+	}
+	appUtil.AppLogger.Printf("Loading %d %s events for company %s ", len(priceEvents), eventType, companyId)
+	err = data.LoadPriceEventsDB(priceEvents, appUtil.Db)
+	if err != nil {
+		return err
+	}
+
+	/* Feed events become corporate actions applied to holdings. Cache is reset once the run completes */
+	return data.LoadFeedCorporateActionsDB(priceEvents, appUtil.Db)
+}
+
+/* Parse event feed with header Date,Dividends or Date,Stock Splits. Unparseable rows are rejected and logged */
+func ReadPriceEventsCsv(reader io.Reader, companyId string, eventType string) ([]data.PriceEvent, error) {
+	var priceEvents []data.PriceEvent
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = 2
+
+	header, err := csvReader.Read()
+	if err == io.EOF {
+		return priceEvents, nil
+	}
+	if err != nil {
+		return priceEvents, fmt.Errorf("error while reading events csv header for company %s: %v", companyId, err)
+	}
+	if strings.TrimSpace(header[0]) != "Date" {
+		return priceEvents, fmt.Errorf("column Date missing in events csv for company %s", companyId)
+	}
+
+	for k := 1; ; k++ {
+		record, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if errors.Is(err, csv.ErrFieldCount) {
+			appUtil.AppLogger.Printf("Rejected %s event %d for Company - %s : %v ", eventType, k, companyId, err)
+			continue
+		}
+		if err != nil {
+			return priceEvents, fmt.Errorf("error while reading events csv for company %s: %v", companyId, err)
+		}
+
+		event, errRow := parsePriceEventRow(record, companyId, eventType)
+		if errRow != nil {
+			appUtil.AppLogger.Printf("Rejected %s event %d for Company - %s : %v ", eventType, k, companyId, errRow)
+			continue
+		}
+		priceEvents = append(priceEvents, event)
+	}
+	return priceEvents, nil
+}
+
+func parsePriceEventRow(record []string, companyId string, eventType string) (data.PriceEvent, error) {
+	event := data.PriceEvent{CompanyId: companyId, EventType: eventType}
+	eventDate, err := time.Parse("2006-01-02", record[0])
+	if err != nil {
+		return event, err
+	}
+	event.EventDate = eventDate
+
+	if eventType == data.PriceEventDividend {
+		event.Amount, err = money.Parse(record[1])
+		return event, err
+	}
+
+	/* Split ratio is new shares:old shares, eg: 2:1 */
+	ratio := strings.FieldsFunc(record[1], func(r rune) bool { return r == ':' || r == '/' })
+	if len(ratio) != 2 {
+		return event, fmt.Errorf("invalid split ratio %s", record[1])
+	}
+	event.SplitTo, err = strconv.ParseInt(strings.TrimSpace(ratio[0]), 10, 64)
+	if err != nil {
+		return event, err
+	}
+	event.SplitFrom, err = strconv.ParseInt(strings.TrimSpace(ratio[1]), 10, 64)
+	if err != nil {
+		return event, err
+	}
+	if event.SplitTo <= 0 || event.SplitFrom <= 0 {
+		return event, fmt.Errorf("invalid split ratio %s", record[1])
+	}
+	return event, nil
+}
+
+/* Column positions of price csv header. Date, Open, High, Low, Close are mandatory */
+func priceCsvColumns(header []string) (map[string]int, error) {
+	columns := make(map[string]int)
+	for key, name := range header {
+		columns[strings.TrimSpace(name)] = key
+	}
+	for _, name := range []string{"Date", "Open", "High", "Low", "Close"} {
+		if _, isPresent := columns[name]; !isPresent {
+			return columns, fmt.Errorf("column %s missing in price csv", name)
+		}
+	}
+	return columns, nil
+}
+
+func parsePriceRow(record []string, columns map[string]int, companyId string) (data.CompaniesPriceData, error) {
+	var priceData data.CompaniesPriceData
+	dateVal, err := time.Parse("2006-01-02", record[columns["Date"]])
+	if err != nil {
+		return priceData, err
+	}
+	openVal, err := money.Parse(record[columns["Open"]])
+	if err != nil {
+		return priceData, err
+	}
+	highVal, err := money.Parse(record[columns["High"]])
+	if err != nil {
+		return priceData, err
+	}
+	lowVal, err := money.Parse(record[columns["Low"]])
+	if err != nil {
+		return priceData, err
+	}
+	closeVal, err := money.Parse(record[columns["Close"]])
+	if err != nil {
+		return priceData, err
+	}
+
+	/* Adj Close and Volume are optional columns */
+	adjCloseVal := money.Zero
+	if key, isPresent := columns["Adj Close"]; isPresent {
+		adjCloseVal, err = money.Parse(record[key])
+		if err != nil {
+			return priceData, err
+		}
+	}
+	var volume int64
+	if key, isPresent := columns["Volume"]; isPresent {
+		volume, err = strconv.ParseInt(record[key], 10, 64)
+		if err != nil {
+			return priceData, err
+		}
+	}
+
+	/* Zero/negative prices and inverted ranges are bad rows, not holidays */
+	if openVal.Sign() <= 0 || highVal.Sign() <= 0 || lowVal.Sign() <= 0 || closeVal.Sign() <= 0 || adjCloseVal.Sign() < 0 || volume < 0 {
+		return priceData, fmt.Errorf("non positive price or negative volume on %s", record[columns["Date"]])
+	}
+	if lowVal.GreaterThan(highVal) {
+		return priceData, fmt.Errorf("low %s above high %s on %s", lowVal, highVal, record[columns["Date"]])
+	}
+
+	return data.CompaniesPriceData{CompanyId: companyId, DateVal: dateVal, OpenVal: openVal, HighVal: highVal, LowVal: lowVal, CloseVal: closeVal,
+		AdjCloseVal: adjCloseVal, Volume: volume}, nil
+}
+
+/* Gzipped copy of raw provider payload at <APP_ARCHIVE_DIR>/<companyId>/<timestamp>.csv.gz */
+func openPriceArchive(companyId string) (io.WriteCloser, error) {
+	dir := filepath.Join(appUtil.Config.ArchiveDir, companyId)
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Create(filepath.Join(dir, time.Now().Format("20060102150405")+constants.AppDataCsv+".gz"))
+	if err != nil {
+		return nil, err
+	}
+	return &gzipFileWriter{gzip.NewWriter(file), file}, nil
+}
+
+/* Closes gzip stream before underlying file */
+type gzipFileWriter struct {
+	*gzip.Writer
+	file *os.File
+}
+
+func (writer *gzipFileWriter) Close() error {
+	errGzip := writer.Writer.Close()
+	errFile := writer.file.Close()
+	if errGzip != nil {
+		return errGzip
+	}
+	return errFile
+}
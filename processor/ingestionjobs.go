@@ -0,0 +1,147 @@
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/vijayyogesh/PortfolioApis/constants"
+	"github.com/vijayyogesh/PortfolioApis/data"
+)
+
+/* Ingestion runs one job at a time, later jobs stay queued till then */
+var ingestionMutex sync.Mutex
+
+/* Instance recorded against jobs it runs */
+var ingestionJobOwner = ingestionInstance()
+
+func ingestionInstance() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	return fmt.Sprintf("%s:%d", hostname, os.Getpid())
+}
+
+/* Record a queued ingestion job for companies */
+func submitIngestionJob(trigger string, requestedBy string, companies []data.Company) (int64, error) {
+	var companyIds []string
+	for _, company := range companies {
+		companyIds = append(companyIds, company.CompanyId)
+	}
+	return data.AddIngestionJobDB(trigger, requestedBy, companyIds, ingestionJobOwner, appUtil.Db)
+}
+
+/* Ingest prices of companies and record outcome against job */
+func runIngestionJob(jobId int64, companies []data.Company) IngestSummary {
+	stopHeartbeat := heartbeatIngestionJob(jobId)
+	defer stopHeartbeat()
+
+	ingestionMutex.Lock()
+	defer ingestionMutex.Unlock()
+
+	err := data.StartIngestionJobDB(jobId, appUtil.Db)
+	if err != nil {
+		appUtil.AppLogger.Println(err.Error(), " Error while starting ingestion job ", jobId)
+	}
+	summary := IngestPrices(companies)
+	err = data.FinishIngestionJobDB(jobId, ingestionJobResult(summary), appUtil.Db)
+	if err != nil {
+		appUtil.AppLogger.Println(err.Error(), " Error while completing ingestion job ", jobId)
+	}
+	return summary
+}
+
+/* Keep job alive while it waits for or holds ingestion, returns func to stop */
+func heartbeatIngestionJob(jobId int64) func() {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(constants.AppIngestionJobHeartbeatSecs * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				err := data.HeartbeatIngestionJobDB(jobId, appUtil.Db)
+				if err != nil {
+					appUtil.AppLogger.Println(err.Error(), " Error while updating heartbeat of ingestion job ", jobId)
+				}
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+func ingestionJobResult(summary IngestSummary) data.IngestionJobResult {
+	result := data.IngestionJobResult{
+		Status:       data.JobStatusSucceeded,
+		Succeeded:    len(summary.Succeeded),
+		Failed:       len(summary.Failed),
+		Skipped:      len(summary.Skipped),
+		RowsInserted: summary.Inserted,
+		RowsUpdated:  summary.Updated,
+		RowsRejected: summary.Rejected,
+		Errors:       summary.Errors,
+	}
+	if result.Failed+result.Skipped > 0 {
+		result.Status = data.JobStatusPartial
+		if result.Succeeded == 0 {
+			result.Status = data.JobStatusFailed
+		}
+	}
+	return result
+}
+
+/* Status of an ingestion job */
+func GetIngestionJob(userInput []byte) (data.IngestionJob, error) {
+	var jobInput data.IngestionJobInput
+	json.Unmarshal(userInput, &jobInput)
+	jobId, err := strconv.ParseInt(jobInput.JobId, 10, 64)
+	if err != nil {
+		appUtil.AppLogger.Println(err)
+		return data.IngestionJob{}, err
+	}
+	job, err := data.GetIngestionJobDB(jobId, appUtil.Db)
+	if err != nil {
+		appUtil.AppLogger.Println(err)
+		return job, err
+	}
+	if job.JobId == "" {
+		return job, fmt.Errorf("ingestion job %d not found", jobId)
+	}
+	return job, nil
+}
+
+/* Recent ingestion jobs, latest first */
+func GetIngestionJobs(userInput []byte) (data.IngestionJobsOutputJson, error) {
+	var jobsOutput data.IngestionJobsOutputJson
+	var jobInput data.IngestionJobInput
+	json.Unmarshal(userInput, &jobInput)
+	limit, err := strconv.Atoi(jobInput.Limit)
+	if err != nil || limit <= 0 || limit > constants.AppIngestionJobsMaxLimit {
+		limit = constants.AppIngestionJobsLimit
+	}
+	jobsOutput.Jobs, err = data.GetIngestionJobsDB(limit, appUtil.Db)
+	if err != nil {
+		appUtil.AppLogger.Println(err)
+	}
+	return jobsOutput, err
+}
+
+/* Jobs queued or running when their instance stopped are marked failed on startup. Jobs of other running
+** replicas have a recent heartbeat and are not touched */
+func FailInterruptedIngestionJobs() error {
+	staleBefore := time.Now().Add(-constants.AppIngestionJobStaleSecs * time.Second)
+	failed, err := data.FailInterruptedIngestionJobsDB(staleBefore, appUtil.Db)
+	if err != nil {
+		return err
+	}
+	if failed > 0 {
+		appUtil.AppLogger.Println("Marked ", failed, " interrupted ingestion jobs as failed")
+	}
+	return nil
+}
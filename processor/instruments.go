@@ -0,0 +1,182 @@
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/vijayyogesh/PortfolioApis/calendar"
+	"github.com/vijayyogesh/PortfolioApis/constants"
+	"github.com/vijayyogesh/PortfolioApis/data"
+)
+
+/* Admin route to add or edit instruments */
+func AddInstruments(userInput []byte) string {
+	var instrumentsInput data.InstrumentsInputJson
+	err := json.Unmarshal(userInput, &instrumentsInput)
+	if err != nil || len(instrumentsInput.Instruments) == 0 {
+		appUtil.AppLogger.Println(err)
+		return constants.AppErrInvalidInstrument
+	}
+
+	for key := range instrumentsInput.Instruments {
+		errValidate := normalizeInstrument(&instrumentsInput.Instruments[key])
+		if errValidate != nil {
+			appUtil.AppLogger.Println(errValidate)
+			return constants.AppErrInvalidInstrument
+		}
+	}
+
+	err = data.AddInstrumentsDB(instrumentsInput.Instruments, appUtil.Db)
+	if err != nil {
+		appUtil.AppLogger.Println(err)
+		return constants.AppErrAddInstruments
+	}
+
+	/* New companies are picked up by ingestion from master list */
+	invalidateMasterListCaches()
+	return constants.AppSuccessAddInstruments
+}
+
+func GetInstruments(userInput []byte) (data.InstrumentsOutputJson, error) {
+	var instrumentsOutput data.InstrumentsOutputJson
+	instruments, err := data.GetInstrumentsDB(appUtil.Db)
+	if err != nil {
+		appUtil.AppLogger.Println(err)
+		return instrumentsOutput, err
+	}
+	instrumentsOutput.Instruments = instruments
+	return instrumentsOutput, nil
+}
+
+/* Upper case codes, apply defaults and validate exchange/asset class/provider */
+func normalizeInstrument(instrument *data.Instrument) error {
+	instrument.CompanyId = strings.TrimSpace(instrument.CompanyId)
+	instrument.Exchange = strings.ToUpper(strings.TrimSpace(instrument.Exchange))
+	instrument.AssetClass = strings.ToUpper(strings.TrimSpace(instrument.AssetClass))
+	instrument.Currency = strings.ToUpper(strings.TrimSpace(instrument.Currency))
+	instrument.Provider = strings.ToLower(strings.TrimSpace(instrument.Provider))
+
+	if instrument.CompanyId == "" {
+		return fmt.Errorf("company id missing for instrument")
+	}
+	if instrument.Exchange == "" {
+		instrument.Exchange = data.ExchangeNSE
+	}
+	if instrument.AssetClass == "" {
+		instrument.AssetClass = data.AssetClassEquity
+	}
+	if instrument.Currency == "" {
+		instrument.Currency = data.CurrencyINR
+	}
+
+	switch instrument.AssetClass {
+	case data.AssetClassEquity, data.AssetClassETF, data.AssetClassMF, data.AssetClassIndex, data.AssetClassBond, data.AssetClassGold:
+	default:
+		return fmt.Errorf("invalid asset class %s for instrument %s", instrument.AssetClass, instrument.CompanyId)
+	}
+	if len(instrument.Currency) != 3 {
+		return fmt.Errorf("invalid currency %s for instrument %s", instrument.Currency, instrument.CompanyId)
+	}
+	if instrument.Provider != "" {
+		initProviders()
+		if _, isPresent := priceProviders[instrument.Provider]; !isPresent {
+			return fmt.Errorf("unknown provider %s for instrument %s", instrument.Provider, instrument.CompanyId)
+		}
+	}
+	return validateAmfiInstrument(*instrument)
+}
+
+/* Instruments by company, loaded from DB into cache first time. Empty when DB is not reachable */
+func loadInstruments() map[string]data.Instrument {
+	instruments, err := instrumentsCache.GetOrLoad(cacheKeyAll, func() (interface{}, error) {
+		instruments, err := data.GetInstrumentsDB(appUtil.Db)
+		if err != nil {
+			return nil, err
+		}
+		companyInstruments := make(map[string]data.Instrument)
+		for _, instrument := range instruments {
+			companyInstruments[instrument.CompanyId] = instrument
+		}
+		return companyInstruments, nil
+	})
+	if err != nil {
+		appUtil.AppLogger.Println(err)
+		return map[string]data.Instrument{}
+	}
+	return instruments.(map[string]data.Instrument)
+}
+
+/* Instrument metadata of company. Companies without metadata are treated as NSE equities */
+func getInstrument(companyId string) data.Instrument {
+	if instrument, isPresent := loadInstruments()[companyId]; isPresent {
+		return instrument
+	}
+	return data.Instrument{
+		CompanyId:  companyId,
+		Exchange:   data.ExchangeNSE,
+		AssetClass: data.AssetClassEquity,
+		Currency:   data.CurrencyINR,
+	}
+}
+
+/* Yahoo style ticker of instrument when provider symbol is not set */
+func providerSymbol(instrument data.Instrument) string {
+	if instrument.ProviderSymbol != "" {
+		return instrument.ProviderSymbol
+	}
+	if instrument.AssetClass == data.AssetClassIndex && instrument.Exchange == data.ExchangeNSE {
+		return constants.AppDataBenchmarkAppenderText + instrument.CompanyId
+	}
+	if instrument.Exchange == data.ExchangeBSE {
+		return instrument.CompanyId + constants.AppDataSymbolSuffixBSE
+	}
+	return instrument.CompanyId + constants.AppDataSymbolSuffixNSE
+}
+
+/* companyId of a provider ticker, used by stand-in server */
+func companyIdForSymbol(symbol string) string {
+	for companyId, instrument := range loadInstruments() {
+		if providerSymbol(instrument) == symbol {
+			return companyId
+		}
+	}
+	return symbol
+}
+
+/* Benchmark index used for comparing portfolio returns. Config value when it is an index instrument */
+func benchmarkId() string {
+	benchmarkId := appUtil.Config.Benchmark
+	if benchmarkId == "" {
+		return constants.AppDefaultBenchmark
+	}
+	if getInstrument(benchmarkId).AssetClass != data.AssetClassIndex {
+		appUtil.AppLogger.Printf("Benchmark %s is not an index instrument, using %s ", benchmarkId, constants.AppDefaultBenchmark)
+		return constants.AppDefaultBenchmark
+	}
+	return benchmarkId
+}
+
+/* Load holiday calendars of exchanges at startup */
+func LoadCalendars() error {
+	holidayDir := appUtil.Config.HolidayDir
+	if holidayDir == "" {
+		holidayDir = constants.AppHolidayDir
+	}
+	return calendar.LoadHolidays(holidayDir, data.ExchangeNSE, data.ExchangeBSE)
+}
+
+/* Trading calendar of exchange where company is listed */
+func exchangeCalendar(companyId string) *calendar.Calendar {
+	return calendar.For(getInstrument(companyId).Exchange)
+}
+
+/* Is user allowed to manage instruments */
+func IsAdminUser(userId string) bool {
+	for _, adminUser := range strings.Split(appUtil.Config.AdminUsers, ",") {
+		if userId != "" && strings.TrimSpace(adminUser) == userId {
+			return true
+		}
+	}
+	return false
+}
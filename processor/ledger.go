@@ -0,0 +1,283 @@
+package processor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vijayyogesh/PortfolioApis/data"
+	"github.com/vijayyogesh/PortfolioApis/money"
+)
+
+/* Running position of a company derived from the ledger (average cost method) */
+type Position struct {
+	PortfolioId string
+	Companyid   string
+	CompanyName string
+	Quantity    money.Decimal
+	CostBasis   money.Decimal
+	RealizedPL  money.Decimal
+	Dividends   money.Decimal
+	FirstDate   string
+}
+
+/* Parse ledger/holding dates. DB returns RFC3339 while user input is plain date */
+func parseTxnDate(dateStr string) (time.Time, error) {
+	txnDate, err := time.Parse("2006-01-02T15:04:05Z", dateStr)
+	if err != nil {
+		return time.Parse("2006-01-02", dateStr)
+	}
+	return txnDate, nil
+}
+
+/* Fees, Brokerage and Taxes of a transaction */
+func transactionCharges(txn data.Transaction) (money.Decimal, error) {
+	charges := money.Zero
+	for _, val := range []string{txn.Fees, txn.Brokerage, txn.Taxes} {
+		if val == "" {
+			continue
+		}
+		charge, err := money.Parse(val)
+		if err != nil {
+			return money.Zero, err
+		}
+		charges = charges.Add(charge)
+	}
+	return charges, nil
+}
+
+/* Quantity, price and total charges of a transaction. Quantity of a SPLIT is its ratio rounded, units are scaled with splitQuantity */
+func parseTransaction(txn data.Transaction) (money.Decimal, money.Decimal, money.Decimal, error) {
+	var qty money.Decimal
+	var err error
+	if txn.TxnType == data.TxnTypeSplit {
+		var ratioTo, ratioFrom money.Decimal
+		ratioTo, ratioFrom, err = parseSplitRatio(txn.Quantity)
+		qty = ratioTo.Div(ratioFrom)
+	} else {
+		qty, err = money.Parse(txn.Quantity)
+	}
+	if err != nil {
+		return money.Zero, money.Zero, money.Zero, err
+	}
+	price := money.Zero
+	if txn.Price != "" {
+		price, err = money.Parse(txn.Price)
+		if err != nil {
+			return money.Zero, money.Zero, money.Zero, err
+		}
+	}
+	charges, err := transactionCharges(txn)
+	if err != nil {
+		return money.Zero, money.Zero, money.Zero, err
+	}
+	return qty, price, charges, nil
+}
+
+/* New and old units of a SPLIT. Ratios without an exact decimal, like 4/3 of a 1:3 bonus, are kept as new/old */
+func parseSplitRatio(quantity string) (money.Decimal, money.Decimal, error) {
+	parts := strings.Split(quantity, "/")
+	if len(parts) > 2 {
+		return money.Zero, money.Zero, fmt.Errorf("invalid split ratio %s", quantity)
+	}
+	ratioTo, err := money.Parse(parts[0])
+	if err != nil {
+		return money.Zero, money.Zero, err
+	}
+	ratioFrom := money.NewFromInt(1)
+	if len(parts) == 2 {
+		ratioFrom, err = money.Parse(parts[1])
+		if err != nil {
+			return money.Zero, money.Zero, err
+		}
+	}
+	if ratioTo.Sign() <= 0 || ratioFrom.Sign() <= 0 {
+		return money.Zero, money.Zero, fmt.Errorf("invalid split ratio %s", quantity)
+	}
+	return ratioTo, ratioFrom, nil
+}
+
+/* Units held after a SPLIT, scaled by its exact ratio so that 300 units after a 1:3 bonus are 400 */
+func splitQuantity(quantity money.Decimal, txn data.Transaction) (money.Decimal, error) {
+	ratioTo, ratioFrom, err := parseSplitRatio(txn.Quantity)
+	if err != nil {
+		return quantity, err
+	}
+	return quantity.MulDiv(ratioTo, ratioFrom), nil
+}
+
+/* Legacy Holdings payload encodes a Sell as negative quantity with sell price in BuyPrice */
+func HoldingsToTransactions(holdings []data.Holdings) ([]data.Transaction, error) {
+	var transactions []data.Transaction
+	for _, holding := range holdings {
+		qty, err := money.Parse(holding.Quantity)
+		if err != nil {
+			return transactions, err
+		}
+		txnType := data.TxnTypeBuy
+		if qty.Sign() < 0 {
+			txnType = data.TxnTypeSell
+			qty = qty.Neg()
+		}
+		transactions = append(transactions, data.Transaction{
+			Companyid: holding.Companyid,
+			TxnType:   txnType,
+			Quantity:  qty.String(),
+			Price:     holding.BuyPrice,
+			TxnDate:   holding.BuyDate,
+		})
+	}
+	return transactions, nil
+}
+
+/* Check type, quantity, price and date of each ledger entry */
+func validateTransaction(txn data.Transaction) error {
+	switch txn.TxnType {
+	case data.TxnTypeBuy, data.TxnTypeSell, data.TxnTypeDividend, data.TxnTypeSplit:
+	default:
+		return fmt.Errorf("invalid transaction type %s for company %s", txn.TxnType, txn.Companyid)
+	}
+	if txn.Companyid == "" {
+		return fmt.Errorf("company id missing for %s transaction", txn.TxnType)
+	}
+	/* Ledger stores quantity as a number, new/old ratios are only derived from corporate actions */
+	if strings.Contains(txn.Quantity, "/") {
+		return fmt.Errorf("quantity must be a number for company %s", txn.Companyid)
+	}
+	qty, price, charges, err := parseTransaction(txn)
+	if err != nil {
+		return err
+	}
+	if qty.Sign() <= 0 {
+		return fmt.Errorf("quantity must be positive for company %s", txn.Companyid)
+	}
+	if price.Sign() < 0 || charges.Sign() < 0 {
+		return fmt.Errorf("price and charges cannot be negative for company %s", txn.Companyid)
+	}
+	if _, err := parseTxnDate(txn.TxnDate); err != nil {
+		return err
+	}
+	if txn.SettlementDate != "" {
+		if _, err := parseTxnDate(txn.SettlementDate); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/* Order ledger entries by trade date, keeping insertion order for the same day */
+func sortTransactions(transactions []data.Transaction) {
+	sort.SliceStable(transactions, func(i, j int) bool {
+		iDate, _ := parseTxnDate(transactions[i].TxnDate)
+		jDate, _ := parseTxnDate(transactions[j].TxnDate)
+		return iDate.Before(jDate)
+	})
+}
+
+/* Positions are tracked per portfolio and company */
+func positionKey(txn data.Transaction) string {
+	return txn.PortfolioId + ":" + txn.Companyid
+}
+
+/* Replay ledger and derive running position per portfolio/company. Transactions must be date ordered.
+** Returns positions along with position keys in order of first appearance */
+func BuildPositions(transactions []data.Transaction) (map[string]*Position, []string, error) {
+	positions := make(map[string]*Position)
+	var order []string
+
+	for _, txn := range transactions {
+		key := positionKey(txn)
+		position, isPresent := positions[key]
+		if !isPresent {
+			position = &Position{PortfolioId: txn.PortfolioId, Companyid: txn.Companyid, CompanyName: txn.CompanyName, FirstDate: txn.TxnDate}
+			positions[key] = position
+			order = append(order, key)
+		}
+
+		qty, price, charges, err := parseTransaction(txn)
+		if err != nil {
+			return positions, order, err
+		}
+
+		switch txn.TxnType {
+		case data.TxnTypeBuy:
+			position.Quantity = position.Quantity.Add(qty)
+			position.CostBasis = position.CostBasis.Add(qty.Mul(price)).Add(charges)
+		case data.TxnTypeSell:
+			if qty.GreaterThan(position.Quantity) {
+				return positions, order, fmt.Errorf("sell quantity %s exceeds holding %s for company %s on %s",
+					qty, position.Quantity, txn.Companyid, txn.TxnDate)
+			}
+			soldCost := position.CostBasis.MulDiv(qty, position.Quantity)
+			position.RealizedPL = position.RealizedPL.Add(qty.Mul(price)).Sub(charges).Sub(soldCost)
+			position.CostBasis = position.CostBasis.Sub(soldCost)
+			position.Quantity = position.Quantity.Sub(qty)
+		case data.TxnTypeDividend:
+			position.Dividends = position.Dividends.Add(qty.Mul(price)).Sub(charges)
+		case data.TxnTypeSplit:
+			/* Cost basis is unchanged, only units are scaled */
+			position.Quantity, err = splitQuantity(position.Quantity, txn)
+			if err != nil {
+				return positions, order, err
+			}
+		}
+	}
+	return positions, order, nil
+}
+
+/* Convert ledger to dated lots used by networth/xirr calculations.
+** Buy lots are positive, Sell lots negative (net sell price in BuyPrice) and
+** Split lots add the extra units at zero cost. Dividends carry no units. */
+func TransactionsToHoldings(transactions []data.Transaction) ([]data.Holdings, error) {
+	var holdings []data.Holdings
+	runningQty := make(map[string]money.Decimal)
+
+	for _, txn := range transactions {
+		qty, price, charges, err := parseTransaction(txn)
+		if err != nil {
+			return holdings, err
+		}
+
+		key := positionKey(txn)
+		holding := data.Holdings{
+			Companyid:   txn.Companyid,
+			CompanyName: txn.CompanyName,
+			BuyDate:     txn.TxnDate,
+			TxnType:     txn.TxnType,
+		}
+
+		switch txn.TxnType {
+		case data.TxnTypeBuy:
+			runningQty[key] = runningQty[key].Add(qty)
+			holding.Quantity = qty.String()
+			holding.BuyPrice = price.Add(charges.Div(qty)).String()
+		case data.TxnTypeSell:
+			runningQty[key] = runningQty[key].Sub(qty)
+			holding.Quantity = qty.Neg().String()
+			holding.BuyPrice = price.Sub(charges.Div(qty)).String()
+		case data.TxnTypeSplit:
+			splitQty, err := splitQuantity(runningQty[key], txn)
+			if err != nil {
+				return holdings, err
+			}
+			addedQty := splitQty.Sub(runningQty[key])
+			runningQty[key] = splitQty
+			holding.Quantity = addedQty.String()
+			holding.BuyPrice = "0"
+		default:
+			continue
+		}
+		holdings = append(holdings, holding)
+	}
+	return holdings, nil
+}
+
+/* Cash credited by a Sell or Dividend transaction, net of charges */
+func transactionProceeds(txn data.Transaction) (money.Decimal, error) {
+	qty, price, charges, err := parseTransaction(txn)
+	if err != nil {
+		return money.Zero, err
+	}
+	return qty.Mul(price).Sub(charges), nil
+}
@@ -0,0 +1,263 @@
+package processor
+
+import (
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/vijayyogesh/PortfolioApis/constants"
+	"github.com/vijayyogesh/PortfolioApis/data"
+	"github.com/vijayyogesh/PortfolioApis/money"
+	"github.com/vijayyogesh/PortfolioApis/timeseries"
+)
+
+/* Unitized NAV of a portfolio by day. NAV starts at ReturnBaseValue, units are issued at NAV of the day
+** on every Buy and redeemed on every Sell, so NAV moves only with returns and not with flows */
+type navSeries struct {
+	nav       map[string]money.Decimal
+	startDate time.Time
+	latestNav money.Decimal
+	units     money.Decimal
+	value     money.Decimal
+	invested  money.Decimal
+}
+
+/* Bound on NAV well within decimal range */
+const maxNav = 1e12
+
+/* Position held while building NAV. Last traded price values it on days without a close */
+type navPosition struct {
+	qty       money.Decimal
+	lastPrice money.Decimal
+	prices    *timeseries.Cursor
+}
+
+/* NAV of each day from first transaction till now with holdings valued at close. For total return dividends are
+** paid out redeeming units, so NAV keeps them. Close is not adjusted for dividends, hence they are not counted twice */
+func buildNavSeries(transactions []data.Transaction, returnType string, now time.Time) (navSeries, error) {
+	series := navSeries{nav: make(map[string]money.Decimal), latestNav: money.NewFromInt(constants.ReturnBaseValue)}
+	if len(transactions) == 0 {
+		return series, nil
+	}
+
+	txnDateMap := make(map[string][]data.Transaction)
+	for _, txn := range transactions {
+		txnDate, err := parseTxnDate(txn.TxnDate)
+		if err != nil {
+			return series, err
+		}
+		if series.startDate.IsZero() || txnDate.Before(series.startDate) {
+			series.startDate = txnDate
+		}
+		dateStr := txnDate.Format("2006-01-02")
+		txnDateMap[dateStr] = append(txnDateMap[dateStr], txn)
+	}
+
+	positions := make(map[string]*navPosition)
+	nav := money.NewFromInt(constants.ReturnBaseValue)
+	units := money.Zero
+	value := money.Zero
+
+	for date := series.startDate; date.Before(now); date = date.AddDate(0, 0, 1) {
+		dateStr := date.Format("2006-01-02")
+
+		/* NAV of the day is from holdings carried into the day, flows of the day are at this NAV. Close of the day
+		** is after its splits and dividends, so splits are applied first and dividends are part of the NAV */
+		dividends := money.Zero
+		for _, txn := range txnDateMap[dateStr] {
+			switch txn.TxnType {
+			case data.TxnTypeSplit:
+				position, isPresent := positions[txn.Companyid]
+				if !isPresent {
+					continue
+				}
+				ratioTo, ratioFrom, err := parseSplitRatio(txn.Quantity)
+				if err != nil {
+					return series, err
+				}
+				position.qty = position.qty.MulDiv(ratioTo, ratioFrom)
+				position.lastPrice = position.lastPrice.MulDiv(ratioFrom, ratioTo)
+			case data.TxnTypeDividend:
+				if returnType != data.ReturnTypeTotal {
+					continue
+				}
+				proceeds, err := transactionProceeds(txn)
+				if err != nil {
+					return series, err
+				}
+				dividends = dividends.Add(proceeds)
+			}
+		}
+		value = navPositionsValue(positions, date)
+		nav = navOf(value.Add(dividends), units, nav)
+
+		for _, txn := range txnDateMap[dateStr] {
+			position, isPresent := positions[txn.Companyid]
+			if !isPresent {
+				position = &navPosition{prices: FetchCompaniesCompletePrice(txn.Companyid, appUtil.Db).Cursor()}
+				positions[txn.Companyid] = position
+			}
+
+			qty, price, charges, err := parseTransaction(txn)
+			if err != nil {
+				return series, err
+			}
+			switch txn.TxnType {
+			case data.TxnTypeBuy:
+				amount := qty.Mul(price).Add(charges)
+				units = units.Add(amount.Div(nav))
+				series.invested = series.invested.Add(amount)
+				position.qty = position.qty.Add(qty)
+				position.lastPrice = price
+			case data.TxnTypeSell:
+				proceeds := qty.Mul(price).Sub(charges)
+				units = units.Sub(proceeds.Div(nav))
+				series.invested = series.invested.Sub(proceeds)
+				position.qty = position.qty.Sub(qty)
+				position.lastPrice = price
+			case data.TxnTypeDividend:
+				if returnType != data.ReturnTypeTotal {
+					continue
+				}
+				proceeds := qty.Mul(price).Sub(charges)
+				units = units.Sub(proceeds.Div(nav))
+			}
+		}
+
+		/* Units left over after every holding is sold are rounding of trade vs close price */
+		if !navHasHoldings(positions) || units.Sign() < 0 {
+			units = money.Zero
+		}
+		value = navPositionsValue(positions, date)
+		nav = navOf(value, units, nav)
+		series.nav[dateStr] = nav
+	}
+	series.latestNav = nav
+	series.units = units
+	series.value = value
+	return series, nil
+}
+
+/* Value of positions at close of date */
+func navPositionsValue(positions map[string]*navPosition, date time.Time) money.Decimal {
+	value := money.Zero
+	for _, position := range positions {
+		if position.qty.IsZero() {
+			continue
+		}
+		price, ok := position.prices.AsOf(date)
+		if !ok || price.Sign() <= 0 {
+			price = position.lastPrice
+		}
+		value = value.Add(position.qty.Mul(price))
+	}
+	return value
+}
+
+/* NAV from value and units, previous NAV when there are no units or value or when residual units are too few to
+** give a NAV within decimal range. NAV is kept positive as flows are divided by it */
+func navOf(value money.Decimal, units money.Decimal, nav money.Decimal) money.Decimal {
+	if units.Sign() <= 0 || value.Sign() <= 0 || value.Float64()/units.Float64() > maxNav {
+		return nav
+	}
+	if newNav := value.Div(units); newNav.Sign() > 0 {
+		return newNav
+	}
+	return nav
+}
+
+func navHasHoldings(positions map[string]*navPosition) bool {
+	for _, position := range positions {
+		if position.qty.Sign() > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+/* NAV series of each requested portfolio, along with the consolidated NAV under AllPortfolios when more than one is requested */
+func navSeriesOfUser(user data.User, returnType string) (map[string]navSeries, []int64, error) {
+	navSeriesMap := make(map[string]navSeries)
+
+	isUserPresent, err := verifyUserId(user.UserId, appUtil.Db)
+	if err != nil || !isUserPresent {
+		return navSeriesMap, nil, err
+	}
+	portfolioIds, err := resolvePortfolioIds(user.UserId, user.PortfolioId)
+	if err != nil || len(portfolioIds) == 0 {
+		return navSeriesMap, portfolioIds, err
+	}
+	transactions, err := getPortfoliosTransactions(portfolioIds)
+	if err != nil {
+		return navSeriesMap, portfolioIds, err
+	}
+
+	now := time.Now()
+	portfolioTxnMap := make(map[string][]data.Transaction)
+	for _, txn := range transactions {
+		portfolioTxnMap[txn.PortfolioId] = append(portfolioTxnMap[txn.PortfolioId], txn)
+	}
+	for portfolioId, portfolioTxns := range portfolioTxnMap {
+		series, err := buildNavSeries(portfolioTxns, returnType, now)
+		if err != nil {
+			return navSeriesMap, portfolioIds, err
+		}
+		navSeriesMap[portfolioId] = series
+	}
+	if len(portfolioIds) > 1 {
+		series, err := buildNavSeries(transactions, returnType, now)
+		if err != nil {
+			return navSeriesMap, portfolioIds, err
+		}
+		navSeriesMap[AllPortfolios] = series
+	}
+	return navSeriesMap, portfolioIds, nil
+}
+
+/* NAV series of requested portfolio, consolidated when more than one. Also returns portfolio id label of the view */
+func requestedNavSeries(user data.User, returnType string) (navSeries, string, error) {
+	navSeriesMap, portfolioIds, err := navSeriesOfUser(user, returnType)
+	if err != nil {
+		return navSeries{}, "", err
+	}
+	portfolioId := portfolioIdLabel(portfolioIds, user.PortfolioId)
+	series, isPresent := navSeriesMap[portfolioId]
+	if !isPresent && len(portfolioIds) == 1 {
+		series = navSeriesMap[strconv.FormatInt(portfolioIds[0], 10)]
+	}
+	return series, portfolioId, nil
+}
+
+/* Return of NAV over base value, annualized when held for more than a year */
+func navReturnSummary(series navSeries, now time.Time) data.NavReturnOutputJson {
+	var navReturn data.NavReturnOutputJson
+	if series.startDate.IsZero() {
+		return navReturn
+	}
+	baseValue := money.NewFromInt(constants.ReturnBaseValue)
+	nav := series.latestNav
+
+	navReturn.StartDate = series.startDate.Format("2006-01-02")
+	navReturn.Nav = money.FormatPrice(nav)
+	navReturn.Units = money.FormatQuantity(series.units)
+	navReturn.CurrentValue = money.FormatAmount(series.value)
+	navReturn.NetInvested = money.FormatAmount(series.invested)
+	navReturn.AbsoluteReturn = money.FormatPercent(money.Percent(nav.Sub(baseValue), baseValue))
+
+	navReturn.AnnualizedReturn = money.FormatPercent(annualizedReturn(baseValue, nav, series.startDate, now))
+	return navReturn
+}
+
+/* Percent growth from start value to end value, compounded annually when the period is longer than a year */
+func annualizedReturn(startValue money.Decimal, endValue money.Decimal, startDate time.Time, endDate time.Time) money.Decimal {
+	if !endDate.After(startDate.AddDate(1, 0, 0)) || startValue.Sign() <= 0 || endValue.Sign() <= 0 {
+		return money.Percent(endValue.Sub(startValue), startValue)
+	}
+	years := endDate.Sub(startDate).Hours() / 24 / 365.25
+	annualized := (math.Pow(endValue.Float64()/startValue.Float64(), 1/years) - 1) * 100
+	if math.IsNaN(annualized) || math.IsInf(annualized, 0) {
+		return money.Percent(endValue.Sub(startValue), startValue)
+	}
+	/* Compounded growth is below absolute growth for periods over a year, so it is within decimal range */
+	return money.NewFromFloat(annualized)
+}
@@ -0,0 +1,189 @@
+package processor
+
+import (
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/vijayyogesh/PortfolioApis/data"
+	"github.com/vijayyogesh/PortfolioApis/money"
+	"github.com/vijayyogesh/PortfolioApis/timeseries"
+	"github.com/vijayyogesh/PortfolioApis/util"
+)
+
+func testDate(val string) time.Time {
+	parsed, _ := time.Parse("2006-01-02", val)
+	return parsed
+}
+
+func testDecimal(t *testing.T, val string) money.Decimal {
+	d, err := money.Parse(val)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+/* Series of closes by date, values as strings */
+func testSeries(t *testing.T, closes map[string]string) *timeseries.Series {
+	var dates []time.Time
+	var values []money.Decimal
+	for dateStr, val := range closes {
+		dates = append(dates, testDate(dateStr))
+		values = append(values, testDecimal(t, val))
+	}
+	return timeseries.New(dates, values)
+}
+
+/* Logger is discarded and prices are served from cache instead of DB */
+func setupTestPrices(t *testing.T, prices map[string]map[string]string) {
+	appUtil = &util.AppUtil{AppLogger: log.New(io.Discard, "", 0)}
+	for companyId, closes := range prices {
+		series := testSeries(t, closes)
+		dailyPriceCache.Set(companyId, companyPrices{close: series, totalReturn: series})
+	}
+	t.Cleanup(func() { dailyPriceCache.Invalidate() })
+}
+
+func testTxn(companyId string, txnType string, qty string, price string, txnDate string) data.Transaction {
+	return data.Transaction{Companyid: companyId, TxnType: txnType, Quantity: qty, Price: price, TxnDate: txnDate}
+}
+
+func TestBuildNavSeries(t *testing.T) {
+	setupTestPrices(t, map[string]map[string]string{
+		"AAA": {"2024-01-01": "100", "2024-01-02": "110", "2024-01-03": "120", "2024-01-04": "120", "2024-01-05": "120", "2024-01-08": "90"},
+		"DIV": {"2024-01-01": "100", "2024-01-02": "95", "2024-01-03": "95"},
+		"SPL": {"2024-01-01": "100", "2024-01-02": "55", "2024-01-03": "55"},
+	})
+
+	tests := []struct {
+		name         string
+		returnType   string
+		transactions []data.Transaction
+		now          string
+		wantNav      map[string]string
+		wantUnits    string
+		wantValue    string
+		wantInvested string
+	}{
+		{
+			/* Sell below close leaves residual units which are reset, NAV carries over the flat days to the re-buy */
+			name:       "buy, price move, sell all, re-buy",
+			returnType: data.ReturnTypePrice,
+			transactions: []data.Transaction{
+				testTxn("AAA", data.TxnTypeBuy, "10", "100", "2024-01-01"),
+				testTxn("AAA", data.TxnTypeSell, "10", "118", "2024-01-03"),
+				testTxn("AAA", data.TxnTypeBuy, "5", "90", "2024-01-08"),
+			},
+			now: "2024-01-09T10:00:00Z",
+			wantNav: map[string]string{"2024-01-01": "10", "2024-01-02": "11", "2024-01-03": "12", "2024-01-05": "12",
+				"2024-01-06": "12", "2024-01-08": "12", "2024-01-09": "12"},
+			wantUnits:    "37.5",
+			wantValue:    "450",
+			wantInvested: "270",
+		},
+		{
+			name:       "dividend ignored for price return",
+			returnType: data.ReturnTypePrice,
+			transactions: []data.Transaction{
+				testTxn("DIV", data.TxnTypeBuy, "10", "100", "2024-01-01"),
+				testTxn("DIV", data.TxnTypeDividend, "10", "5", "2024-01-02"),
+			},
+			now:          "2024-01-04",
+			wantNav:      map[string]string{"2024-01-01": "10", "2024-01-02": "9.5", "2024-01-03": "9.5"},
+			wantUnits:    "100",
+			wantValue:    "950",
+			wantInvested: "1000",
+		},
+		{
+			name:       "dividend redeems units for total return",
+			returnType: data.ReturnTypeTotal,
+			transactions: []data.Transaction{
+				testTxn("DIV", data.TxnTypeBuy, "10", "100", "2024-01-01"),
+				testTxn("DIV", data.TxnTypeDividend, "10", "5", "2024-01-02"),
+			},
+			now:          "2024-01-04",
+			wantNav:      map[string]string{"2024-01-01": "10", "2024-01-02": "10", "2024-01-03": "10"},
+			wantUnits:    "95",
+			wantValue:    "950",
+			wantInvested: "1000",
+		},
+		{
+			name:       "split scales quantity without changing units",
+			returnType: data.ReturnTypePrice,
+			transactions: []data.Transaction{
+				testTxn("SPL", data.TxnTypeBuy, "10", "100", "2024-01-01"),
+				testTxn("SPL", data.TxnTypeSplit, "2", "0", "2024-01-02"),
+			},
+			now:          "2024-01-04",
+			wantNav:      map[string]string{"2024-01-01": "10", "2024-01-02": "11", "2024-01-03": "11"},
+			wantUnits:    "100",
+			wantValue:    "1100",
+			wantInvested: "1000",
+		},
+		{
+			/* Buy on ex date is at NAV of split quantity valued at post split close */
+			name:       "buy on split date",
+			returnType: data.ReturnTypePrice,
+			transactions: []data.Transaction{
+				testTxn("SPL", data.TxnTypeBuy, "10", "100", "2024-01-01"),
+				testTxn("SPL", data.TxnTypeSplit, "2", "0", "2024-01-02"),
+				testTxn("SPL", data.TxnTypeBuy, "10", "55", "2024-01-02"),
+			},
+			now:          "2024-01-04",
+			wantNav:      map[string]string{"2024-01-01": "10", "2024-01-02": "11", "2024-01-03": "11"},
+			wantUnits:    "150",
+			wantValue:    "1650",
+			wantInvested: "1550",
+		},
+		{
+			name:         "charges are part of amount invested",
+			returnType:   data.ReturnTypePrice,
+			transactions: []data.Transaction{{Companyid: "AAA", TxnType: data.TxnTypeBuy, Quantity: "10", Price: "100", Fees: "10", TxnDate: "2024-01-01"}},
+			now:          "2024-01-02",
+			wantNav:      map[string]string{"2024-01-01": "9.90099"},
+			wantUnits:    "101",
+			wantValue:    "1000",
+			wantInvested: "1010",
+		},
+	}
+
+	for _, test := range tests {
+		now, err := time.Parse(time.RFC3339, test.now)
+		if err != nil {
+			now = testDate(test.now)
+		}
+		series, err := buildNavSeries(test.transactions, test.returnType, now)
+		if err != nil {
+			t.Errorf("%s: error %v", test.name, err)
+			continue
+		}
+		for dateStr, want := range test.wantNav {
+			got, isPresent := series.nav[dateStr]
+			if !isPresent || got.Round(4).String() != testDecimal(t, want).Round(4).String() {
+				t.Errorf("%s: nav on %s = %s, want %s", test.name, dateStr, got, want)
+			}
+		}
+		if got := series.units.Round(4).String(); got != test.wantUnits {
+			t.Errorf("%s: units = %s, want %s", test.name, got, test.wantUnits)
+		}
+		if got := series.value.String(); got != test.wantValue {
+			t.Errorf("%s: value = %s, want %s", test.name, got, test.wantValue)
+		}
+		if got := series.invested.String(); got != test.wantInvested {
+			t.Errorf("%s: invested = %s, want %s", test.name, got, test.wantInvested)
+		}
+	}
+}
+
+func TestBuildNavSeriesEmpty(t *testing.T) {
+	setupTestPrices(t, nil)
+	series, err := buildNavSeries(nil, data.ReturnTypePrice, testDate("2024-01-02"))
+	if err != nil || len(series.nav) != 0 || !series.startDate.IsZero() {
+		t.Errorf("empty ledger gave nav %v start %s err %v", series.nav, series.startDate, err)
+	}
+	if got := navReturnSummary(series, testDate("2024-01-02")); got.Nav != "" {
+		t.Errorf("summary of empty ledger = %+v", got)
+	}
+}
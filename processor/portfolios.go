@@ -0,0 +1,140 @@
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/vijayyogesh/PortfolioApis/constants"
+	"github.com/vijayyogesh/PortfolioApis/data"
+)
+
+/* Payload value for a consolidated view across portfolios */
+const AllPortfolios = "all"
+
+/* Add a named portfolio for user */
+func AddPortfolio(userInput []byte) string {
+	var portfolioInput data.PortfolioInputJson
+	json.Unmarshal(userInput, &portfolioInput)
+
+	return addPortfolio(portfolioInput)
+}
+
+func addPortfolio(portfolioInput data.PortfolioInputJson) string {
+	isUserPresent, err := verifyUserId(portfolioInput.UserID, appUtil.Db)
+	if err != nil || !isUserPresent {
+		appUtil.AppLogger.Println(err)
+		return constants.AppErrAddUserHoldingsInvalid
+	}
+
+	if portfolioInput.Portfolio.PortfolioName == "" {
+		return constants.AppErrAddPortfolio
+	}
+
+	portfolioId, err := data.AddPortfolioDB(portfolioInput.UserID, portfolioInput.Portfolio, appUtil.Db)
+	if err != nil {
+		appUtil.AppLogger.Println(err)
+		return constants.AppErrAddPortfolio
+	}
+	appUtil.AppLogger.Printf("Added portfolio %d for user %s ", portfolioId, portfolioInput.UserID)
+	return constants.AppSuccessAddPortfolio
+}
+
+/* Update name/target amount of an existing portfolio */
+func UpdatePortfolio(userInput []byte) string {
+	var portfolioInput data.PortfolioInputJson
+	json.Unmarshal(userInput, &portfolioInput)
+
+	return updatePortfolio(portfolioInput)
+}
+
+func updatePortfolio(portfolioInput data.PortfolioInputJson) string {
+	if portfolioInput.Portfolio.PortfolioId == "" {
+		return constants.AppErrInvalidPortfolio
+	}
+	portfolioId, err := resolveWritePortfolioId(portfolioInput.UserID, portfolioInput.Portfolio.PortfolioId)
+	if err != nil {
+		appUtil.AppLogger.Println(err)
+		return constants.AppErrInvalidPortfolio
+	}
+
+	err = data.UpdatePortfolioDB(portfolioId, portfolioInput.Portfolio, appUtil.Db)
+	if err != nil {
+		appUtil.AppLogger.Println(err)
+		return constants.AppErrUpdatePortfolio
+	}
+	return constants.AppSuccessUpdatePortfolio
+}
+
+/* List portfolios of user */
+func GetPortfolios(userInput []byte) (data.PortfoliosOutputJson, error) {
+	var portfoliosOutput data.PortfoliosOutputJson
+
+	var user data.User
+	json.Unmarshal(userInput, &user)
+
+	isUserPresent, err := verifyUserId(user.UserId, appUtil.Db)
+	if err != nil || !isUserPresent {
+		appUtil.AppLogger.Println(err)
+		return portfoliosOutput, err
+	}
+
+	portfolios, err := data.GetPortfoliosDB(user.UserId, appUtil.Db)
+	if err != nil {
+		appUtil.AppLogger.Println(err)
+		return portfoliosOutput, err
+	}
+	portfoliosOutput.UserID = user.UserId
+	portfoliosOutput.Portfolios = portfolios
+	return portfoliosOutput, nil
+}
+
+/* Resolve portfolio id from payload for read routes.
+** Empty or "all" returns every portfolio of the user for a consolidated view */
+func resolvePortfolioIds(userId string, portfolioId string) ([]int64, error) {
+	var portfolioIds []int64
+
+	portfolios, err := data.GetPortfoliosDB(userId, appUtil.Db)
+	if err != nil {
+		return portfolioIds, err
+	}
+
+	for _, portfolio := range portfolios {
+		if portfolioId == "" || portfolioId == AllPortfolios || portfolioId == portfolio.PortfolioId {
+			id, err := data.ParsePortfolioId(portfolio.PortfolioId)
+			if err != nil {
+				return portfolioIds, err
+			}
+			portfolioIds = append(portfolioIds, id)
+		}
+	}
+
+	if len(portfolioIds) == 0 && portfolioId != "" && portfolioId != AllPortfolios {
+		return portfolioIds, fmt.Errorf("portfolio %s not found for user %s", portfolioId, userId)
+	}
+	return portfolioIds, nil
+}
+
+/* Resolve a single portfolio for write routes. Empty defaults to the oldest portfolio of the user */
+func resolveWritePortfolioId(userId string, portfolioId string) (int64, error) {
+	if portfolioId == AllPortfolios {
+		return 0, fmt.Errorf("portfolio id is required, %s is not allowed for updates", AllPortfolios)
+	}
+
+	portfolioIds, err := resolvePortfolioIds(userId, portfolioId)
+	if err != nil {
+		return 0, err
+	}
+	if len(portfolioIds) == 0 {
+		return 0, fmt.Errorf("no portfolio found for user %s", userId)
+	}
+	return portfolioIds[0], nil
+}
+
+/* Portfolio id echoed back in responses */
+func portfolioIdLabel(portfolioIds []int64, requested string) string {
+	if len(portfolioIds) == 1 && requested != "" && requested != AllPortfolios {
+		return strconv.FormatInt(portfolioIds[0], 10)
+	}
+	return AllPortfolios
+}
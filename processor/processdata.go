@@ -2,109 +2,140 @@ package processor
 
 import (
 	"database/sql"
-	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"io"
-	"net/http"
 	"os"
+	"sort"
 	"strconv"
-	"strings"
-	"sync"
-	"sync/atomic"
 	"time"
 
 	"github.com/alpeb/go-finance/fin"
 
+	"github.com/vijayyogesh/PortfolioApis/calendar"
 	"github.com/vijayyogesh/PortfolioApis/constants"
 	"github.com/vijayyogesh/PortfolioApis/data"
+	"github.com/vijayyogesh/PortfolioApis/money"
+	"github.com/vijayyogesh/PortfolioApis/timeseries"
 	"github.com/vijayyogesh/PortfolioApis/util"
 )
 
-var dailyPriceCache map[string]map[string]data.CompaniesPriceData = make(map[string]map[string]data.CompaniesPriceData)
-
-var dailyPriceCacheLatest map[string]data.CompaniesPriceData = make(map[string]data.CompaniesPriceData)
-
-var companiesATHPriceCache map[string]data.CompaniesPriceData
-
-var companiesCache []data.Company
-
-var usersCache map[string]data.User = make(map[string]data.User)
-
 var appUtil *util.AppUtil
 
-var benchmark = "BSE-500"
-
 /* Initializing Processor with required config */
 func InitProcessor(appUtilInput *util.AppUtil) {
 	appUtil = appUtilInput
+	initCaches()
 }
 
 /* -------------------------------------- */
 /* ROUTER METHODS START */
 
 /* 1) Master method that does the following
-** Download data file based on TS
-** Load into DB
+** Stream prices from provider based on TS
+** Load into DB in batches
  */
 func FetchAndUpdatePrices(db *sql.DB) string {
 
-	/* Update only during market hours */
-	hrs, _, _ := time.Now().Clock()
-	if (hrs >= 9 && hrs <= 16) && (time.Now().Weekday() != time.Saturday) && (time.Now().Weekday() != time.Sunday) {
+	/* Update only during market hours of NSE trading days (IST), and a while after close for closing prices */
+	if calendar.For(data.ExchangeNSE).InSession(time.Now(), constants.AppPriceUpdateAfterCloseMins*time.Minute) {
 
 		//Fetch Unique Company Details
 		companiesData, err := FetchCompanies(db)
-		if err == nil {
-			//Download data file
-			DownloadDataAsync(companiesData)
-
-			//Read Data From File & Write into DB asynchronously
-			LoadPriceData(db)
+		if err != nil {
+			appUtil.AppLogger.Println(err)
+			return "Prices not updated as companies could not be fetched"
 		}
-		return "Prices updated successfully"
+		jobId, err := submitIngestionJob(data.JobTriggerScheduled, "", companiesData)
+		if err != nil {
+			appUtil.AppLogger.Println(err)
+			return "Prices not updated as ingestion job could not be recorded"
+		}
+
+		//Stream prices from provider into DB
+		summary := runIngestionJob(jobId, companiesData)
+		return fmt.Sprintf("Prices update job %d completed. Succeeded - %d Failed - %d Skipped - %d",
+			jobId, len(summary.Succeeded), len(summary.Failed), len(summary.Skipped))
 	} else {
 		return "Prices not updated as current time is outside market hours"
 	}
 }
 
-/* 1b) Update Prices for Company */
-func UpdateSelectedCompanies(userInput []byte) string {
+/* 1b) Update Prices for Company. Download runs in background, returns queued job to poll */
+func UpdateSelectedCompanies(userInput []byte) (data.IngestionJob, error) {
 	var CompaniesInput data.CompaniesInput
 	err := json.Unmarshal(userInput, &CompaniesInput)
+	if err == nil && len(CompaniesInput.Company) == 0 {
+		err = fmt.Errorf("no companies provided to update prices")
+	}
+	if err != nil {
+		appUtil.AppLogger.Println(err)
+		return data.IngestionJob{}, err
+	}
 
-	if err == nil {
-		//Download data file
-		DownloadDataAsync(CompaniesInput.Company)
-
-		//Read Data From File & Write into DB asynchronously
-		LoadPriceData(appUtil.Db)
-		return constants.AppSuccessUpdateSelectedCompaniesPrice
-	} else {
-		return constants.AppErrUpdateSelectedCompaniesPrice
+	jobId, err := submitIngestionJob(data.JobTriggerManual, CompaniesInput.UserID, CompaniesInput.Company)
+	if err != nil {
+		appUtil.AppLogger.Println(err)
+		return data.IngestionJob{}, err
 	}
+
+	//Stream prices from provider into DB
+	go runIngestionJob(jobId, CompaniesInput.Company)
+	return data.IngestionJob{JobId: strconv.FormatInt(jobId, 10), Trigger: data.JobTriggerManual, RequestedBy: CompaniesInput.UserID,
+		Status: data.JobStatusQueued, Total: strconv.Itoa(len(CompaniesInput.Company))}, nil
 }
 
 /* 2) Fetch/Update Master Companies List */
 func FetchAndUpdateCompaniesMasterList() string {
 	appUtil.AppLogger.Println("Starting FetchAndUpdateCompaniesMasterList")
 
-	err := DownloadCompaniesMaster()
+	universes, err := configuredUniverses()
 	if err != nil {
 		appUtil.AppLogger.Println(err)
 		return constants.AppErrMasterList
 	}
+	for _, universe := range universes {
+		err := DownloadCompaniesMaster(universe)
+		if err != nil {
+			appUtil.AppLogger.Println(err.Error(), " Error while downloading universe "+universe.Name)
+			return constants.AppErrMasterList
+		}
 
-	errLoad := LoadCompaniesMaster()
-	if errLoad != nil {
-		appUtil.AppLogger.Println(errLoad)
-		return constants.AppErrMasterList
+		errLoad := LoadCompaniesMaster(universe)
+		if errLoad != nil {
+			appUtil.AppLogger.Println(errLoad.Error(), " Error while loading universe "+universe.Name)
+			return constants.AppErrMasterList
+		}
 	}
 
 	appUtil.AppLogger.Println("Completed FetchAndUpdateCompaniesMasterList")
 	return constants.AppSuccessMasterList
 }
 
+/* 2b) Load master list, instruments, corporate actions and prices of traded companies and benchmark into cache */
+func WarmupCaches() string {
+	_, err := FetchCompanies(appUtil.Db)
+	if err != nil {
+		appUtil.AppLogger.Println(err)
+		return constants.AppErrWarmupCaches
+	}
+	loadInstruments()
+	_, err = loadCorporateActions()
+	if err != nil {
+		return constants.AppErrWarmupCaches
+	}
+
+	companyIds, err := data.GetTradedCompanyIdsDB(appUtil.Db)
+	if err != nil {
+		appUtil.AppLogger.Println(err)
+		return constants.AppErrWarmupCaches
+	}
+	for _, companyId := range append(companyIds, benchmarkId()) {
+		FetchCompaniesCompletePrice(companyId, appUtil.Db)
+	}
+	return fmt.Sprintf("Caches loaded with prices of %d companies", len(companyIds)+1)
+}
+
 /* 3) Add User */
 func AddUser(user data.User) string {
 	user.StartDate = time.Now()
@@ -114,8 +145,8 @@ func AddUser(user data.User) string {
 		appUtil.AppLogger.Println(err)
 		return constants.AppErrAddUser
 	}
-	/* Add to cache too */
-	usersCache[user.UserId] = user
+	/* Users are reloaded with new user on next verification */
+	invalidateUsersCache()
 	return constants.AppSuccessAddUser
 }
 
@@ -127,6 +158,11 @@ func AddUserHoldings(userInput []byte) string {
 
 	json.Unmarshal(userInput, &holdingsInput)
 
+	return addUserHoldings(holdingsInput)
+}
+
+/* Record holdings/ledger transactions of a portfolio */
+func addUserHoldings(holdingsInput data.HoldingsInputJson) string {
 	isUserPresent, err := verifyUserId(holdingsInput.UserID, appUtil.Db)
 	if err != nil {
 		return constants.AppErrAddUserHoldings
@@ -135,26 +171,63 @@ func AddUserHoldings(userInput []byte) string {
 	if isUserPresent {
 		appUtil.AppLogger.Println(holdingsInput)
 
-		/* When it is a Sell transaction, move it to cash by default */
-		for _, company := range holdingsInput.Holdings {
-			qty, errQty := strconv.ParseFloat(company.Quantity, 64)
-			sellPrice, errSellPrice := strconv.ParseFloat(company.BuyPrice, 64)
-			if errQty != nil {
-				appUtil.AppLogger.Println(errQty)
-				return constants.AppErrAddUserHoldings
-			}
-			if errSellPrice != nil {
-				appUtil.AppLogger.Println(errSellPrice)
-				return constants.AppErrAddUserHoldings
+		portfolioId, err := resolveWritePortfolioId(holdingsInput.UserID, holdingsInput.PortfolioId)
+		if err != nil {
+			appUtil.AppLogger.Println(err)
+			return constants.AppErrInvalidPortfolio
+		}
+
+		/* Legacy Holdings are recorded as BUY/SELL ledger entries */
+		legacyTransactions, err := HoldingsToTransactions(holdingsInput.Holdings)
+		if err != nil {
+			appUtil.AppLogger.Println(err)
+			return constants.AppErrAddUserHoldings
+		}
+		holdingsInput.Transactions = append(holdingsInput.Transactions, legacyTransactions...)
+
+		for _, txn := range holdingsInput.Transactions {
+			errValidate := validateTransaction(txn)
+			if errValidate != nil {
+				appUtil.AppLogger.Println(errValidate)
+				return constants.AppErrInvalidTransaction
 			}
+		}
 
-			if qty < 0 {
-				value := -qty * sellPrice
+		/* Replay ledger with new entries to reject sells beyond held quantity */
+		existingTransactions, err := data.GetUserTransactionsDB([]int64{portfolioId}, appUtil.Db)
+		if err != nil {
+			appUtil.AppLogger.Println(err)
+			return constants.AppErrAddUserHoldings
+		}
+		for key := range holdingsInput.Transactions {
+			holdingsInput.Transactions[key].PortfolioId = strconv.FormatInt(portfolioId, 10)
+		}
+		combinedTransactions := append(existingTransactions, holdingsInput.Transactions...)
+		sortTransactions(combinedTransactions)
+		combinedTransactions, err = applyCorporateActions(combinedTransactions)
+		if err != nil {
+			appUtil.AppLogger.Println(err)
+			return constants.AppErrAddUserHoldings
+		}
+		_, _, errPositions := BuildPositions(combinedTransactions)
+		if errPositions != nil {
+			appUtil.AppLogger.Println(errPositions)
+			return constants.AppErrInvalidTransaction
+		}
+
+		/* When it is a Sell/Dividend transaction, move proceeds to cash by default */
+		for _, txn := range holdingsInput.Transactions {
+			if txn.TxnType == data.TxnTypeSell || txn.TxnType == data.TxnTypeDividend {
+				value, err := transactionProceeds(txn)
+				if err != nil {
+					appUtil.AppLogger.Println(err)
+					return constants.AppErrAddUserHoldings
+				}
 				holdingsNTCash := data.HoldingsNonTracked{
 					SecurityId:   "CASH",
-					BuyDate:      company.BuyDate,
-					BuyValue:     fmt.Sprintf("%f", value),
-					CurrentValue: fmt.Sprintf("%f", value),
+					BuyDate:      txn.TxnDate,
+					BuyValue:     value.String(),
+					CurrentValue: value.String(),
 					InterestRate: "0",
 				}
 				holdingsInput.HoldingsNT = append(holdingsInput.HoldingsNT, holdingsNTCash)
@@ -162,11 +235,14 @@ func AddUserHoldings(userInput []byte) string {
 		}
 
 		/* Push data to DB */
-		err := data.AddUserHoldingsDB(holdingsInput, appUtil.Db)
-		if err != nil {
-			appUtil.AppLogger.Println(err)
+		errAdd := data.AddUserHoldingsDB(portfolioId, holdingsInput, appUtil.Db)
+		if errAdd != nil {
+			appUtil.AppLogger.Println(errAdd)
 			return constants.AppErrAddUserHoldings
 		}
+
+		/* Back dated entries change snapshots from their date */
+		invalidateSnapshots(holdingsInput.UserID, earliestHoldingsDate(holdingsInput))
 		return constants.AppSuccessAddUserHoldings
 	}
 	return constants.AppErrAddUserHoldingsInvalid
@@ -174,25 +250,68 @@ func AddUserHoldings(userInput []byte) string {
 
 /* 5) Get User Holdings */
 func GetUserHoldings(userInput []byte, aggregateHoldings bool) (data.HoldingsOutputJson, error) {
-	var userHoldings data.HoldingsOutputJson
-
 	var user data.User
 	json.Unmarshal(userInput, &user)
 	appUtil.AppLogger.Println(user)
 
+	return getUserHoldings(user, aggregateHoldings)
+}
+
+/* Holdings of the portfolio(s) requested by user */
+func getUserHoldings(user data.User, aggregateHoldings bool) (data.HoldingsOutputJson, error) {
+	var userHoldings data.HoldingsOutputJson
+
 	isUserPresent, err := verifyUserId(user.UserId, appUtil.Db)
 	if err != nil {
 		appUtil.AppLogger.Println(err)
 		return userHoldings, err
 	}
 
+	var portfolioIds []int64
 	if isUserPresent {
-		holdings, err := data.GetUserHoldingsDB(user.UserId, appUtil.Db)
+		portfolioIds, err = resolvePortfolioIds(user.UserId, user.PortfolioId)
 		if err != nil {
 			appUtil.AppLogger.Println(err)
 			return userHoldings, err
 		}
-		userHoldings = holdings
+	}
+
+	userHoldings, err = getPortfoliosHoldings(portfolioIds, aggregateHoldings)
+	if err != nil {
+		return userHoldings, err
+	}
+	if isUserPresent {
+		userHoldings.UserID = user.UserId
+		userHoldings.PortfolioId = portfolioIdLabel(portfolioIds, user.PortfolioId)
+	}
+	return userHoldings, nil
+}
+
+/* Holdings, networth and allocation across given portfolios */
+func getPortfoliosHoldings(portfolioIds []int64, aggregateHoldings bool) (data.HoldingsOutputJson, error) {
+	var userHoldings data.HoldingsOutputJson
+
+	var transactions []data.Transaction
+	if len(portfolioIds) > 0 {
+		var err error
+		/* Units and cost basis adjusted for splits/bonus, dividends credited */
+		transactions, err = getPortfoliosTransactions(portfolioIds)
+		if err != nil {
+			appUtil.AppLogger.Println(err)
+			return userHoldings, err
+		}
+		holdingsNT, err := data.GetUserHoldingsNTDB(portfolioIds, appUtil.Db)
+		if err != nil {
+			appUtil.AppLogger.Println(err)
+			return userHoldings, err
+		}
+		lots, err := TransactionsToHoldings(transactions)
+		if err != nil {
+			appUtil.AppLogger.Println(err)
+			return userHoldings, err
+		}
+		userHoldings.Holdings = lots
+		userHoldings.HoldingsNT = holdingsNT
 	}
 	errCalc := calculateNetWorthAndAlloc(&userHoldings, appUtil.Db)
 	if errCalc != nil {
@@ -201,7 +320,7 @@ func GetUserHoldings(userInput []byte, aggregateHoldings bool) (data.HoldingsOut
 	}
 
 	if aggregateHoldings {
-		aggregatedHoldings, err := AggregateHoldings(userHoldings)
+		aggregatedHoldings, err := AggregateHoldings(transactions)
 		if err != nil {
 			return userHoldings, err
 		}
@@ -211,12 +330,48 @@ func GetUserHoldings(userInput []byte, aggregateHoldings bool) (data.HoldingsOut
 	return userHoldings, nil
 }
 
+/* 5b) Get User Transactions ledger */
+func GetUserTransactions(userInput []byte) (data.TransactionsOutputJson, error) {
+	var transactionsOutput data.TransactionsOutputJson
+
+	var user data.User
+	json.Unmarshal(userInput, &user)
+
+	isUserPresent, err := verifyUserId(user.UserId, appUtil.Db)
+	if err != nil {
+		appUtil.AppLogger.Println(err)
+		return transactionsOutput, err
+	}
+
+	if isUserPresent {
+		portfolioIds, err := resolvePortfolioIds(user.UserId, user.PortfolioId)
+		if err != nil {
+			appUtil.AppLogger.Println(err)
+			return transactionsOutput, err
+		}
+		transactions, err := data.GetUserTransactionsDB(portfolioIds, appUtil.Db)
+		if err != nil {
+			appUtil.AppLogger.Println(err)
+			return transactionsOutput, err
+		}
+		transactionsOutput.UserID = user.UserId
+		transactionsOutput.PortfolioId = portfolioIdLabel(portfolioIds, user.PortfolioId)
+		transactionsOutput.Transactions = transactions
+	}
+	return transactionsOutput, nil
+}
+
 /* 6) Add model Pf with allocation and Reasonable price */
 func AddModelPortfolio(userInput []byte) string {
 	var modelPf data.ModelPortfolio
 
 	json.Unmarshal(userInput, &modelPf)
 
+	return addModelPortfolio(modelPf)
+}
+
+/* Save model Pf of a portfolio */
+func addModelPortfolio(modelPf data.ModelPortfolio) string {
 	isUserPresent, err := verifyUserId(modelPf.UserID, appUtil.Db)
 	if err != nil {
 		appUtil.AppLogger.Println(err)
@@ -224,7 +379,12 @@ func AddModelPortfolio(userInput []byte) string {
 	}
 
 	if isUserPresent {
-		err := data.AddModelPortfolioDB(modelPf, appUtil.Db)
+		portfolioId, err := resolveWritePortfolioId(modelPf.UserID, modelPf.PortfolioId)
+		if err != nil {
+			appUtil.AppLogger.Println(err)
+			return constants.AppErrInvalidPortfolio
+		}
+		err = data.AddModelPortfolioDB(portfolioId, modelPf, appUtil.Db)
 		if err != nil {
 			appUtil.AppLogger.Println(err)
 			return constants.AppErrAddModelPf
@@ -236,11 +396,16 @@ func AddModelPortfolio(userInput []byte) string {
 
 /* 7) Fetch Model Portfolio for given User */
 func GetModelPortfolio(userInput []byte) (data.ModelPortfolio, error) {
-	var modelPortfolio data.ModelPortfolio
-
 	var user data.User
 	json.Unmarshal(userInput, &user)
 
+	return getModelPortfolio(user)
+}
+
+/* Model Portfolio of the portfolio(s) requested by user */
+func getModelPortfolio(user data.User) (data.ModelPortfolio, error) {
+	var modelPortfolio data.ModelPortfolio
+
 	isUserPresent, err := verifyUserId(user.UserId, appUtil.Db)
 	if err != nil {
 		appUtil.AppLogger.Println(err)
@@ -248,16 +413,22 @@ func GetModelPortfolio(userInput []byte) (data.ModelPortfolio, error) {
 	}
 
 	if isUserPresent {
-		modelPf, err := data.GetModelPortfolioDB(user.UserId, appUtil.Db)
+		portfolioIds, err := resolvePortfolioIds(user.UserId, user.PortfolioId)
+		if err != nil {
+			appUtil.AppLogger.Println(err)
+			return modelPortfolio, err
+		}
+		modelPf, err := data.GetModelPortfolioDB(user.UserId, portfolioIds, appUtil.Db)
 		if err != nil {
 			appUtil.AppLogger.Println(err)
 			return modelPortfolio, err
 		}
 		/* Below block is added for formatting */
 		for key := range modelPf.Securities {
-			secReasonablePrice, _ := strconv.ParseFloat(modelPf.Securities[key].ReasonablePrice, 64)
-			modelPf.Securities[key].ReasonablePrice = fmt.Sprintf("%.2f", secReasonablePrice)
+			secReasonablePrice, _ := money.Parse(modelPf.Securities[key].ReasonablePrice)
+			modelPf.Securities[key].ReasonablePrice = money.FormatPrice(secReasonablePrice)
 		}
+		modelPf.PortfolioId = portfolioIdLabel(portfolioIds, user.PortfolioId)
 		modelPortfolio = modelPf
 	}
 
@@ -271,25 +442,51 @@ func GetPortfolioModelSync(userInput []byte) (data.SyncedPortfolio, error) {
 	var user data.User
 	json.Unmarshal(userInput, &user)
 
-	/* Get Target Amount */
-	targetAmount, err := data.GetTargetAmountDB(user.UserId, appUtil.Db)
+	portfolioIds, err := resolvePortfolioIds(user.UserId, user.PortfolioId)
 	if err != nil {
 		appUtil.AppLogger.Println(err)
 		return syncedPf, err
 	}
 
+	/* Target amount is set per portfolio, hence sync each portfolio separately */
+	for _, portfolioId := range portfolioIds {
+		portfolioUser := user
+		portfolioUser.PortfolioId = strconv.FormatInt(portfolioId, 10)
+
+		adjustedHoldings, err := syncPortfolioWithModel(portfolioUser, portfolioId)
+		if err != nil {
+			appUtil.AppLogger.Println(err)
+			return syncedPf, err
+		}
+		syncedPf.AdjustedHoldings = append(syncedPf.AdjustedHoldings, adjustedHoldings...)
+	}
+
+	return syncedPf, nil
+}
+
+/* Sync Model Pf of a single portfolio with its actual holdings */
+func syncPortfolioWithModel(user data.User, portfolioId int64) ([]data.AdjustedHolding, error) {
+	var adjustedHoldings []data.AdjustedHolding
+
+	/* Get Target Amount */
+	targetAmount, err := data.GetTargetAmountDB(portfolioId, appUtil.Db)
+	if err != nil {
+		appUtil.AppLogger.Println(err)
+		return adjustedHoldings, err
+	}
+
 	/* Get Current Holdings */
-	holdingsOutputJson, err := GetUserHoldings(userInput, true)
+	holdingsOutputJson, err := getUserHoldings(user, true)
 	if err != nil {
 		appUtil.AppLogger.Println(err)
-		return syncedPf, err
+		return adjustedHoldings, err
 	}
 
 	/* Get Model Pf */
-	modelPf, errModelPf := GetModelPortfolio(userInput)
+	modelPf, errModelPf := getModelPortfolio(user)
 	if errModelPf != nil {
 		appUtil.AppLogger.Println(errModelPf)
-		return syncedPf, errModelPf
+		return adjustedHoldings, errModelPf
 	}
 
 	/* For each Model Pf holding
@@ -307,146 +504,163 @@ func GetPortfolioModelSync(userInput []byte) (data.SyncedPortfolio, error) {
 	for _, security := range modelPf.Securities {
 
 		/* For each Model security, Calculate Amount to Be allocated based on expected allocation & target Amount */
-		var amountToBeAllocated float64
-		allocation, parseErr := strconv.ParseFloat(security.ExpectedAllocation, 64)
+		allocation, parseErr := money.Parse(security.ExpectedAllocation)
 		if parseErr != nil {
 			appUtil.AppLogger.Println(parseErr)
-			return syncedPf, parseErr
+			return adjustedHoldings, parseErr
 		}
-		amountToBeAllocated = allocation / 100.0 * targetAmount
+		amountToBeAllocated := targetAmount.MulDiv(allocation, money.NewFromInt(100))
 
 		/* If already present, check if over/under invested */
 		holdings, ok := holdingsMap[security.Securityid]
 		if ok {
 			cumulativeAmount := CalculateCumulativeInvestedAmount(holdings)
-			amountToBeAllocated = (allocation / 100.0 * targetAmount) - cumulativeAmount
+			amountToBeAllocated = amountToBeAllocated.Sub(cumulativeAmount)
 		}
 
 		/* Form Structure stating how much to invest/prune in each model security */
 		var adjustedHolding data.AdjustedHolding
+		adjustedHolding.PortfolioId = user.PortfolioId
 		adjustedHolding.Securityid = security.Securityid
-		adjustedHolding.AdjustedAmount = fmt.Sprintf("%.2f", amountToBeAllocated)
+		adjustedHolding.AdjustedAmount = money.FormatAmount(amountToBeAllocated)
 
 		/* Check if current price is below reasonable price */
-		LoadLatestCompaniesCompletePrice(security.Securityid, appUtil.Db)
-		latestPriceData := dailyPriceCacheLatest[security.Securityid]
-		secReasonablePrice, _ := strconv.ParseFloat(security.ReasonablePrice, 64)
-		percentBRP := (secReasonablePrice - latestPriceData.CloseVal) / secReasonablePrice * 100.0
-		adjustedHolding.PercentBelowReasonablePrice = fmt.Sprintf("%.2f", percentBRP)
-		if latestPriceData.CloseVal < secReasonablePrice {
+		latestPriceData, _ := getLatestPrice(security.Securityid)
+		secReasonablePrice, _ := money.Parse(security.ReasonablePrice)
+		percentBRP := money.Percent(secReasonablePrice.Sub(latestPriceData.CloseVal), secReasonablePrice)
+		adjustedHolding.PercentBelowReasonablePrice = money.FormatPercent(percentBRP)
+		if latestPriceData.CloseVal.LessThan(secReasonablePrice) {
 			adjustedHolding.BelowReasonablePrice = "Y"
 		} else {
 			adjustedHolding.BelowReasonablePrice = "N"
 		}
 
-		syncedPf.AdjustedHoldings = append(syncedPf.AdjustedHoldings, adjustedHolding)
+		adjustedHoldings = append(adjustedHoldings, adjustedHolding)
 	}
 
-	return syncedPf, nil
+	return adjustedHoldings, nil
+}
+
+/* Daily values of networth chart by date */
+type netWorthSeries struct {
+	networth  map[string]money.Decimal
+	equity    map[string]money.Decimal
+	benchmark map[string]money.Decimal
+	debt      map[string]money.Decimal
+	invested  map[string]money.Decimal
 }
 
-/* 9) Fetch NW Periods for given User */
+func newNetWorthSeries() netWorthSeries {
+	return netWorthSeries{
+		networth:  make(map[string]money.Decimal),
+		equity:    make(map[string]money.Decimal),
+		benchmark: make(map[string]money.Decimal),
+		debt:      make(map[string]money.Decimal),
+		invested:  make(map[string]money.Decimal),
+	}
+}
+
+/* 9) Fetch NW Periods for given User. Consolidated view with price return is served from snapshots */
 func FetchNetWorthOverPeriods(userInput []byte) (map[string]map[string]float64, error) {
 	appUtil.AppLogger.Println("Starting FetchNetWorthOverPeriods")
 
 	var combinedOutputMap map[string]map[string]float64 = make(map[string]map[string]float64)
 
-	var networthMap map[string]float64 = make(map[string]float64)
-	var trackedHoldingsMap map[string]float64 = make(map[string]float64)
-	var nonTrackedHoldingsMap map[string]float64 = make(map[string]float64)
-	var allocationMap map[string]float64 = make(map[string]float64)
-	var benchMarkMap map[string]float64 = make(map[string]float64)
-	var amountInvestedMap map[string]float64 = make(map[string]float64)
+	var user data.User
+	json.Unmarshal(userInput, &user)
+	returnType, err := parseReturnType(user.ReturnType)
+	if err != nil {
+		appUtil.AppLogger.Println(err)
+		return combinedOutputMap, err
+	}
+
+	var series netWorthSeries
+	if isSnapshotView(user, returnType) {
+		series, err = netWorthFromSnapshots(user.UserId, userInput)
+	} else {
+		series, err = netWorthOverPeriods(userInput, returnType, time.Time{})
+	}
+	if err != nil {
+		return combinedOutputMap, err
+	}
+
+	combinedOutputMap["networth"] = toChartSeries(series.networth)
+	combinedOutputMap["equity"] = toChartSeries(series.equity)
+	combinedOutputMap["benchmark"] = toChartSeries(series.benchmark)
+	combinedOutputMap["debt"] = toChartSeries(series.debt)
+	combinedOutputMap["invested"] = toChartSeries(series.invested)
+
+	appUtil.AppLogger.Println("Completed FetchNetWorthOverPeriods")
+	return combinedOutputMap, nil
+}
+
+/* Networth, equity, debt, benchmark and invested amount of each day from buy dates, or from date when it is later */
+func netWorthOverPeriods(userInput []byte, returnType string, from time.Time) (netWorthSeries, error) {
+	series := newNetWorthSeries()
+	networthMap := series.networth
+	trackedHoldingsMap := series.equity
+	nonTrackedHoldingsMap := series.debt
+	benchMarkMap := series.benchmark
+	amountInvestedMap := series.invested
 
 	userHoldings, err := GetUserHoldings(userInput, false)
 	appUtil.AppLogger.Println(userHoldings)
 	if err != nil {
 		appUtil.AppLogger.Println(err)
-		return combinedOutputMap, err
+		return series, err
 	}
+	now := time.Now()
+	cal := calendar.For(data.ExchangeNSE)
 	for _, holdings := range userHoldings.Holdings {
-		dailyPriceRecordsMap := FetchCompaniesCompletePrice(holdings.Companyid, appUtil.Db)
+		prices := FetchCompaniesCompletePrice(holdings.Companyid, appUtil.Db).Cursor()
 
-		/* Benchmark changes */
-		benchMarkRecordsMap := FetchCompaniesCompletePrice(benchmark, appUtil.Db)
-		holdingsQty, _ := strconv.ParseFloat(holdings.Quantity, 64)
-		holdingsBuyPrice, _ := strconv.ParseFloat(holdings.BuyPrice, 64)
-		holdingsBuyValue := holdingsBuyPrice * holdingsQty
+		/* Benchmark changes. Price or total return of benchmark as requested */
+		benchMarkPrices := fetchReturnPrices(benchmarkId(), returnType)
+		holdingsQty, _ := money.Parse(holdings.Quantity)
+		holdingsBuyPrice, _ := money.Parse(holdings.BuyPrice)
+		holdingsBuyValue := holdingsBuyPrice.Mul(holdingsQty)
 
 		buyDate, err := time.Parse("2006-01-02T15:04:05Z", holdings.BuyDate)
 
 		/* Benchmark changes */
-		bmDateStr := buyDate.Format("2006-01-02")
-		bmQty := holdingsBuyValue / benchMarkRecordsMap[bmDateStr].CloseVal
+		bmQty := money.Zero
+		if bmBuyClose, ok := benchMarkPrices.AsOf(buyDate); ok && bmBuyClose.Sign() > 0 {
+			bmQty = holdingsBuyValue.Div(bmBuyClose)
+		}
+		benchMarkCursor := benchMarkPrices.Cursor()
 
 		if err != nil {
 			appUtil.AppLogger.Println(err)
-			return combinedOutputMap, err
+			return series, err
 		} else {
-			qty, parseErr := strconv.ParseFloat(holdings.Quantity, 64)
+			qty, parseErr := money.Parse(holdings.Quantity)
 			if parseErr != nil {
 				appUtil.AppLogger.Println(parseErr)
-				return combinedOutputMap, parseErr
+				return series, parseErr
 			}
 
-			/* Loop all dates from Buy Date and calc NW */
-			for buyDate.Before(time.Now()) {
+			/* Loop all dates from Buy Date and calc NW. Holidays use close of previous trading day */
+			if buyDate.Before(from) {
+				buyDate = from
+			}
+			for buyDate.Before(now) {
 				dateStr := buyDate.Format("2006-01-02")
-				dailyData, ok := dailyPriceRecordsMap[dateStr]
-
-				/* Benchmark changes */
-				benchMarkData, bmDataExists := benchMarkRecordsMap[dateStr]
+				priceDate := cal.LatestTradingDay(buyDate)
 
 				/* Amount Invested */
-				investedVal := float64(int((amountInvestedMap[dateStr]+(holdingsBuyValue))*100)) / 100
-				amountInvestedMap[dateStr] = investedVal
+				amountInvestedMap[dateStr] = amountInvestedMap[dateStr].Add(holdingsBuyValue)
 
-				if ok && dailyData.CloseVal != 0 {
-					networthVal := float64(int((networthMap[dateStr]+(dailyData.CloseVal*qty))*100)) / 100
+				if closeVal, ok := prices.AsOf(priceDate); ok {
+					networthVal := networthMap[dateStr].Add(closeVal.Mul(qty))
 					networthMap[dateStr] = networthVal
 					trackedHoldingsMap[dateStr] = networthVal
 
 					/* Benchmark changes */
-					if bmDataExists && benchMarkData.CloseVal != 0 {
-						benchMarkVal := float64(int((benchMarkMap[dateStr]+(benchMarkData.CloseVal*bmQty))*100)) / 100
-						benchMarkMap[dateStr] = benchMarkVal
-					} else {
-						previousDate := buyDate.AddDate(0, 0, -1)
-						previousDateStr := previousDate.Format("2006-01-02")
-						benchMarkMap[dateStr] = benchMarkMap[previousDateStr]
-					}
-				} else {
-					/* As prices are zero on Holidays */
-					isZero := true
-					buyDateCopy := buyDate
-					for isZero {
-						previousDate := buyDateCopy.AddDate(0, 0, -1)
-						previousDateStr := previousDate.Format("2006-01-02")
-						dailyDataCopy, ok := dailyPriceRecordsMap[previousDateStr]
-
-						benchMarkDataCopy, bmDataExists := benchMarkRecordsMap[previousDateStr]
-
-						if ok && dailyDataCopy.CloseVal != 0 {
-							networthVal := float64(int((networthMap[dateStr]+(dailyDataCopy.CloseVal*qty))*100)) / 100
-							networthMap[dateStr] = networthVal
-							trackedHoldingsMap[dateStr] = networthVal
-							isZero = false
-
-							/* Benchmark changes */
-							if bmDataExists && benchMarkDataCopy.CloseVal != 0 {
-								benchMarkVal := float64(int((benchMarkMap[dateStr]+(benchMarkDataCopy.CloseVal*bmQty))*100)) / 100
-								benchMarkMap[dateStr] = benchMarkVal
-							} else {
-								benchMarkMap[dateStr] = benchMarkMap[previousDateStr]
-							}
-
-							break
-						}
-						buyDateCopy = previousDate
+					if bmCloseVal, bmDataExists := benchMarkCursor.AsOf(priceDate); bmDataExists {
+						benchMarkMap[dateStr] = benchMarkMap[dateStr].Add(bmCloseVal.Mul(bmQty))
 					}
 				}
 				buyDate = buyDate.AddDate(0, 0, 1)
-				allocationMap[dateStr] = (trackedHoldingsMap[dateStr] / networthMap[dateStr]) * 100
 			}
 		}
 	}
@@ -456,35 +670,36 @@ func FetchNetWorthOverPeriods(userInput []byte) (map[string]map[string]float64,
 		buyDate, err := time.Parse("2006-01-02T15:04:05Z", holdingsNt.BuyDate)
 		if err != nil {
 			appUtil.AppLogger.Println(err)
-			return combinedOutputMap, err
+			return series, err
 		}
 
-		for buyDate.Before(time.Now()) {
-			dateStr := buyDate.Format("2006-01-02")
-			currVal, parseErr := strconv.ParseFloat(holdingsNt.CurrentValue, 64)
-			if parseErr != nil {
-				appUtil.AppLogger.Println(parseErr)
-				return combinedOutputMap, parseErr
-			}
-
-			networthVal := float64(int((networthMap[dateStr]+currVal)*100)) / 100
-			networthMap[dateStr] = networthVal
-			nonTrackedHoldingsMap[dateStr] = float64(int((nonTrackedHoldingsMap[dateStr]+currVal)*100)) / 100
+		currVal, parseErr := money.Parse(holdingsNt.CurrentValue)
+		if parseErr != nil {
+			appUtil.AppLogger.Println(parseErr)
+			return series, parseErr
+		}
 
+		if buyDate.Before(from) {
+			buyDate = from
+		}
+		for buyDate.Before(now) {
+			dateStr := buyDate.Format("2006-01-02")
+			networthMap[dateStr] = networthMap[dateStr].Add(currVal)
+			nonTrackedHoldingsMap[dateStr] = nonTrackedHoldingsMap[dateStr].Add(currVal)
 			buyDate = buyDate.AddDate(0, 0, 1)
-
-			allocationMap[dateStr] = (trackedHoldingsMap[dateStr] / networthMap[dateStr]) * 100
 		}
 	}
 
-	combinedOutputMap["networth"] = networthMap
-	combinedOutputMap["equity"] = trackedHoldingsMap
-	combinedOutputMap["benchmark"] = benchMarkMap
-	combinedOutputMap["debt"] = nonTrackedHoldingsMap
-	combinedOutputMap["invested"] = amountInvestedMap
+	return series, nil
+}
 
-	appUtil.AppLogger.Println("Completed FetchNetWorthOverPeriods")
-	return combinedOutputMap, nil
+/* Chart output stays numeric, values rounded to amount places */
+func toChartSeries(series map[string]money.Decimal) map[string]float64 {
+	chartSeries := make(map[string]float64, len(series))
+	for dateStr, val := range series {
+		chartSeries[dateStr] = val.Round(money.AmountPlaces).Float64()
+	}
+	return chartSeries
 }
 
 /* 10) Fetch All Company Names */
@@ -492,52 +707,25 @@ func FetchAllCompanies(userInput []byte) ([]data.Company, error) {
 	return FetchCompanies(appUtil.Db)
 }
 
-/* 11) Calculate Return */
-func CalculateReturn(userInput []byte) (string, error) {
-	/* Get Current Holdings */
-	holdingsOutputJson, err := GetUserHoldings(userInput, false)
+/* 11) Calculate Return. NAV, units and return of requested portfolio, consolidated when more than one */
+func CalculateReturn(userInput []byte) (data.NavReturnOutputJson, error) {
+	var user data.User
+	json.Unmarshal(userInput, &user)
+	returnType, err := parseReturnType(user.ReturnType)
 	if err != nil {
 		appUtil.AppLogger.Println(err)
-		return "", err
-	}
-
-	var totalUnits float64
-
-	/* No of units * 10 => Total Buy Value
-	   No of units * NAV => Total Current Value */
-
-	var holdingsBuyDateMap = make(map[string][]data.Holdings)
-	for _, holding := range holdingsOutputJson.Holdings {
-		buyDate, _ := time.Parse("2006-01-02T15:04:05Z", holding.BuyDate)
-		dateStr := buyDate.Format("2006-01-02")
-		holdingsBuyDateMap[dateStr] = append(holdingsBuyDateMap[dateStr], holding)
+		return data.NavReturnOutputJson{}, err
 	}
-	appUtil.AppLogger.Println("holdingsBuyDateMap ")
-	appUtil.AppLogger.Println(holdingsBuyDateMap)
 
-	for _, holding := range holdingsOutputJson.Holdings {
-		err := LoadLatestCompaniesCompletePrice(holding.Companyid, appUtil.Db)
-		if err != nil {
-			return "", err
-		}
-		//latestPriceData := dailyPriceCacheLatest[holding.Companyid]
-		qty, errQty := strconv.ParseFloat(holding.Quantity, 64)
-		if errQty != nil {
-			appUtil.AppLogger.Println(errQty)
-			return "", errQty
-		}
-
-		buyPrice, errBuyPrice := strconv.ParseFloat(holding.BuyPrice, 64)
-		if errBuyPrice != nil {
-			appUtil.AppLogger.Println(errBuyPrice)
-			return "", errBuyPrice
-		}
-		totalUnits = totalUnits + ((buyPrice * qty) / 10)
-		//appUtil.AppLogger.Println("totalUnits ")
-		//appUtil.AppLogger.Println(totalUnits)
+	series, portfolioId, err := requestedNavSeries(user, returnType)
+	if err != nil {
+		appUtil.AppLogger.Println(err)
+		return data.NavReturnOutputJson{}, err
 	}
-
-	return constants.AppSuccessCalculateReturn, nil
+	navReturn := navReturnSummary(series, time.Now())
+	navReturn.UserID = user.UserId
+	navReturn.PortfolioId = portfolioId
+	return navReturn, nil
 }
 
 /* 12) Calculate Index SIP Return */
@@ -550,6 +738,12 @@ func CalculateIndexSIPReturn(userInput []byte) (data.SIPReturnOutput, error) {
 		appUtil.AppLogger.Println(sipReturnInput)
 	}
 
+	return calculateIndexSIPReturn(sipReturnInput)
+}
+
+/* SIP return of an index over sub periods */
+func calculateIndexSIPReturn(sipReturnInput data.SIPReturnInput) (data.SIPReturnOutput, error) {
+
 	var sipReturnOutput data.SIPReturnOutput
 	var sipReturnSubPeriodArr []data.SIPReturnSubPeriod
 	var sipReturnSubPeriod data.SIPReturnSubPeriod
@@ -560,10 +754,15 @@ func CalculateIndexSIPReturn(userInput []byte) (data.SIPReturnOutput, error) {
 	endDateStr := sipReturnInput.SIPReturnInputParam.EndDate
 	sipAmountStr := sipReturnInput.SIPReturnInputParam.SIPAmount
 	companyId := sipReturnInput.SIPReturnInputParam.Companyid
-	stepUpPct, _ := strconv.ParseFloat(sipReturnInput.SIPReturnInputParam.StepUpPct, 64)
+	stepUpPct, _ := money.Parse(sipReturnInput.SIPReturnInputParam.StepUpPct)
+	returnType, err := parseReturnType(sipReturnInput.SIPReturnInputParam.ReturnType)
+	if err != nil {
+		appUtil.AppLogger.Println(err)
+		return sipReturnOutput, err
+	}
 
-	FetchCompaniesCompletePrice(companyId, appUtil.Db)
-	dailyPriceRecordsMap := dailyPriceCache[companyId]
+	prices := fetchReturnPrices(companyId, returnType)
+	cal := exchangeCalendar(companyId)
 
 	startDate, _ := time.Parse("2006/01/02", startDateStr)
 	appUtil.AppLogger.Println(startDate)
@@ -571,44 +770,50 @@ func CalculateIndexSIPReturn(userInput []byte) (data.SIPReturnOutput, error) {
 	endDate, _ := time.Parse("2006/01/02", endDateStr)
 	appUtil.AppLogger.Println(endDate)
 
-	sipAmount, _ := strconv.ParseFloat(sipAmountStr, 64)
-	qty := 0.0
-	finalCloseVal := 0.0
+	sipAmount, _ := money.Parse(sipAmountStr)
+	qty := money.Zero
+	finalCloseVal := money.Zero
+	totalInvestment := money.Zero
 
 	for startDate.Before(endDate) || startDate.Equal(endDate) {
 		dates = append(dates, startDate)
 
-		closeVal := dailyPriceRecordsMap[startDate.Format("2006-01-02")].CloseVal
-		startDateUpdated := startDate
-
-		for closeVal < 1 {
-			startDateUpdated = startDateUpdated.AddDate(0, 0, 1)
-			closeVal = dailyPriceRecordsMap[startDateUpdated.Format("2006-01-02")].CloseVal
+		/* Invest on next trading day when SIP date is a holiday, or on next available price when price is missing */
+		investDate := startDate
+		if isTrading, _ := cal.IsTradingDay(investDate); !isTrading {
+			investDate = cal.NextTradingDay(investDate)
+		}
+		_, closeVal, ok := prices.OnOrAfter(investDate)
+		if !ok || closeVal.Sign() <= 0 {
+			err := fmt.Errorf("price not available for company %s from %s", companyId, startDate.Format("2006-01-02"))
+			appUtil.AppLogger.Println(err)
+			return sipReturnOutput, err
 		}
 
-		qty = qty + (sipAmount / closeVal)
-		values = append(values, -sipAmount)
+		qty = qty.Add(sipAmount.Div(closeVal))
+		values = append(values, -sipAmount.Float64())
+		totalInvestment = totalInvestment.Add(sipAmount)
 
 		finalCloseVal = closeVal
-		xirrSubPeriod, errXirr := fin.ScheduledInternalRateOfReturn(append(values, qty*finalCloseVal), append(dates, startDate), 0.0)
+		xirrSubPeriod, errXirr := fin.ScheduledInternalRateOfReturn(append(values, qty.Mul(finalCloseVal).Float64()), append(dates, startDate), 0.0)
 		if errXirr != nil {
 			appUtil.AppLogger.Println(errXirr)
 		}
 
 		periodCount++
 
-		sipReturnSubPeriod.Quantity = fmt.Sprintf("%.2f", qty)
+		sipReturnSubPeriod.Quantity = money.FormatQuantity(qty)
 		sipReturnSubPeriod.EndDate = startDate.Format("2006-01-02")
-		sipReturnSubPeriod.TotalEndValue = fmt.Sprintf("%.2f", qty*finalCloseVal)
+		sipReturnSubPeriod.TotalEndValue = money.FormatAmount(qty.Mul(finalCloseVal))
 		sipReturnSubPeriod.Xirr = fmt.Sprintf("%.2f", xirrSubPeriod*100)
-		sipReturnSubPeriod.TotalInvestment = fmt.Sprintf("%.2f", float64(periodCount*int64(sipAmount)))
-		sipReturnSubPeriod.BuyVal = fmt.Sprintf("%.2f", finalCloseVal)
+		sipReturnSubPeriod.TotalInvestment = money.FormatAmount(totalInvestment)
+		sipReturnSubPeriod.BuyVal = money.FormatPrice(finalCloseVal)
 		sipReturnSubPeriodArr = append(sipReturnSubPeriodArr, sipReturnSubPeriod)
 
 		startDate = startDate.AddDate(0, 1, 0)
 
 		if periodCount%12 == 0 {
-			sipAmount = sipAmount + (stepUpPct / 100 * sipAmount)
+			sipAmount = sipAmount.Add(sipAmount.MulDiv(stepUpPct, money.NewFromInt(100)))
 			appUtil.AppLogger.Println("updated Sip Amount ")
 			appUtil.AppLogger.Println(sipAmount)
 		}
@@ -660,7 +865,7 @@ func CalculateIndexSIPReturn(userInput []byte) (data.SIPReturnOutput, error) {
 	appUtil.AppLogger.Println(sipReturnBracket)
 
 	dates = append(dates, endDate)
-	values = append(values, qty*finalCloseVal)
+	values = append(values, qty.Mul(finalCloseVal).Float64())
 
 	xirr, err := fin.ScheduledInternalRateOfReturn(values, dates, 0.0)
 	if err != nil {
@@ -675,7 +880,7 @@ func CalculateIndexSIPReturn(userInput []byte) (data.SIPReturnOutput, error) {
 /* 13) Calculate All Time High for Portfolio */
 func CalculateATHforPF(userInput []byte) (data.HoldingsOutputJson, error) {
 	var holdingsATHOutputJson data.HoldingsOutputJson
-	var netWorth float64
+	netWorth := money.Zero
 
 	/* Get Current Holdings */
 	holdingsOutputJson, err := GetUserHoldings(userInput, true)
@@ -683,45 +888,99 @@ func CalculateATHforPF(userInput []byte) (data.HoldingsOutputJson, error) {
 		appUtil.AppLogger.Println(err)
 	}
 
-	GetATHforCompanies()
+	athPrices, err := GetATHforCompanies()
 
-	if companiesATHPriceCache != nil {
+	if err == nil {
 		for _, holding := range holdingsOutputJson.Holdings {
 			companyId := holding.Companyid
-			athPrice := companiesATHPriceCache[companyId].CloseVal
-			holding.LTP = fmt.Sprintf("%.2f", athPrice)
+			athPrice := athPrices[companyId].CloseVal
+			holding.LTP = money.FormatPrice(athPrice)
 
-			qty, _ := strconv.ParseFloat(holding.Quantity, 64)
-			holding.CurrentValue = fmt.Sprintf("%.2f", athPrice*qty)
+			qty, _ := money.Parse(holding.Quantity)
+			athValue := athPrice.Mul(qty)
+			holding.CurrentValue = money.FormatAmount(athValue)
 
-			holdingBuyPrice, _ := strconv.ParseFloat(holding.BuyPrice, 64)
-			holdingBuyVal := holdingBuyPrice * qty
-			holdingPL := ((athPrice * qty) - (holdingBuyPrice * qty))
-			holding.PL = fmt.Sprintf("%.2f", holdingPL)
+			holdingBuyPrice, _ := money.Parse(holding.BuyPrice)
+			holdingBuyVal := holdingBuyPrice.Mul(qty)
+			holdingPL := athValue.Sub(holdingBuyVal)
+			holding.PL = money.FormatAmount(holdingPL)
 
-			holdingReturn := (holdingPL / holdingBuyVal) * 100
-			holding.NetPct = fmt.Sprintf("%.2f", holdingReturn)
+			holding.NetPct = money.FormatPercent(money.Percent(holdingPL, holdingBuyVal))
 
-			netWorth = netWorth + (athPrice * qty)
+			netWorth = netWorth.Add(athValue)
 
 			holdingsATHOutputJson.Holdings = append(holdingsATHOutputJson.Holdings, holding)
 		}
-		holdingsATHOutputJson.Networth = fmt.Sprintf("%.2f", netWorth)
+		holdingsATHOutputJson.Networth = money.FormatAmount(netWorth)
 	}
 	return holdingsATHOutputJson, nil
 }
 
-/* 14) Calculate xirr values for Portfolio from start date to Now */
+/* Daily xirr % of portfolio and benchmark by date */
+type xirrSeries struct {
+	portfolio map[string]float64
+	benchmark map[string]float64
+}
+
+/* 14) Calculate xirr values for Portfolio from start date to Now. Consolidated view with price return is served from snapshots */
 func CalculateXirrReturn(userInput []byte) (map[string]map[string]float64, error) {
 
 	/* Output map with xirr values */
 	var combinedOutputMap map[string]map[string]float64 = make(map[string]map[string]float64)
-	var xirrDateMap map[string]float64 = make(map[string]float64)
-	var bmXirrDateMap map[string]float64 = make(map[string]float64)
+
+	var user data.User
+	json.Unmarshal(userInput, &user)
+	returnType, err := parseReturnType(user.ReturnType)
+	if err != nil {
+		appUtil.AppLogger.Println(err)
+		return combinedOutputMap, err
+	}
+
+	var series xirrSeries
+	if isSnapshotView(user, returnType) {
+		series, err = xirrFromSnapshots(user, userInput)
+	} else {
+		series, err = xirrOverPeriods(user, userInput, returnType, time.Time{})
+	}
+	if err != nil {
+		return combinedOutputMap, err
+	}
+
+	combinedOutputMap["portfolioReturn"] = series.portfolio
+	combinedOutputMap["benchmarkReturn"] = series.benchmark
+
+	return combinedOutputMap, nil
+}
+
+/* Xirr of each day from portfolio start date. Xirr is calculated only from date, earlier days just carry holdings forward.
+** Price return values holdings and benchmark at close and adds dividends received as inflows.
+** Total return values both at adjusted close, which already reinvests dividends, so dividend inflows are not added */
+func xirrOverPeriods(user data.User, userInput []byte, returnType string, from time.Time) (xirrSeries, error) {
+	series := xirrSeries{portfolio: make(map[string]float64), benchmark: make(map[string]float64)}
+	xirrDateMap := series.portfolio
+	bmXirrDateMap := series.benchmark
+
+	/* Dividends received are inflows of portfolio */
+	dividendFlows := make(map[string]money.Decimal)
+	if returnType == data.ReturnTypePrice {
+		var err error
+		dividendFlows, err = getUserDividendCashFlows(user)
+		if err != nil {
+			appUtil.AppLogger.Println(err)
+			return series, err
+		}
+	}
+	var dividendDates []string
+	for dateStr := range dividendFlows {
+		dividendDates = append(dividendDates, dateStr)
+	}
+	sort.Strings(dividendDates)
 
 	/* Fetch Holdings grouped by Buy Date */
 	holdingsDateMap, startDateTime := GetHoldingsDateWiseMapForUser(userInput)
-	var holdingsDataAsOfDate []data.Holdings
+	var holdingsDataAsOfDate []xirrHolding
+	bmPrices := fetchReturnPrices(benchmarkId(), returnType)
+	bmCursor := bmPrices.Cursor()
 
 	startDate, _ := time.Parse("2006-01-02", startDateTime)
 	endDate := time.Now()
@@ -732,412 +991,272 @@ func CalculateXirrReturn(userInput []byte) (map[string]map[string]float64, error
 	var dates []time.Time
 	var values []float64
 	var bmValues []float64
-	finalCloseVal := 0.0
-	bmFinalCloseVal := 0.0
-	skipDate := false
-
-	/* Added for cases where prices are zero */
-	var latestDates []time.Time
-	var latestValues []float64
-	latestCloseVal := 0.0
-	var bmLatestValues []float64
-	bmLatestCloseVal := 0.0
-
-	/* Loop all dates from PF start date */
+
+	/* Xirr of previous day, carried over days market is closed */
+	cal := calendar.For(data.ExchangeNSE)
+	hasLastXirr := false
+	lastXirr, bmLastXirr := 0.0, 0.0
+
+	/* Loop all dates from PF start date. Holidays use close of previous trading day */
 	for startDate.Before(endDate) || startDate.Equal(endDate) {
 		startDateStr := startDate.Format("2006-01-02")
 
 		/* Append to user holdings when new buydate is available */
-		if _, ok := holdingsDateMap[startDateStr]; ok {
-			holdingsDataAsOfDate = append(holdingsDataAsOfDate, holdingsDateMap[startDateStr]...)
+		for _, holding := range holdingsDateMap[startDateStr] {
+			holdingsDataAsOfDate = append(holdingsDataAsOfDate, newXirrHolding(holding, returnType, bmPrices))
 		}
 
-		/* Loop Holdings and calculate value/portfolio value with prices of a particular day  */
-		for _, holding := range holdingsDataAsOfDate {
-			dailyPriceRecordsMap := FetchCompaniesCompletePrice(holding.Companyid, appUtil.Db)
-
-			closeVal := dailyPriceRecordsMap[startDate.Format("2006-01-02")].CloseVal
-			holdingBuyPrice, _ := strconv.ParseFloat(holding.BuyPrice, 64)
-			qty, _ := strconv.ParseFloat(holding.Quantity, 64)
-			buyDate, _ := time.Parse("2006-01-02T15:04:05Z", holding.BuyDate)
-			finalCloseVal = finalCloseVal + (qty * closeVal)
-
-			if closeVal < 1 {
-				skipDate = true
-				break
-			}
-
-			values = append(values, qty*-holdingBuyPrice)
-			dates = append(dates, buyDate)
-
-			/* Benchmark changes */
-			bmDailyPriceRecordsMap := FetchCompaniesCompletePrice(benchmark, appUtil.Db)
-			bmCloseVal := bmDailyPriceRecordsMap[startDate.Format("2006-01-02")].CloseVal
-			bmBuyDateVal := bmDailyPriceRecordsMap[buyDate.Format("2006-01-02")].CloseVal
-			bmQty := (qty * holdingBuyPrice) / bmBuyDateVal
-			bmFinalCloseVal = bmFinalCloseVal + (bmQty * bmCloseVal)
-			bmValues = append(bmValues, bmQty*-bmBuyDateVal)
-		}
-
-		if skipDate {
-			/* When prices are zero/holidays, use latest available values with start date */
-			xirrSubPeriod, errXirr := fin.ScheduledInternalRateOfReturn(append(latestValues, latestCloseVal), append(latestDates, startDate), 0.0)
-			if errXirr != nil {
-				appUtil.AppLogger.Println(errXirr)
-				xirrSubPeriod = 0.0
-			}
-			xirrFloat, _ := strconv.ParseFloat(fmt.Sprintf("%.2f", xirrSubPeriod*100), 64)
+		/* Xirr of days before from is not needed, holdings are only carried forward */
+		if startDate.Before(from) {
+			startDate = startDate.AddDate(0, 0, 1)
+			continue
+		}
 
+		/* Values do not change on a holiday unless holdings or dividends were added on it */
+		isTrading, _ := cal.IsTradingDay(startDate)
+		_, isDividendDate := dividendFlows[startDateStr]
+		if hasLastXirr && !isTrading && len(holdingsDateMap[startDateStr]) == 0 && !isDividendDate {
 			if startDate.After(cutOffDate) {
-				xirrDateMap[startDateStr] = xirrFloat
+				xirrDateMap[startDateStr] = lastXirr
+				bmXirrDateMap[startDateStr] = bmLastXirr
 			}
+			startDate = startDate.AddDate(0, 0, 1)
+			continue
+		}
 
-			/* Benchmark changes */
-			bmXirrSubPeriod, bmErrXirr := fin.ScheduledInternalRateOfReturn(append(bmLatestValues, bmLatestCloseVal), append(latestDates, startDate), 0.0)
-			if bmErrXirr != nil {
-				appUtil.AppLogger.Println(bmErrXirr)
-				bmXirrSubPeriod = 0.0
-			}
-			bmXirrFloat, _ := strconv.ParseFloat(fmt.Sprintf("%.2f", bmXirrSubPeriod*100), 64)
-			if startDate.After(cutOffDate) {
-				bmXirrDateMap[startDateStr] = bmXirrFloat
-			}
-		} else {
-			xirrSubPeriod, errXirr := fin.ScheduledInternalRateOfReturn(append(values, finalCloseVal), append(dates, startDate), 0.0)
-			if errXirr != nil {
-				appUtil.AppLogger.Println(errXirr)
-				xirrSubPeriod = 0.0
-			}
-			xirrFloat, _ := strconv.ParseFloat(fmt.Sprintf("%.2f", xirrSubPeriod*100), 64)
-			if startDate.After(cutOffDate) {
-				xirrDateMap[startDateStr] = xirrFloat
+		/* Loop Holdings and calculate value/portfolio value with prices of a particular day.
+		** Holding without any price yet is valued at cost */
+		finalCloseVal := money.Zero
+		bmFinalCloseVal := money.Zero
+		dates = dates[:0]
+		values = values[:0]
+		bmValues = bmValues[:0]
+		bmCloseVal, _ := bmCursor.AsOf(startDate)
+		for _, holding := range holdingsDataAsOfDate {
+			if closeVal, ok := holding.prices.AsOf(startDate); ok {
+				finalCloseVal = finalCloseVal.Add(holding.qty.Mul(closeVal))
+			} else {
+				finalCloseVal = finalCloseVal.Add(holding.buyValue)
 			}
+			values = append(values, -holding.buyValue.Float64())
+			dates = append(dates, holding.buyDate)
 
 			/* Benchmark changes */
-			bmXirrSubPeriod, bmErrXirr := fin.ScheduledInternalRateOfReturn(append(bmValues, bmFinalCloseVal), append(dates, startDate), 0.0)
-			if bmErrXirr != nil {
-				appUtil.AppLogger.Println(bmErrXirr)
-				xirrSubPeriod = 0.0
-			}
-			bmXirrFloat, _ := strconv.ParseFloat(fmt.Sprintf("%.2f", bmXirrSubPeriod*100), 64)
-			if startDate.After(cutOffDate) {
-				bmXirrDateMap[startDateStr] = bmXirrFloat
+			bmFinalCloseVal = bmFinalCloseVal.Add(holding.bmQty.Mul(bmCloseVal))
+			bmValues = append(bmValues, -holding.bmBuyValue.Float64())
+		}
+
+		/* Dividends till date. Benchmark has no matching inflow */
+		for _, dividendDateStr := range dividendDates {
+			if dividendDateStr > startDateStr {
+				break
 			}
+			dividendDate, _ := time.Parse("2006-01-02", dividendDateStr)
+			values = append(values, dividendFlows[dividendDateStr].Float64())
+			dates = append(dates, dividendDate)
+			bmValues = append(bmValues, 0)
 		}
 
-		/* Set to latest available values */
-		if !skipDate {
-			latestDates = dates
-			latestValues = values
-			latestCloseVal = finalCloseVal
+		xirrSubPeriod, errXirr := fin.ScheduledInternalRateOfReturn(append(values, finalCloseVal.Float64()), append(dates, startDate), 0.0)
+		if errXirr != nil {
+			appUtil.AppLogger.Println(errXirr)
+			xirrSubPeriod = 0.0
+		}
+		xirrFloat, _ := strconv.ParseFloat(fmt.Sprintf("%.2f", xirrSubPeriod*100), 64)
+		if startDate.After(cutOffDate) {
+			xirrDateMap[startDateStr] = xirrFloat
+		}
 
-			/* Benchmark changes */
-			bmLatestCloseVal = bmFinalCloseVal
-			bmLatestValues = bmValues
+		/* Benchmark changes */
+		bmXirrSubPeriod, bmErrXirr := fin.ScheduledInternalRateOfReturn(append(bmValues, bmFinalCloseVal.Float64()), append(dates, startDate), 0.0)
+		if bmErrXirr != nil {
+			appUtil.AppLogger.Println(bmErrXirr)
+			bmXirrSubPeriod = 0.0
+		}
+		bmXirrFloat, _ := strconv.ParseFloat(fmt.Sprintf("%.2f", bmXirrSubPeriod*100), 64)
+		if startDate.After(cutOffDate) {
+			bmXirrDateMap[startDateStr] = bmXirrFloat
 		}
+		hasLastXirr, lastXirr, bmLastXirr = true, xirrFloat, bmXirrFloat
 
-		/* Reset loop variables */
-		finalCloseVal = 0.0
-		values = values[:0]
-		dates = dates[:0]
 		startDate = startDate.AddDate(0, 0, 1)
-		skipDate = false
-
-		/* Benchmark changes */
-		bmFinalCloseVal = 0.0
-		bmValues = bmValues[:0]
 	}
 
-	combinedOutputMap["portfolioReturn"] = xirrDateMap
-	combinedOutputMap["benchmarkReturn"] = bmXirrDateMap
-
-	return combinedOutputMap, nil
+	return series, nil
 }
 
-/* 14) Calculate nav style returns for Portfolio from start date to Now */
-func CalculateNavReturn(userInput []byte) (map[string]float64, error) {
-	/* Output map with NAV values */
-	var navDateMap map[string]float64 = make(map[string]float64)
-	return navDateMap, nil
+/* Holding of xirr loop with quantities and values parsed once, and a cursor over its prices */
+type xirrHolding struct {
+	prices     *timeseries.Cursor
+	qty        money.Decimal
+	buyValue   money.Decimal
+	buyDate    time.Time
+	bmQty      money.Decimal
+	bmBuyValue money.Decimal
 }
 
-/* ROUTER METHODS END */
-/* -------------------------------------- */
-
-/* Fetch Unique Company Details */
-func FetchCompanies(db *sql.DB) ([]data.Company, error) {
-	if companiesCache != nil {
-		appUtil.AppLogger.Println("Fetching Companies Master List From Cache")
-		return companiesCache, nil
-	} else {
-		appUtil.AppLogger.Println("Fetching Companies Master List From DB")
-		companies, err := data.FetchCompaniesDB(db)
-		if err != nil {
-			appUtil.AppLogger.Println(err)
-			return companies, err
+/* Benchmark units are bought with buy value at benchmark close on buy date, or previous close on a holiday.
+** For total return holding is valued at adjusted close, with units scaled by close over adjusted close on buy date
+** so that value on buy date is at close and later dividends grow it */
+func newXirrHolding(holding data.Holdings, returnType string, bmPrices *timeseries.Series) xirrHolding {
+	holdingBuyPrice, _ := money.Parse(holding.BuyPrice)
+	qty, _ := money.Parse(holding.Quantity)
+	buyDate, _ := time.Parse("2006-01-02T15:04:05Z", holding.BuyDate)
+	prices := fetchReturnPrices(holding.Companyid, returnType)
+	xirrHolding := xirrHolding{
+		prices:   prices.Cursor(),
+		qty:      qty,
+		buyValue: qty.Mul(holdingBuyPrice),
+		buyDate:  buyDate,
+	}
+	if returnType == data.ReturnTypeTotal {
+		buyClose, closeOk := FetchCompaniesCompletePrice(holding.Companyid, appUtil.Db).AsOf(buyDate)
+		buyAdjClose, adjOk := prices.AsOf(buyDate)
+		if closeOk && adjOk && buyAdjClose.Sign() > 0 {
+			xirrHolding.qty = qty.MulDiv(buyClose, buyAdjClose)
 		}
-		companiesCache = companies
-		return companies, nil
 	}
-}
-
-/* Call DownloadDataFile from go routine */
-func DownloadDataAsync(companiesData []data.Company) {
-	/*var wg sync.WaitGroup
-	for _, company := range companiesData {
-		wg.Add(1)
-		go func(companyId string, fromTime time.Time) {
-			defer wg.Done()
-			if fromTime.IsZero() {
-				fromTime = time.Date(1996, 1, 1, 0, 0, 0, 0, time.UTC)
-			}
-			//fmt.Println("fromTime -- ", fromTime)
-			DownloadDataFile(companyId, fromTime)
-		}(company.CompanyId, company.LoadDate)
-	}
-	wg.Wait()
-	*/
-
-	/* Avoided Go routine as Yahoo Finance blocks more than 5 hits per second */
-	for _, company := range companiesData {
-		companyId := company.CompanyId
-		fromTime := company.LoadDate
-		if fromTime.IsZero() {
-			fromTime = time.Date(1996, 1, 1, 0, 0, 0, 0, time.UTC)
-		}
-		time.Sleep(2 * time.Second)
-		err := DownloadDataFile(companyId, fromTime)
-		if err != nil {
-			appUtil.AppLogger.Println(err)
-		}
+	if bmBuyDateVal, ok := bmPrices.AsOf(buyDate); ok && bmBuyDateVal.Sign() > 0 {
+		xirrHolding.bmQty = xirrHolding.buyValue.Div(bmBuyDateVal)
+		xirrHolding.bmBuyValue = xirrHolding.bmQty.Mul(bmBuyDateVal)
 	}
+	return xirrHolding
 }
 
-/* Download data file from online */
-func DownloadDataFile(companyId string, fromTime time.Time) error {
-	filePath := ""
-	url := ""
-	if strings.Contains(companyId, constants.AppDataPrefixMF) {
-		filePath = appUtil.Config.AppDataDir + companyId + constants.AppDataPricesFileSuffixMF
-		url = constants.AppDataPricesUrl + companyId + constants.AppDataPricesUrlSuffixMF
-	} else if strings.Compare(companyId, constants.AppDataBenchmarkNSE) == 0 {
-		filePath = appUtil.Config.AppDataDir + companyId + constants.AppDataCsv
-		url = constants.AppDataPricesUrl + constants.AppDataBenchmarkAppenderText + companyId + constants.AppDataBenchmarkPricesUrlSuffix
-	} else if strings.Contains(companyId, constants.AppDataBenchmarkBSE) {
-		filePath = appUtil.Config.AppDataDir + companyId + constants.AppDataPricesFileSuffixMF
-		url = constants.AppDataPricesUrl + companyId + constants.AppDataPricesUrlSuffixMF
-	} else {
-		filePath = appUtil.Config.AppDataDir + companyId + constants.AppDataPricesFileSuffix
-		url = constants.AppDataPricesUrl + companyId + constants.AppDataPricesUrlSuffix
-	}
+/* 14) Calculate nav style returns for Portfolio from start date to Now. NAV series of each portfolio keyed by portfolio id */
+func CalculateNavReturn(userInput []byte) (map[string]map[string]float64, error) {
+	/* Output map with NAV values */
+	var navOutputMap map[string]map[string]float64 = make(map[string]map[string]float64)
 
-	out, err := os.Create(filePath)
+	var user data.User
+	json.Unmarshal(userInput, &user)
+	returnType, err := parseReturnType(user.ReturnType)
 	if err != nil {
-		return err
+		appUtil.AppLogger.Println(err)
+		return navOutputMap, err
 	}
-	defer out.Close()
-
-	/* Form start and end time */
-	startTime := strconv.Itoa(int(fromTime.Unix()))
-	endTime := strconv.Itoa(int(time.Now().Unix()))
-
-	url = fmt.Sprintf(url, startTime, endTime)
-
-	appUtil.AppLogger.Println("Hitting url " + url + " for company - " + companyId)
 
-	/* Get the data from Yahoo Finance */
-	resp, err := http.Get(url)
+	navSeriesMap, _, err := navSeriesOfUser(user, returnType)
 	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	/* Check server response */
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("bad status: %s", resp.Status)
+		appUtil.AppLogger.Println(err)
+		return navOutputMap, err
 	}
-
-	/* Writer the body to file */
-	_, err = io.Copy(out, resp.Body)
-	if err != nil {
-		return err
+	for portfolioId, series := range navSeriesMap {
+		navDateMap := make(map[string]float64, len(series.nav))
+		for dateStr, nav := range series.nav {
+			navDateMap[dateStr] = nav.Round(money.PricePlaces).Float64()
+		}
+		navOutputMap[portfolioId] = navDateMap
 	}
-
-	appUtil.AppLogger.Println("Completed loading file for company " + companyId)
-	return nil
+	return navOutputMap, nil
 }
 
-/* Read Data From File & Write into DB asynchronously */
-func LoadPriceData(db *sql.DB) {
-	companies, err := FetchCompanies(db)
-	if err == nil {
-		var totRecordsCount int64
-		var wg sync.WaitGroup
-
-		for _, company := range companies {
-			wg.Add(1)
-			filePath := ""
-			if strings.Contains(company.CompanyId, constants.AppDataPrefixMF) {
-				filePath = appUtil.Config.AppDataDir + company.CompanyId + constants.AppDataPricesFileSuffixMF
-			} else if strings.Compare(company.CompanyId, constants.AppDataBenchmarkNSE) == 0 {
-				filePath = appUtil.Config.AppDataDir + company.CompanyId + constants.AppDataCsv
-			} else if strings.Contains(company.CompanyId, constants.AppDataBenchmarkBSE) {
-				filePath = appUtil.Config.AppDataDir + company.CompanyId + constants.AppDataPricesFileSuffixMF
-			} else {
-				filePath = appUtil.Config.AppDataDir + company.CompanyId + constants.AppDataPricesFileSuffix
-			}
+/* ROUTER METHODS END */
+/* -------------------------------------- */
 
-			go func(companyid string) {
-				defer wg.Done()
-				var err error
-				companiesdata, recordsCount, err := ReadDailyPriceCsv(filePath, companyid)
-				if err != nil {
-					appUtil.AppLogger.Println(err.Error(), "Error returned from ReadDailyPriceCsv ")
-				}
-				appUtil.AppLogger.Printf("CompanyId - %s RecordsCount - %d ", companyid, recordsCount)
-				if recordsCount != 0 {
-					atomic.AddInt64(&totRecordsCount, int64(recordsCount))
-					appUtil.AppLogger.Printf("Inserting %d records for company %s ", recordsCount, companyid)
-					err := data.LoadPriceDataDB(companiesdata, db)
-					/* Ignoring data errors for now */
-					if err != nil {
-						appUtil.AppLogger.Println(err.Error(), " Error while inserting Records for CompanyId: "+companyid)
-					}
-					data.UpdateLoadDate(db, companyid, time.Now())
-				} else {
-					appUtil.AppLogger.Println("Skipping DB Insert as file record count is zero for - " + companyid)
-				}
-			}(company.CompanyId)
-		}
-		wg.Wait()
-	} else {
+/* Fetch Unique Company Details */
+func FetchCompanies(db *sql.DB) ([]data.Company, error) {
+	companies, err := companiesCache.GetOrLoad(cacheKeyAll, func() (interface{}, error) {
+		appUtil.AppLogger.Println("Fetching Companies Master List From DB")
+		return data.FetchCompaniesDB(db)
+	})
+	if err != nil {
 		appUtil.AppLogger.Println(err)
+		return nil, err
 	}
-	dailyPriceCache = make(map[string]map[string]data.CompaniesPriceData)
-	dailyPriceCacheLatest = make(map[string]data.CompaniesPriceData)
+	return companies.([]data.Company), nil
 }
 
-func ReadDailyPriceCsv(filePath string, companyid string) ([]data.CompaniesPriceData, int, error) {
-	var companiesdata []data.CompaniesPriceData
+/* Close and total return series of a company */
+type companyPrices struct {
+	close       *timeseries.Series
+	totalReturn *timeseries.Series
+}
 
-	/* Open file */
-	file, err := os.Open(filePath)
-	/* Return if error */
-	if err != nil {
-		appUtil.AppLogger.Println(err.Error(), "Error while opening file ")
-		return companiesdata, 0, fmt.Errorf("error while opening file %s ", filePath)
-	}
-	appUtil.AppLogger.Println("Reading from File - " + file.Name() + " for company - " + companyid)
+/* Fetch All Price Data initially from DB and use cache for subsequent requests */
+func FetchCompaniesCompletePrice(companyid string, db *sql.DB) *timeseries.Series {
+	return loadCompanyPrices(companyid, db).close
+}
 
-	/* Read csv */
-	csvReader := csv.NewReader(file)
-	records, err := csvReader.ReadAll()
-	/* Return if error */
-	if err != nil {
-		appUtil.AppLogger.Println(err.Error(), "Error while reading csv ")
-		return companiesdata, 0, fmt.Errorf("error while reading csv %s ", filePath)
+/* Price series for return calculations. Total return uses adjusted close which includes dividends and splits */
+func fetchReturnPrices(companyid string, returnType string) *timeseries.Series {
+	prices := loadCompanyPrices(companyid, appUtil.Db)
+	if returnType == data.ReturnTypeTotal {
+		return prices.totalReturn
 	}
-	/* Close resources */
-	file.Close()
-
-	/* Process each record */
-	for k, v := range records {
-		if k != 0 {
-
-			openval, dataError := strconv.ParseFloat(v[len(v)-6], 64)
-			processDataErr(dataError, k, companyid)
-
-			highval, dataError := strconv.ParseFloat(v[len(v)-5], 64)
-			processDataErr(dataError, k, companyid)
-
-			lowval, dataError := strconv.ParseFloat(v[len(v)-4], 64)
-			processDataErr(dataError, k, companyid)
-
-			closeval, dataError := strconv.ParseFloat(v[len(v)-3], 64)
-			processDataErr(dataError, k, companyid)
+	return prices.close
+}
 
-			dateval, dataError := time.Parse("2006-01-02", v[len(v)-7])
-			processDataErr(dataError, k, companyid)
+func loadCompanyPrices(companyid string, db *sql.DB) companyPrices {
+	prices, _ := dailyPriceCache.GetOrLoad(companyid, func() (interface{}, error) {
+		//appUtil.AppLogger.Println("FetchCompaniesCompletePrice - From DB")
+		return newCompanyPrices(data.FetchCompaniesCompletePriceDataDB(companyid, db)), nil
+	})
+	return prices.(companyPrices)
+}
 
-			companiesdata = append(companiesdata, data.CompaniesPriceData{CompanyId: companyid, DateVal: dateval, OpenVal: openval, HighVal: highval, LowVal: lowval, CloseVal: closeval})
+/* Days with zero price (holidays) are left out, so that as of lookups use previous close.
+** Close is used for total return when adjusted close is not available */
+func newCompanyPrices(priceRecords []data.CompaniesPriceData) companyPrices {
+	var closeDates, totalReturnDates []time.Time
+	var closeVals, totalReturnVals []money.Decimal
+	for _, priceData := range priceRecords {
+		if priceData.CloseVal.Sign() > 0 {
+			closeDates = append(closeDates, priceData.DateVal)
+			closeVals = append(closeVals, priceData.CloseVal)
+		}
+		totalReturnVal := priceData.AdjCloseVal
+		if totalReturnVal.IsZero() {
+			totalReturnVal = priceData.CloseVal
+		}
+		if totalReturnVal.Sign() > 0 {
+			totalReturnDates = append(totalReturnDates, priceData.DateVal)
+			totalReturnVals = append(totalReturnVals, totalReturnVal)
 		}
 	}
-
-	appUtil.AppLogger.Println("Completed Reading from File for company - " + companyid)
-	return companiesdata, len(companiesdata), nil
-
-}
-
-/* Non critical record error which can be logged and ignored */
-func processDataErr(dataError error, k int, companyid string) {
-	if dataError != nil {
-		appUtil.AppLogger.Println(dataError)
-		appUtil.AppLogger.Printf("Error while processing/reading data for Company - %s record - %d ", companyid, k)
+	return companyPrices{
+		close:       timeseries.New(closeDates, closeVals),
+		totalReturn: timeseries.New(totalReturnDates, totalReturnVals),
 	}
 }
 
-/* Fetch All Price Data initially from DB and use cache for subsequent requests */
-func FetchCompaniesCompletePrice(companyid string, db *sql.DB) map[string]data.CompaniesPriceData {
-	var dailyPriceRecordsMap map[string]data.CompaniesPriceData = make(map[string]data.CompaniesPriceData)
-	if dailyPriceCache[companyid] != nil {
-		//appUtil.AppLogger.Println("FetchCompaniesCompletePrice - From Cache")
-		dailyPriceRecordsMap = dailyPriceCache[companyid]
-	} else {
-		//appUtil.AppLogger.Println("FetchCompaniesCompletePrice - From DB")
-		dailyPriceRecords := data.FetchCompaniesCompletePriceDataDB(companyid, db)
-		for _, priceData := range dailyPriceRecords {
-			dateStr := priceData.DateVal.Format("2006-01-02")
-			dailyPriceRecordsMap[dateStr] = priceData
-		}
-		dailyPriceCache[companyid] = dailyPriceRecordsMap
-
+/* Return type of request, price return when not provided */
+func parseReturnType(returnType string) (string, error) {
+	switch returnType {
+	case "", data.ReturnTypePrice:
+		return data.ReturnTypePrice, nil
+	case data.ReturnTypeTotal:
+		return data.ReturnTypeTotal, nil
 	}
-	return dailyPriceRecordsMap
+	return "", fmt.Errorf("invalid return type %s", returnType)
 }
 
 /* Load Latest Price Data from DB and use cache for subsequent requests */
 func LoadLatestCompaniesCompletePrice(companyid string, db *sql.DB) error {
-
-	if _, ok := dailyPriceCacheLatest[companyid]; ok {
-		//appUtil.AppLogger.Println("LoadLatestCompaniesCompletePrice - Price data already in Cache")
-	} else {
-		//appUtil.AppLogger.Println("LoadLatestCompaniesCompletePrice - Loading Price Data From DB to Cache")
-		dailyPriceRecordsLatest, err := data.FetchCompaniesLatestPriceDataDB(companyid, db)
-		if err != nil {
-			appUtil.AppLogger.Println(err)
-			return err
-		}
-		dailyPriceCacheLatest[companyid] = dailyPriceRecordsLatest
-	}
-	return nil
+	_, err := getLatestPrice(companyid)
+	return err
 }
 
-/* Download data file from online */
-func DownloadCompaniesMaster() error {
+/* Download constituents list of universe from configured provider */
+func DownloadCompaniesMaster(universe Universe) error {
 
-	filePath := appUtil.Config.AppDataDir + constants.AppDataMasterFile
-	url := constants.AppDataMasterUrl
+	filePath := appUtil.Config.AppDataDir + universe.FileName()
 
-	out, err := os.Create(filePath)
+	/* Get the data from provider, NSE INDIA by default */
+	masterList, err := getMasterListProvider().FetchMasterList(universe)
 	if err != nil {
 		return err
 	}
-	defer out.Close()
+	defer masterList.Close()
 
-	/* Get the data from NSE INDIA */
-	resp, err := http.Get(url)
+	out, err := os.Create(filePath)
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
-
-	/* Check server response */
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("bad status: %s", resp.Status)
-	}
+	defer out.Close()
 
 	/* Writer the body to file */
-	_, err = io.Copy(out, resp.Body)
+	_, err = io.Copy(out, masterList)
 	if err != nil {
 		return err
 	}
@@ -1145,14 +1264,13 @@ func DownloadCompaniesMaster() error {
 	return nil
 }
 
-/* Read Companies Master Data From File & Write into DB  */
-func LoadCompaniesMaster() error {
-	companiesMasterList, errRead := ReadCompaniesMasterCsv(appUtil.Config.AppDataDir + constants.AppDataMasterFile)
+/* Read constituents of universe from File & Write into DB along with index membership */
+func LoadCompaniesMaster(universe Universe) error {
+	companiesMasterList, instruments, errRead := ReadCompaniesMasterCsv(appUtil.Config.AppDataDir+universe.FileName(), universe.Name)
 	if errRead != nil {
 		return errRead
 	}
-	errLoad := LoadCompaniesMasterList(companiesMasterList)
-	return errLoad
+	return loadUniverse(universe.Name, companiesMasterList, instruments)
 }
 
 /* Write Companies Master List into DB */
@@ -1161,60 +1279,48 @@ func LoadCompaniesMasterList(companiesMasterList []data.Company) error {
 	return err
 }
 
-func ReadCompaniesMasterCsv(filePath string) ([]data.Company, error) {
-	var companiesMasterList []data.Company
-
+func ReadCompaniesMasterCsv(filePath string, universeName string) ([]data.Company, []data.Instrument, error) {
 	/* Open file */
 	file, err := os.Open(filePath)
 	/* Return if error opening file */
 	if err != nil {
 		appUtil.AppLogger.Println(err.Error(), "Error while opening file ")
-		return companiesMasterList, fmt.Errorf("error while opening file %s ", filePath)
+		return nil, nil, fmt.Errorf("error while opening file %s ", filePath)
 	}
+	defer file.Close()
 	appUtil.AppLogger.Println("Reading from File - " + file.Name())
 
-	/* Read csv */
-	csvReader := csv.NewReader(file)
-	records, err := csvReader.ReadAll()
-	/* Return if error */
+	companiesMasterList, instruments, err := ReadMasterListCsv(file, universeName)
 	if err != nil {
 		appUtil.AppLogger.Println(err.Error(), "Error while reading csv ")
-		return companiesMasterList, fmt.Errorf("error while reading csv %s ", filePath)
-	}
-	/* Close resources */
-	file.Close()
-
-	/* Process each record */
-	for k, v := range records {
-		/* Ignore first record */
-		if k != 0 {
-			companyid := v[len(v)-3]
-			companyname := v[len(v)-5]
-			companiesMasterList = append(companiesMasterList, data.Company{CompanyId: companyid, CompanyName: companyname, LoadDate: time.Date(1996, 1, 1, 0, 0, 0, 0, time.UTC)})
-		}
+		return companiesMasterList, instruments, err
 	}
 
 	appUtil.AppLogger.Println("Records Read From File - ", len(companiesMasterList))
-	return companiesMasterList, nil
-
+	return companiesMasterList, instruments, nil
 }
 
 func verifyUserId(userid string, db *sql.DB) (bool, error) {
 	appUtil.AppLogger.Println("Verifying UserId - " + userid)
 	/* Populate cache first time */
-	if len(usersCache) == 0 {
+	users, err := usersCache.GetOrLoad(cacheKeyAll, func() (interface{}, error) {
 		users, err := data.FetchUniqueUsersDB(db)
 		if err != nil {
-			appUtil.AppLogger.Println(err)
-			return false, err
+			return nil, err
 		}
+		usersMap := make(map[string]data.User)
 		for _, user := range users {
-			usersCache[user.UserId] = user
+			usersMap[user.UserId] = user
 		}
 		appUtil.AppLogger.Println("Loaded User Data In Cache")
+		return usersMap, nil
+	})
+	if err != nil {
+		appUtil.AppLogger.Println(err)
+		return false, err
 	}
 
-	if _, isPresent := usersCache[userid]; isPresent {
+	if _, isPresent := users.(map[string]data.User)[userid]; isPresent {
 		appUtil.AppLogger.Println("User data available in Cache")
 		return true, nil
 	}
@@ -1222,53 +1328,63 @@ func verifyUserId(userid string, db *sql.DB) (bool, error) {
 }
 
 func calculateNetWorthAndAlloc(userHoldings *data.HoldingsOutputJson, db *sql.DB) error {
-	var NW float64
-	var eqTotal float64
-	var debtTotal float64
+	NW := money.Zero
+	eqTotal := money.Zero
+	debtTotal := money.Zero
+	goldTotal := money.Zero
 
 	for _, holding := range userHoldings.Holdings {
-		err := LoadLatestCompaniesCompletePrice(holding.Companyid, db)
+		latestPriceData, err := getLatestPrice(holding.Companyid)
 		if err != nil {
 			return err
 		}
-		latestPriceData := dailyPriceCacheLatest[holding.Companyid]
-		qty, errQty := strconv.ParseFloat(holding.Quantity, 64)
+		qty, errQty := money.Parse(holding.Quantity)
 		if errQty != nil {
 			appUtil.AppLogger.Println(errQty)
 			return errQty
 		}
 
-		NW = NW + (latestPriceData.CloseVal * qty)
-		eqTotal = eqTotal + (latestPriceData.CloseVal * qty)
+		currentVal := latestPriceData.CloseVal.Mul(qty)
+		NW = NW.Add(currentVal)
+
+		/* Bucket by asset class of instrument, all stocks/funds/ETFs are equity */
+		switch getInstrument(holding.Companyid).AssetClass {
+		case data.AssetClassBond:
+			debtTotal = debtTotal.Add(currentVal)
+		case data.AssetClassGold:
+			goldTotal = goldTotal.Add(currentVal)
+		default:
+			eqTotal = eqTotal.Add(currentVal)
+		}
 	}
 
 	for _, holdingNT := range userHoldings.HoldingsNT {
-		cv, errCV := strconv.ParseFloat(holdingNT.CurrentValue, 64)
+		cv, errCV := money.Parse(holdingNT.CurrentValue)
 		if errCV != nil {
 			appUtil.AppLogger.Println(errCV)
 			return errCV
 		}
-		NW = NW + cv
-		debtTotal = debtTotal + cv
+		NW = NW.Add(cv)
+		debtTotal = debtTotal.Add(cv)
 	}
 
-	/* Calculate EQ and Debt % */
-	eqPercent := fmt.Sprintf("%f", eqTotal/NW*100)
-	debtPercent := fmt.Sprintf("%f", debtTotal/NW*100)
-
-	userHoldings.Allocation.Equity = eqPercent
-	userHoldings.Allocation.Debt = debtPercent
+	/* Calculate EQ, Debt and Gold % */
+	userHoldings.Allocation.Equity = money.FormatPercent(money.Percent(eqTotal, NW))
+	userHoldings.Allocation.Debt = money.FormatPercent(money.Percent(debtTotal, NW))
+	if !goldTotal.IsZero() {
+		userHoldings.Allocation.Gold = money.FormatPercent(money.Percent(goldTotal, NW))
+	}
 
-	userHoldings.Networth = fmt.Sprintf("%f", NW)
+	userHoldings.Networth = money.FormatAmount(NW)
 	return nil
 }
 
-func CalculateCumulativeInvestedAmount(holdings []data.Holdings) float64 {
-	var cumulativeAmount float64
+func CalculateCumulativeInvestedAmount(holdings []data.Holdings) money.Decimal {
+	cumulativeAmount := money.Zero
 	for _, holding := range holdings {
-		buyPrice, _ := strconv.ParseFloat(holding.BuyPrice, 64)
-		qty, _ := strconv.ParseFloat(holding.Quantity, 64)
-		cumulativeAmount = cumulativeAmount + (buyPrice * qty)
+		buyPrice, _ := money.Parse(holding.BuyPrice)
+		qty, _ := money.Parse(holding.Quantity)
+		cumulativeAmount = cumulativeAmount.Add(buyPrice.Mul(qty))
 	}
 	return cumulativeAmount
 }
@@ -1285,111 +1401,62 @@ func IsValidPassword(user data.User) bool {
 	return false
 }
 
-/* Prepare data for Holdings table */
-func AggregateHoldings(userHoldings data.HoldingsOutputJson) ([]data.Holdings, error) {
+/* Prepare data for Holdings table from the ledger */
+func AggregateHoldings(transactions []data.Transaction) ([]data.Holdings, error) {
 
 	var holdingsAggregated []data.Holdings
 
-	var holdingsMap map[string]data.Holdings = make(map[string]data.Holdings)
-
-	var aggregatedBuyVal float64
-	var aggregatedBuyPrice float64
-
-	/* Loop transaction data and aggregate as needed */
-	for _, holding := range userHoldings.Holdings {
-		if holdingMapVal, isPresent := holdingsMap[holding.Companyid]; isPresent {
-
-			holdingMapQty, err := strconv.ParseFloat(holdingMapVal.Quantity, 64)
-			if err != nil {
-				appUtil.AppLogger.Println(err)
-				return holdingsAggregated, err
-			}
-
-			holdingMapBuyPrice, err := strconv.ParseFloat(holdingMapVal.BuyPrice, 64)
-			if err != nil {
-				appUtil.AppLogger.Println(err)
-				return holdingsAggregated, err
-			}
-
-			holdingQty, err := strconv.ParseFloat(holding.Quantity, 64)
-			if err != nil {
-				appUtil.AppLogger.Println(err)
-				return holdingsAggregated, err
-			}
-
-			holdingBuyPrice, err := strconv.ParseFloat(holding.BuyPrice, 64)
-			if err != nil {
-				appUtil.AppLogger.Println(err)
-				return holdingsAggregated, err
-			}
-
-			aggregatedQty := holdingMapQty + holdingQty
-
-			if holdingQty > 0 {
-				aggregatedBuyVal = (holdingMapQty * holdingMapBuyPrice) + (holdingQty * holdingBuyPrice)
-				aggregatedBuyPrice = aggregatedBuyVal / aggregatedQty
-			} else {
-				/* Use same buy price & buy val for a sell transaction */
-				aggregatedBuyVal = aggregatedQty * holdingMapBuyPrice
-				aggregatedBuyPrice = holdingMapBuyPrice
-			}
-
-			latestPriceData := dailyPriceCacheLatest[holding.Companyid]
-
-			/* Set aggregated Qty/Buy Price/Current Val/PL/return */
-			var updatedHolding data.Holdings
-			updatedHolding.Companyid = holding.Companyid
-			updatedHolding.CompanyName = holding.CompanyName
-			updatedHolding.Quantity = fmt.Sprintf("%.0f", aggregatedQty)
-			updatedHolding.BuyPrice = fmt.Sprintf("%.2f", aggregatedBuyPrice)
-			updatedHolding.LTP = fmt.Sprintf("%.2f", latestPriceData.CloseVal)
-			updatedHolding.BuyDate = holding.BuyDate
-
-			aggregatedCurrentVal := latestPriceData.CloseVal * aggregatedQty
-			updatedHolding.CurrentValue = fmt.Sprintf("%.2f", aggregatedCurrentVal)
-
-			aggregatedPL := aggregatedCurrentVal - aggregatedBuyVal
-			updatedHolding.PL = fmt.Sprintf("%.2f", aggregatedPL)
-
-			aggregatedReturn := (aggregatedPL / aggregatedBuyVal) * 100
-			updatedHolding.NetPct = fmt.Sprintf("%.2f", aggregatedReturn)
-
-			holdingsMap[holding.Companyid] = updatedHolding
-
-		} else {
-			latestPriceData := dailyPriceCacheLatest[holding.Companyid]
-
-			qty, err := strconv.ParseFloat(holding.Quantity, 64)
-			if err != nil {
-				appUtil.AppLogger.Println(err)
-				return holdingsAggregated, err
-			}
-
-			buyPrice, err := strconv.ParseFloat(holding.BuyPrice, 64)
-			if err != nil {
-				appUtil.AppLogger.Println(err)
-				return holdingsAggregated, err
-			}
-			holding.Quantity = fmt.Sprintf("%.0f", qty)
-			holding.BuyPrice = fmt.Sprintf("%.2f", buyPrice)
-
-			holding.LTP = fmt.Sprintf("%.2f", latestPriceData.CloseVal)
-			holding.CurrentValue = fmt.Sprintf("%.2f", latestPriceData.CloseVal*qty)
-
-			holdingBuyVal := buyPrice * qty
-			holdingPL := ((latestPriceData.CloseVal * qty) - holdingBuyVal)
-			holding.PL = fmt.Sprintf("%.2f", holdingPL)
+	positions, order, err := BuildPositions(transactions)
+	if err != nil {
+		appUtil.AppLogger.Println(err)
+		return holdingsAggregated, err
+	}
+
+	/* Merge positions of the same company across portfolios */
+	var companyOrder []string
+	companyPositions := make(map[string]*Position)
+	for _, key := range order {
+		position := positions[key]
+		companyPosition, isPresent := companyPositions[position.Companyid]
+		if !isPresent {
+			companyPosition = &Position{Companyid: position.Companyid, CompanyName: position.CompanyName, FirstDate: position.FirstDate}
+			companyPositions[position.Companyid] = companyPosition
+			companyOrder = append(companyOrder, position.Companyid)
+		}
+		companyPosition.Quantity = companyPosition.Quantity.Add(position.Quantity)
+		companyPosition.CostBasis = companyPosition.CostBasis.Add(position.CostBasis)
+		companyPosition.RealizedPL = companyPosition.RealizedPL.Add(position.RealizedPL)
+		companyPosition.Dividends = companyPosition.Dividends.Add(position.Dividends)
+	}
+
+	for _, companyId := range companyOrder {
+		position := companyPositions[companyId]
+		latestPriceData, _ := getLatestPrice(companyId)
+
+		/* Set aggregated Qty/Buy Price/Current Val/PL/return */
+		var holding data.Holdings
+		holding.Companyid = position.Companyid
+		holding.CompanyName = position.CompanyName
+		holding.BuyDate = position.FirstDate
+		holding.Quantity = money.FormatQuantity(position.Quantity)
+
+		avgBuyPrice := money.Zero
+		if position.Quantity.Sign() > 0 {
+			avgBuyPrice = position.CostBasis.Div(position.Quantity)
+		}
+		holding.BuyPrice = money.FormatPrice(avgBuyPrice)
+		holding.LTP = money.FormatPrice(latestPriceData.CloseVal)
 
-			holdingReturn := (holdingPL / holdingBuyVal) * 100
-			holding.NetPct = fmt.Sprintf("%.2f", holdingReturn)
+		currentVal := latestPriceData.CloseVal.Mul(position.Quantity)
+		holding.CurrentValue = money.FormatAmount(currentVal)
 
-			holdingsMap[holding.Companyid] = holding
-		}
-	}
+		holdingPL := currentVal.Sub(position.CostBasis)
+		holding.PL = money.FormatAmount(holdingPL)
 
-	/* Flatten Holdings aggregated map to slice */
+		holding.NetPct = money.FormatPercent(money.Percent(holdingPL, position.CostBasis))
+		holding.RealizedPL = money.FormatAmount(position.RealizedPL)
+		holding.Dividends = money.FormatAmount(position.Dividends)
 
-	for _, holding := range holdingsMap {
 		holdingsAggregated = append(holdingsAggregated, holding)
 	}
 
@@ -1399,24 +1466,23 @@ func AggregateHoldings(userHoldings data.HoldingsOutputJson) ([]data.Holdings, e
 
 /* Fetch ATH of companies and store in cache map */
 func GetATHforCompanies() (map[string]data.CompaniesPriceData, error) {
-	var companiesATHMap map[string]data.CompaniesPriceData = make(map[string]data.CompaniesPriceData)
-
-	if companiesATHPriceCache != nil {
-		appUtil.AppLogger.Println("Fetching ATH for Companies From Cache")
-		return companiesATHPriceCache, nil
-	} else {
+	companiesATHMap, err := companiesATHPriceCache.GetOrLoad(cacheKeyAll, func() (interface{}, error) {
 		appUtil.AppLogger.Println("Fetching ATH for Companies From DB")
 		athRecords, err := data.FetchATHForCompaniesDB(appUtil.Db)
 		if err != nil {
-			return companiesATHMap, err
+			return nil, err
 		}
 
+		companiesATHMap := make(map[string]data.CompaniesPriceData)
 		for _, companyData := range athRecords {
 			companiesATHMap[companyData.CompanyId] = companyData
 		}
-		companiesATHPriceCache = companiesATHMap
-		return companiesATHPriceCache, nil
+		return companiesATHMap, nil
+	})
+	if err != nil {
+		return make(map[string]data.CompaniesPriceData), err
 	}
+	return companiesATHMap.(map[string]data.CompaniesPriceData), nil
 }
 
 /* Fetch Holdings grouped by Buy Date */
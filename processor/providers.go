@@ -0,0 +1,224 @@
+package processor
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vijayyogesh/PortfolioApis/constants"
+	"github.com/vijayyogesh/PortfolioApis/data"
+)
+
+/* Source of daily prices for an instrument.
+** Returns csv with header Date,Open,High,Low,Close,Adj Close,Volume from fromTime till now */
+type PriceProvider interface {
+	Name() string
+	FetchPrices(companyId string, fromTime time.Time) (io.ReadCloser, error)
+}
+
+/* Optional capability of a price provider to publish corporate events.
+** Returns csv with header Date,Dividends for dividends and Date,Stock Splits (eg: 2:1) for splits */
+type PriceEventProvider interface {
+	FetchEvents(companyId string, eventType string, fromTime time.Time) (io.ReadCloser, error)
+}
+
+/* Optional capability of a price provider to share downloads among companies of an ingestion run.
+** StartRun is called with companies of the run before their prices are fetched and EndRun after the run */
+type RunScopedProvider interface {
+	StartRun(companyIds []string)
+	EndRun()
+}
+
+/* Source of constituents csv of a universe in NSE or BSE index constituents format */
+type MasterListProvider interface {
+	Name() string
+	FetchMasterList(universe Universe) (io.ReadCloser, error)
+}
+
+/* -------------------------------------- */
+/* YAHOO FINANCE */
+
+type YahooPriceProvider struct {
+	BaseUrl string
+}
+
+func NewYahooPriceProvider(baseUrl string) *YahooPriceProvider {
+	if baseUrl == "" {
+		baseUrl = constants.AppDataPricesUrl
+	}
+	return &YahooPriceProvider{BaseUrl: baseUrl}
+}
+
+func (provider *YahooPriceProvider) Name() string {
+	return constants.AppProviderYahoo
+}
+
+func (provider *YahooPriceProvider) FetchPrices(companyId string, fromTime time.Time) (io.ReadCloser, error) {
+	startTime := strconv.FormatInt(fromTime.Unix(), 10)
+	endTime := strconv.FormatInt(time.Now().Unix(), 10)
+	url := provider.BaseUrl + providerSymbol(getInstrument(companyId)) + fmt.Sprintf(constants.AppDataPricesUrlQuery, startTime, endTime)
+
+	appUtil.AppLogger.Println("Hitting url " + url + " for company - " + companyId)
+	return httpGetBody(url)
+}
+
+func (provider *YahooPriceProvider) FetchEvents(companyId string, eventType string, fromTime time.Time) (io.ReadCloser, error) {
+	startTime := strconv.FormatInt(fromTime.Unix(), 10)
+	endTime := strconv.FormatInt(time.Now().Unix(), 10)
+	url := provider.BaseUrl + providerSymbol(getInstrument(companyId)) + fmt.Sprintf(constants.AppDataEventsUrlQuery, startTime, endTime, feedEventName(eventType))
+
+	appUtil.AppLogger.Println("Hitting url " + url + " for company - " + companyId)
+	return httpGetBody(url)
+}
+
+/* Event name used by Yahoo feed and fixture files */
+func feedEventName(eventType string) string {
+	if eventType == data.PriceEventSplit {
+		return constants.AppDataEventsSplit
+	}
+	return constants.AppDataEventsDividend
+}
+
+/* -------------------------------------- */
+/* NSE INDIA */
+
+/* Index lists are fetched from BaseUrl, universes with complete url from that url */
+type NSEMasterListProvider struct {
+	BaseUrl string
+}
+
+func NewNSEMasterListProvider(baseUrl string) *NSEMasterListProvider {
+	if baseUrl == "" {
+		baseUrl = constants.AppDataIndicesUrl
+	}
+	/* Url of a single list configured earlier */
+	if strings.HasSuffix(baseUrl, constants.AppDataCsv) {
+		baseUrl = baseUrl[:strings.LastIndex(baseUrl, "/")+1]
+	}
+	return &NSEMasterListProvider{BaseUrl: baseUrl}
+}
+
+func (provider *NSEMasterListProvider) Name() string {
+	return constants.AppProviderNSE
+}
+
+func (provider *NSEMasterListProvider) FetchMasterList(universe Universe) (io.ReadCloser, error) {
+	url := universe.Source
+	if !strings.Contains(url, "://") {
+		url = provider.BaseUrl + url
+	}
+	appUtil.AppLogger.Println("Hitting url " + url + " for universe " + universe.Name)
+	return httpGetBody(url)
+}
+
+/* -------------------------------------- */
+/* LOCAL DIRECTORY/FIXTURES */
+
+/* Reads <companyId>.csv and master list file from a directory, for offline runs */
+type LocalProvider struct {
+	Dir string
+}
+
+func NewLocalProvider(dir string) *LocalProvider {
+	return &LocalProvider{Dir: dir}
+}
+
+func (provider *LocalProvider) Name() string {
+	return constants.AppProviderLocal
+}
+
+/* Complete file is returned irrespective of fromTime, rows already loaded are upserted */
+func (provider *LocalProvider) FetchPrices(companyId string, fromTime time.Time) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(provider.Dir, companyId+constants.AppDataCsv))
+}
+
+/* Reads <companyId>_div.csv or <companyId>_split.csv. Missing file means no events */
+func (provider *LocalProvider) FetchEvents(companyId string, eventType string, fromTime time.Time) (io.ReadCloser, error) {
+	file, err := os.Open(filepath.Join(provider.Dir, companyId+"_"+feedEventName(eventType)+constants.AppDataCsv))
+	if os.IsNotExist(err) {
+		return io.NopCloser(strings.NewReader("")), nil
+	}
+	return file, err
+}
+
+func (provider *LocalProvider) FetchMasterList(universe Universe) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(provider.Dir, universe.FileName()))
+}
+
+/* -------------------------------------- */
+/* PROVIDER SELECTION */
+
+var providersOnce sync.Once
+var priceProviders map[string]PriceProvider
+var priceProviderOverrides map[string]string
+var masterListProvider MasterListProvider
+var providerHttpClient *providerClient
+
+/* Build providers from config once.
+** PRICE_PROVIDER picks default, PRICE_PROVIDER_OVERRIDES picks per instrument (companyId:provider,...)
+** on top of provider set in instruments table */
+func initProviders() {
+	providersOnce.Do(func() {
+		config := appUtil.Config
+		providerHttpClient = newProviderClient()
+		localProvider := NewLocalProvider(config.FixtureDir)
+
+		priceProviders = map[string]PriceProvider{
+			constants.AppProviderYahoo: NewYahooPriceProvider(config.PriceProviderUrl),
+			constants.AppProviderLocal: localProvider,
+			constants.AppProviderAMFI:  NewAMFIPriceProvider(config.AmfiNavUrl, config.AmfiHistoryUrl, config.AmfiNavDir),
+		}
+
+		priceProviderOverrides = make(map[string]string)
+		for _, override := range strings.Split(config.PriceProviderOverrides, ",") {
+			overrideParts := strings.SplitN(strings.TrimSpace(override), ":", 2)
+			if len(overrideParts) == 2 {
+				priceProviderOverrides[overrideParts[0]] = overrideParts[1]
+			}
+		}
+
+		if config.MasterListProvider == constants.AppProviderLocal {
+			masterListProvider = localProvider
+		} else {
+			masterListProvider = NewNSEMasterListProvider(config.MasterListProviderUrl)
+		}
+	})
+}
+
+/* Price provider of an instrument. Defaults to Yahoo when nothing is configured */
+func priceProviderFor(companyId string) (PriceProvider, error) {
+	initProviders()
+
+	providerName, isPresent := priceProviderOverrides[companyId]
+	if !isPresent {
+		providerName = getInstrument(companyId).Provider
+	}
+	if providerName == "" {
+		providerName = appUtil.Config.PriceProvider
+	}
+	if providerName == "" {
+		providerName = constants.AppProviderYahoo
+	}
+
+	provider, isPresent := priceProviders[providerName]
+	if !isPresent {
+		return nil, fmt.Errorf("unknown price provider %s for company %s", providerName, companyId)
+	}
+	return provider, nil
+}
+
+func getMasterListProvider() MasterListProvider {
+	initProviders()
+	return masterListProvider
+}
+
+/* GET url through shared rate limited client and return body when status is OK */
+func httpGetBody(url string) (io.ReadCloser, error) {
+	initProviders()
+	return providerHttpClient.Get(url)
+}
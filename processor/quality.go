@@ -0,0 +1,248 @@
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/vijayyogesh/PortfolioApis/calendar"
+	"github.com/vijayyogesh/PortfolioApis/constants"
+	"github.com/vijayyogesh/PortfolioApis/data"
+	"github.com/vijayyogesh/PortfolioApis/money"
+)
+
+/* Data quality report of a company, or of all companies when companyid is not provided */
+func GetDataQualityReport(userInput []byte) (data.DataQualityReport, error) {
+	var report data.DataQualityReport
+	var qualityInput data.DataQualityInput
+	json.Unmarshal(userInput, &qualityInput)
+
+	companyIds, err := qualityCompanyIds(qualityInput.Companyid)
+	if err != nil {
+		return report, err
+	}
+	for _, companyId := range companyIds {
+		companyQuality, _, err := checkPriceQuality(companyId)
+		if err != nil {
+			appUtil.AppLogger.Println(err)
+			return report, err
+		}
+		report.Companies = append(report.Companies, companyQuality)
+	}
+	return report, nil
+}
+
+/* Admin route to refetch recent gaps of a company or all companies */
+func BackfillPriceGaps(userInput []byte) string {
+	var qualityInput data.DataQualityInput
+	json.Unmarshal(userInput, &qualityInput)
+
+	companyIds, err := qualityCompanyIds(qualityInput.Companyid)
+	if err != nil {
+		return constants.AppErrBackfillGaps
+	}
+	backfillPriceGaps(companyIds)
+	return constants.AppSuccessBackfillGaps
+}
+
+func qualityCompanyIds(companyId string) ([]string, error) {
+	if companyId != "" {
+		return []string{companyId}, nil
+	}
+	var companyIds []string
+	companies, err := FetchCompanies(appUtil.Db)
+	if err != nil {
+		return companyIds, err
+	}
+	for _, company := range companies {
+		companyIds = append(companyIds, company.CompanyId)
+	}
+	return companyIds, nil
+}
+
+/* Detect gaps and jumps in price history of company and record them as flags.
+** Also returns first missing trading day within backfill window, zero when there is none */
+func checkPriceQuality(companyId string) (data.CompanyDataQuality, time.Time, error) {
+	companyQuality := data.CompanyDataQuality{CompanyId: companyId, Gaps: []data.PriceGap{}, Jumps: []data.PriceJump{}}
+	var backfillFrom time.Time
+
+	priceHistory, err := data.FetchPriceHistoryDB(companyId, appUtil.Db)
+	if err != nil {
+		return companyQuality, backfillFrom, err
+	}
+	companyQuality.Rows = strconv.Itoa(len(priceHistory))
+	if len(priceHistory) == 0 {
+		return companyQuality, backfillFrom, nil
+	}
+	companyQuality.FirstDate = priceHistory[0].DateVal.Format("2006-01-02")
+	companyQuality.LastDate = priceHistory[len(priceHistory)-1].DateVal.Format("2006-01-02")
+
+	/* Prices of today may not be published yet */
+	cal := exchangeCalendar(companyId)
+	lastExpected := cal.PreviousTradingDay(time.Now())
+
+	var flags []data.PriceQualityFlag
+	gaps, missingDays := findPriceGaps(priceHistory, cal, lastExpected)
+	for _, gap := range gaps {
+		companyQuality.Gaps = append(companyQuality.Gaps, gap)
+		flags = append(flags, data.PriceQualityFlag{CompanyId: companyId, DateVal: gap.From, FlagType: data.QualityFlagGap,
+			Detail: fmt.Sprintf("%s trading days missing till %s", gap.Days, gap.To)})
+	}
+	companyQuality.MissingDays = strconv.Itoa(missingDays)
+	if len(gaps) > 0 {
+		lastGap := gaps[len(gaps)-1]
+		companyQuality.Stale = lastGap.To == lastExpected.Format("2006-01-02")
+	}
+
+	jumps := findPriceJumps(priceHistory, splitDates(companyId), jumpThresholdPct())
+	for _, jump := range jumps {
+		companyQuality.Jumps = append(companyQuality.Jumps, jump)
+		flags = append(flags, data.PriceQualityFlag{CompanyId: companyId, DateVal: jump.Date, FlagType: data.QualityFlagJump,
+			Detail: fmt.Sprintf("close moved %s%% from %s to %s", jump.ChangePct, jump.PrevClose, jump.Close)})
+	}
+
+	err = data.ReplacePriceQualityFlagsDB(companyId, flags, appUtil.Db)
+	if err != nil {
+		return companyQuality, backfillFrom, err
+	}
+
+	/* Only recent gaps are refetched, older ones are mostly missing at provider too */
+	windowStart := time.Now().AddDate(0, 0, -constants.AppDQBackfillDays)
+	for _, gap := range gaps {
+		gapFrom, _ := time.Parse("2006-01-02", gap.From)
+		gapTo, _ := time.Parse("2006-01-02", gap.To)
+		if !gapTo.Before(windowStart) {
+			if gapFrom.Before(windowStart) {
+				gapFrom = windowStart
+			}
+			backfillFrom = gapFrom
+			break
+		}
+	}
+	return companyQuality, backfillFrom, nil
+}
+
+/* Ranges of trading days of exchange without price between first price date and lastExpected.
+** Only years covered by holiday file are checked, as holidays of other years would show up as gaps */
+func findPriceGaps(priceHistory []data.CompaniesPriceData, cal *calendar.Calendar, lastExpected time.Time) ([]data.PriceGap, int) {
+	var gaps []data.PriceGap
+	missingDays := 0
+	coveredFrom, coveredTo, isCovered := cal.CoveredRange()
+	if !isCovered {
+		return gaps, missingDays
+	}
+	firstDay := priceHistory[0].DateVal
+	if firstDay.Before(coveredFrom) {
+		firstDay = coveredFrom
+	}
+	if lastExpected.After(coveredTo) {
+		lastExpected = coveredTo
+	}
+	available := make(map[string]bool, len(priceHistory))
+	for _, priceData := range priceHistory {
+		available[priceData.DateVal.Format("2006-01-02")] = true
+	}
+
+	var gapFrom, gapTo string
+	gapDays := 0
+	closeGap := func() {
+		if gapDays > 0 {
+			gaps = append(gaps, data.PriceGap{From: gapFrom, To: gapTo, Days: strconv.Itoa(gapDays)})
+			missingDays += gapDays
+			gapDays = 0
+		}
+	}
+	for day := firstDay; !day.After(lastExpected); day = day.AddDate(0, 0, 1) {
+		if isTrading, _ := cal.IsTradingDay(day); !isTrading {
+			continue
+		}
+		dateStr := day.Format("2006-01-02")
+		if available[dateStr] {
+			closeGap()
+			continue
+		}
+		if gapDays == 0 {
+			gapFrom = dateStr
+		}
+		gapTo = dateStr
+		gapDays++
+	}
+	closeGap()
+	return gaps, missingDays
+}
+
+/* Day on day change in adjusted close beyond thresholdPct. Split/bonus ex dates are excluded */
+func findPriceJumps(priceHistory []data.CompaniesPriceData, excludedDates map[string]bool, thresholdPct money.Decimal) []data.PriceJump {
+	var jumps []data.PriceJump
+	for key := 1; key < len(priceHistory); key++ {
+		prevClose := priceHistory[key-1].AdjCloseVal
+		closeVal := priceHistory[key].AdjCloseVal
+		dateStr := priceHistory[key].DateVal.Format("2006-01-02")
+		if prevClose.Sign() <= 0 || excludedDates[dateStr] {
+			continue
+		}
+		changePct := money.Percent(closeVal.Sub(prevClose), prevClose)
+		if changePct.Abs().GreaterThan(thresholdPct) {
+			jumps = append(jumps, data.PriceJump{
+				Date:      dateStr,
+				PrevClose: money.FormatPrice(prevClose),
+				Close:     money.FormatPrice(closeVal),
+				ChangePct: money.FormatPercent(changePct),
+			})
+		}
+	}
+	return jumps
+}
+
+/* Ex dates of splits and bonus issues of company */
+func splitDates(companyId string) map[string]bool {
+	dates := make(map[string]bool)
+	corporateActions, err := loadCorporateActions()
+	if err != nil {
+		return dates
+	}
+	for _, action := range corporateActions[companyId] {
+		if action.ActionType == data.ActionTypeSplit || action.ActionType == data.ActionTypeBonus {
+			exDate, err := parseTxnDate(action.ExDate)
+			if err == nil {
+				dates[exDate.Format("2006-01-02")] = true
+			}
+		}
+	}
+	return dates
+}
+
+func jumpThresholdPct() money.Decimal {
+	if appUtil.Config.DQJumpPct > 0 {
+		return money.NewFromInt(int64(appUtil.Config.DQJumpPct))
+	}
+	return money.NewFromInt(constants.AppDQJumpPct)
+}
+
+/* Check companies and refetch from first recent gap */
+func backfillPriceGaps(companyIds []string) {
+	changedFrom := make(map[string]time.Time)
+	for _, companyId := range companyIds {
+		_, backfillFrom, err := checkPriceQuality(companyId)
+		if err != nil {
+			appUtil.AppLogger.Println(err.Error(), " Error while checking price quality for CompanyId: "+companyId)
+			continue
+		}
+		if backfillFrom.IsZero() {
+			continue
+		}
+		appUtil.AppLogger.Println("Backfilling prices for CompanyId: " + companyId + " from " + backfillFrom.Format("2006-01-02"))
+		stats, err := ingestCompanyPrices(companyId, backfillFrom)
+		if err != nil {
+			appUtil.AppLogger.Println(err.Error(), " Error while backfilling prices for CompanyId: "+companyId)
+		}
+		if stats.Inserted+stats.Updated > 0 {
+			changedFrom[companyId] = backfillFrom
+		}
+		appUtil.AppLogger.Printf("Backfill CompanyId - %s Inserted - %d Updated - %d Rejected - %d ", companyId, stats.Inserted, stats.Updated, stats.Rejected)
+	}
+	invalidatePriceCaches(companyIds)
+	invalidateCorporateActionsCache()
+	invalidateCompanySnapshots(changedFrom)
+}
@@ -0,0 +1,229 @@
+package processor
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/vijayyogesh/PortfolioApis/constants"
+	"github.com/vijayyogesh/PortfolioApis/data"
+)
+
+/* Company with lower cased fields used for matching */
+type searchEntry struct {
+	company    data.Company
+	id         string
+	name       string
+	nameTokens []string
+	isin       string
+	industry   string
+}
+
+/* Match scores, higher ranks first */
+const (
+	scoreIdExact       = 100
+	scoreIsinExact     = 95
+	scoreIdPrefix      = 90
+	scoreNamePrefix    = 80
+	scoreTokenPrefix   = 70
+	scoreIdContains    = 60
+	scoreNameContains  = 50
+	scoreFuzzy         = 40
+	scoreIndustryMatch = 30
+)
+
+/* Search companies by id, name, ISIN and industry. Every word of query must match, results are ranked and paged */
+func SearchCompanies(userInput []byte) (data.CompanySearchOutputJson, error) {
+	var searchOutput data.CompanySearchOutputJson
+	var searchInput data.CompanySearchInputJson
+	json.Unmarshal(userInput, &searchInput)
+
+	page, err := strconv.Atoi(searchInput.Page)
+	if err != nil || page < 1 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(searchInput.PageSize)
+	if err != nil || pageSize < 1 || pageSize > constants.AppSearchMaxPageSize {
+		pageSize = constants.AppSearchPageSize
+	}
+	searchOutput.Query = searchInput.Query
+	searchOutput.Page = strconv.Itoa(page)
+	searchOutput.PageSize = strconv.Itoa(pageSize)
+	searchOutput.Companies = []data.CompanySearchResult{}
+
+	index, err := getCompanySearchIndex()
+	if err != nil {
+		return searchOutput, err
+	}
+	results := searchCompanyIndex(index, searchInput.Query)
+	searchOutput.Total = strconv.Itoa(len(results))
+
+	start := (page - 1) * pageSize
+	if start < len(results) {
+		end := start + pageSize
+		if end > len(results) {
+			end = len(results)
+		}
+		searchOutput.Companies = results[start:end]
+	}
+	return searchOutput, nil
+}
+
+/* Build index from master list first time. Rebuilt after master list changes */
+func getCompanySearchIndex() ([]searchEntry, error) {
+	index, err := companySearchIndex.GetOrLoad(cacheKeyAll, func() (interface{}, error) {
+		companies, err := FetchCompanies(appUtil.Db)
+		if err != nil {
+			return nil, err
+		}
+		index := make([]searchEntry, 0, len(companies))
+		for _, company := range companies {
+			name := strings.ToLower(company.CompanyName)
+			index = append(index, searchEntry{
+				company:    company,
+				id:         strings.ToLower(company.CompanyId),
+				name:       name,
+				nameTokens: strings.FieldsFunc(name, func(r rune) bool { return !isSearchChar(r) }),
+				isin:       strings.ToLower(company.Isin),
+				industry:   strings.ToLower(company.Industry),
+			})
+		}
+		return index, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return index.([]searchEntry), nil
+}
+
+/* Matching companies ordered by score and then id */
+func searchCompanyIndex(index []searchEntry, query string) []data.CompanySearchResult {
+	var results []data.CompanySearchResult
+	terms := strings.FieldsFunc(strings.ToLower(query), func(r rune) bool { return !isSearchChar(r) })
+	if len(terms) == 0 {
+		return results
+	}
+
+	type scored struct {
+		entry searchEntry
+		score int
+	}
+	var matches []scored
+	for _, entry := range index {
+		total := 0
+		for _, term := range terms {
+			score := scoreTerm(entry, term)
+			if score == 0 {
+				total = 0
+				break
+			}
+			total += score
+		}
+		if total > 0 {
+			matches = append(matches, scored{entry, total / len(terms)})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].score != matches[j].score {
+			return matches[i].score > matches[j].score
+		}
+		return matches[i].entry.id < matches[j].entry.id
+	})
+
+	for _, match := range matches {
+		results = append(results, data.CompanySearchResult{
+			CompanyId:   match.entry.company.CompanyId,
+			CompanyName: match.entry.company.CompanyName,
+			Industry:    match.entry.company.Industry,
+			Isin:        match.entry.company.Isin,
+			Score:       strconv.Itoa(match.score),
+		})
+	}
+	return results
+}
+
+/* Best score of a query word against fields of a company, zero when it does not match */
+func scoreTerm(entry searchEntry, term string) int {
+	switch {
+	case entry.id == term:
+		return scoreIdExact
+	case entry.isin != "" && entry.isin == term:
+		return scoreIsinExact
+	case strings.HasPrefix(entry.id, term):
+		return scoreIdPrefix
+	case strings.HasPrefix(entry.name, term):
+		return scoreNamePrefix
+	}
+	for _, token := range entry.nameTokens {
+		if strings.HasPrefix(token, term) {
+			return scoreTokenPrefix
+		}
+	}
+	switch {
+	case strings.Contains(entry.id, term):
+		return scoreIdContains
+	case strings.Contains(entry.name, term):
+		return scoreNameContains
+	}
+
+	/* Typos are tolerated in words long enough to be told apart */
+	maxEdits := 0
+	if len(term) >= constants.AppSearchFuzzyMinLen {
+		maxEdits = 1
+	}
+	if len(term) >= 2*constants.AppSearchFuzzyMinLen {
+		maxEdits = 2
+	}
+	if maxEdits > 0 {
+		best := maxEdits + 1
+		for _, candidate := range append([]string{entry.id}, entry.nameTokens...) {
+			/* Prefixes of a longer word are compared, so that partially typed words match */
+			for length := len(term) - maxEdits; length <= len(term)+maxEdits && length <= len(candidate); length++ {
+				if distance := editDistance(term, candidate[:length]); distance < best {
+					best = distance
+				}
+			}
+		}
+		if best <= maxEdits {
+			return scoreFuzzy - 10*best
+		}
+	}
+
+	if entry.industry != "" && strings.Contains(entry.industry, term) {
+		return scoreIndustryMatch
+	}
+	return 0
+}
+
+func isSearchChar(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '&' || r == '-'
+}
+
+/* Levenshtein distance between two words */
+func editDistance(a string, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = minInt(minInt(previous[j]+1, current[j-1]+1), previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
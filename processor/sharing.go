@@ -0,0 +1,192 @@
+package processor
+
+import (
+	"encoding/json"
+
+	"github.com/vijayyogesh/PortfolioApis/constants"
+	"github.com/vijayyogesh/PortfolioApis/data"
+)
+
+/* Owner grants read/write access of a portfolio to another user */
+func SharePortfolio(userInput []byte) string {
+	var grantInput data.PortfolioGrantInputJson
+	json.Unmarshal(userInput, &grantInput)
+	grant := grantInput.Grant
+
+	if grant.Permission != data.PermissionRead && grant.Permission != data.PermissionWrite {
+		return constants.AppErrInvalidPermission
+	}
+	if grant.PortfolioId == "" || grant.PortfolioId == AllPortfolios {
+		return constants.AppErrInvalidPortfolio
+	}
+	if grant.GranteeUserId == "" || grant.GranteeUserId == grantInput.UserID {
+		return constants.AppErrInvalidGrantee
+	}
+
+	isGranteePresent, err := verifyUserId(grant.GranteeUserId, appUtil.Db)
+	if err != nil || !isGranteePresent {
+		appUtil.AppLogger.Println(err)
+		return constants.AppErrInvalidGrantee
+	}
+
+	/* Only portfolios owned by the requesting user can be shared */
+	portfolioId, err := resolveWritePortfolioId(grantInput.UserID, grant.PortfolioId)
+	if err != nil {
+		appUtil.AppLogger.Println(err)
+		return constants.AppErrInvalidPortfolio
+	}
+
+	err = data.AddPortfolioGrantDB(portfolioId, grant.GranteeUserId, grant.Permission, appUtil.Db)
+	if err != nil {
+		appUtil.AppLogger.Println(err)
+		return constants.AppErrSharePortfolio
+	}
+	return constants.AppSuccessSharePortfolio
+}
+
+/* Owner revokes access given earlier */
+func RevokePortfolioShare(userInput []byte) string {
+	var grantInput data.PortfolioGrantInputJson
+	json.Unmarshal(userInput, &grantInput)
+
+	if grantInput.Grant.PortfolioId == "" || grantInput.Grant.PortfolioId == AllPortfolios {
+		return constants.AppErrInvalidPortfolio
+	}
+	portfolioId, err := resolveWritePortfolioId(grantInput.UserID, grantInput.Grant.PortfolioId)
+	if err != nil {
+		appUtil.AppLogger.Println(err)
+		return constants.AppErrInvalidPortfolio
+	}
+
+	isRevoked, err := data.DeletePortfolioGrantDB(portfolioId, grantInput.Grant.GranteeUserId, appUtil.Db)
+	if err != nil {
+		appUtil.AppLogger.Println(err)
+		return constants.AppErrSharePortfolio
+	}
+	if !isRevoked {
+		return constants.AppErrGrantNotFound
+	}
+	return constants.AppSuccessRevokePortfolioShare
+}
+
+/* Grants given by user and portfolios shared with user */
+func GetPortfolioShares(userInput []byte) (data.PortfolioGrantsOutputJson, error) {
+	var grantsOutput data.PortfolioGrantsOutputJson
+
+	var user data.User
+	json.Unmarshal(userInput, &user)
+
+	grantedByMe, err := data.GetGrantsByOwnerDB(user.UserId, appUtil.Db)
+	if err != nil {
+		appUtil.AppLogger.Println(err)
+		return grantsOutput, err
+	}
+	sharedWithMe, err := data.GetGrantsForGranteeDB(user.UserId, appUtil.Db)
+	if err != nil {
+		appUtil.AppLogger.Println(err)
+		return grantsOutput, err
+	}
+
+	grantsOutput.UserID = user.UserId
+	grantsOutput.GrantedByMe = grantedByMe
+	grantsOutput.SharedWithMe = sharedWithMe
+	return grantsOutput, nil
+}
+
+/* Check if acting user (from token) can access portfolio of owner (from payload).
+** Owners have full access. Others need a grant on the specific portfolio,
+** consolidated "all" views are limited to the owner. */
+func AuthorizePortfolioAccess(actingUserId string, ownerUserId string, portfolioId string, needWrite bool) bool {
+	if actingUserId == ownerUserId {
+		return true
+	}
+	if portfolioId == "" || portfolioId == AllPortfolios {
+		appUtil.AppLogger.Println("Shared access requires a specific portfolio id")
+		return false
+	}
+
+	/* Portfolio must belong to the owner in payload */
+	portfolioIds, err := resolvePortfolioIds(ownerUserId, portfolioId)
+	if err != nil || len(portfolioIds) != 1 {
+		appUtil.AppLogger.Println(err)
+		return false
+	}
+
+	permission, err := data.GetPortfolioPermissionDB(portfolioIds[0], actingUserId, appUtil.Db)
+	if err != nil {
+		appUtil.AppLogger.Println(err)
+		return false
+	}
+
+	if permission == data.PermissionWrite {
+		return true
+	}
+	return permission == data.PermissionRead && !needWrite
+}
+
+/* Consolidated networth and allocation of own portfolios and portfolios shared with user */
+func GetHouseholdNetworth(userInput []byte) (data.HouseholdOutputJson, error) {
+	var householdOutput data.HouseholdOutputJson
+
+	var user data.User
+	json.Unmarshal(userInput, &user)
+
+	ownPortfolios, err := data.GetPortfoliosDB(user.UserId, appUtil.Db)
+	if err != nil {
+		appUtil.AppLogger.Println(err)
+		return householdOutput, err
+	}
+	sharedWithMe, err := data.GetGrantsForGranteeDB(user.UserId, appUtil.Db)
+	if err != nil {
+		appUtil.AppLogger.Println(err)
+		return householdOutput, err
+	}
+
+	var members []data.HouseholdPortfolio
+	for _, portfolio := range ownPortfolios {
+		members = append(members, data.HouseholdPortfolio{
+			PortfolioId:   portfolio.PortfolioId,
+			PortfolioName: portfolio.PortfolioName,
+			OwnerUserId:   portfolio.UserId,
+		})
+	}
+	for _, grant := range sharedWithMe {
+		members = append(members, data.HouseholdPortfolio{
+			PortfolioId:   grant.PortfolioId,
+			PortfolioName: grant.PortfolioName,
+			OwnerUserId:   grant.OwnerUserId,
+			Permission:    grant.Permission,
+		})
+	}
+
+	/* Networth and allocation per portfolio */
+	var householdIds []int64
+	for key, member := range members {
+		portfolioId, err := data.ParsePortfolioId(member.PortfolioId)
+		if err != nil {
+			appUtil.AppLogger.Println(err)
+			return householdOutput, err
+		}
+		householdIds = append(householdIds, portfolioId)
+
+		portfolioHoldings, err := getPortfoliosHoldings([]int64{portfolioId}, false)
+		if err != nil {
+			return householdOutput, err
+		}
+		members[key].Networth = portfolioHoldings.Networth
+		members[key].Allocation = portfolioHoldings.Allocation
+	}
+
+	/* Consolidated view across all portfolios of the household */
+	householdHoldings, err := getPortfoliosHoldings(householdIds, true)
+	if err != nil {
+		return householdOutput, err
+	}
+
+	householdOutput.UserID = user.UserId
+	householdOutput.Networth = householdHoldings.Networth
+	householdOutput.Allocation = householdHoldings.Allocation
+	householdOutput.Holdings = householdHoldings.Holdings
+	householdOutput.Portfolios = members
+	return householdOutput, nil
+}
@@ -0,0 +1,279 @@
+package processor
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/vijayyogesh/PortfolioApis/constants"
+	"github.com/vijayyogesh/PortfolioApis/data"
+	"github.com/vijayyogesh/PortfolioApis/money"
+)
+
+/* Snapshots are of consolidated portfolio of user with price return, other views are computed on request */
+func isSnapshotView(user data.User, returnType string) bool {
+	return returnType == data.ReturnTypePrice && (user.PortfolioId == "" || user.PortfolioId == AllPortfolios)
+}
+
+/* Snapshot job. Save snapshots of every user after their last snapshot till yesterday */
+func RefreshPortfolioSnapshots() string {
+	users, err := data.FetchUniqueUsersDB(appUtil.Db)
+	if err != nil {
+		appUtil.AppLogger.Println(err)
+		return constants.AppErrRefreshSnapshots
+	}
+
+	var failed []string
+	days := 0
+	for _, user := range users {
+		saved, err := refreshUserSnapshots(user.UserId)
+		if err != nil {
+			appUtil.AppLogger.Println(err.Error(), " Error while saving snapshots of user "+user.UserId)
+			failed = append(failed, user.UserId)
+		}
+		days += saved
+	}
+	return fmt.Sprintf("Snapshots saved for %d users, %d days. Failed - %d %v", len(users)-len(failed), days, len(failed), failed)
+}
+
+/* Compute snapshots of user from the day after last snapshot. Today is left out as its prices may still change.
+** Snapshots invalidated while computing are not saved and are picked up by next run */
+func refreshUserSnapshots(userId string) (int, error) {
+	version, err := data.GetSnapshotVersionDB(userId, appUtil.Db)
+	if err != nil {
+		return 0, err
+	}
+	lastDate, err := data.GetLastSnapshotDateDB(userId, appUtil.Db)
+	if err != nil {
+		return 0, err
+	}
+	from := time.Time{}
+	if !lastDate.IsZero() {
+		from = lastDate.AddDate(0, 0, 1)
+	}
+	today := time.Now().Format("2006-01-02")
+	if from.Format("2006-01-02") >= today {
+		return 0, nil
+	}
+
+	user := data.User{UserId: userId}
+	userInput, err := json.Marshal(user)
+	if err != nil {
+		return 0, err
+	}
+	netWorth, err := netWorthOverPeriods(userInput, data.ReturnTypePrice, from)
+	if err != nil {
+		return 0, err
+	}
+	xirr, err := xirrOverPeriods(user, userInput, data.ReturnTypePrice, from)
+	if err != nil {
+		return 0, err
+	}
+
+	snapshots := buildSnapshots(netWorth, xirr, today)
+	if len(snapshots) == 0 {
+		return 0, nil
+	}
+	isSaved, err := data.SavePortfolioSnapshotsDB(userId, version, snapshots, appUtil.Db)
+	if err != nil {
+		return 0, err
+	}
+	if !isSaved {
+		appUtil.AppLogger.Println("Snapshots of user " + userId + " were invalidated while computing, left for next run")
+		return 0, nil
+	}
+	return len(snapshots), nil
+}
+
+/* Snapshot of each day before date having any value */
+func buildSnapshots(netWorth netWorthSeries, xirr xirrSeries, beforeDate string) []data.PortfolioSnapshot {
+	var snapshots []data.PortfolioSnapshot
+	dateSet := make(map[string]bool)
+	for _, series := range []map[string]money.Decimal{netWorth.networth, netWorth.invested, netWorth.debt} {
+		for dateStr := range series {
+			dateSet[dateStr] = true
+		}
+	}
+	for dateStr := range xirr.portfolio {
+		dateSet[dateStr] = true
+	}
+	var dates []string
+	for dateStr := range dateSet {
+		if dateStr < beforeDate {
+			dates = append(dates, dateStr)
+		}
+	}
+	sort.Strings(dates)
+
+	nullDecimal := func(series map[string]money.Decimal, dateStr string) money.NullDecimal {
+		val, isPresent := series[dateStr]
+		return money.NullDecimal{Decimal: val, Valid: isPresent}
+	}
+	nullFloat := func(series map[string]float64, dateStr string) sql.NullFloat64 {
+		val, isPresent := series[dateStr]
+		return sql.NullFloat64{Float64: val, Valid: isPresent}
+	}
+	for _, dateStr := range dates {
+		snapshots = append(snapshots, data.PortfolioSnapshot{
+			SnapshotDate:   dateStr,
+			MarketValue:    nullDecimal(netWorth.networth, dateStr),
+			Invested:       nullDecimal(netWorth.invested, dateStr),
+			EquityValue:    nullDecimal(netWorth.equity, dateStr),
+			DebtValue:      nullDecimal(netWorth.debt, dateStr),
+			BenchmarkValue: nullDecimal(netWorth.benchmark, dateStr),
+			Xirr:           nullFloat(xirr.portfolio, dateStr),
+			BenchmarkXirr:  nullFloat(xirr.benchmark, dateStr),
+		})
+	}
+	return snapshots
+}
+
+/* Day after last snapshot from which values are computed on request, zero when there are no snapshots */
+func afterSnapshots(snapshots []data.PortfolioSnapshot) time.Time {
+	if len(snapshots) == 0 {
+		return time.Time{}
+	}
+	lastDate, _ := time.Parse("2006-01-02", snapshots[len(snapshots)-1].SnapshotDate)
+	return lastDate.AddDate(0, 0, 1)
+}
+
+/* Networth chart from snapshots, with days after last snapshot computed. Computed fully when snapshots cannot be read */
+func netWorthFromSnapshots(userId string, userInput []byte) (netWorthSeries, error) {
+	snapshots, err := data.GetPortfolioSnapshotsDB(userId, appUtil.Db)
+	if err != nil {
+		appUtil.AppLogger.Println(err)
+		return netWorthOverPeriods(userInput, data.ReturnTypePrice, time.Time{})
+	}
+
+	series, err := netWorthOverPeriods(userInput, data.ReturnTypePrice, afterSnapshots(snapshots))
+	if err != nil {
+		return series, err
+	}
+	for _, snapshot := range snapshots {
+		setValid := func(series map[string]money.Decimal, val money.NullDecimal) {
+			if val.Valid {
+				series[snapshot.SnapshotDate] = val.Decimal
+			}
+		}
+		setValid(series.networth, snapshot.MarketValue)
+		setValid(series.invested, snapshot.Invested)
+		setValid(series.equity, snapshot.EquityValue)
+		setValid(series.debt, snapshot.DebtValue)
+		setValid(series.benchmark, snapshot.BenchmarkValue)
+	}
+	return series, nil
+}
+
+/* Xirr from snapshots, with days after last snapshot computed. Computed fully when snapshots cannot be read */
+func xirrFromSnapshots(user data.User, userInput []byte) (xirrSeries, error) {
+	snapshots, err := data.GetPortfolioSnapshotsDB(user.UserId, appUtil.Db)
+	if err != nil {
+		appUtil.AppLogger.Println(err)
+		return xirrOverPeriods(user, userInput, data.ReturnTypePrice, time.Time{})
+	}
+
+	series, err := xirrOverPeriods(user, userInput, data.ReturnTypePrice, afterSnapshots(snapshots))
+	if err != nil {
+		return series, err
+	}
+	for _, snapshot := range snapshots {
+		if snapshot.Xirr.Valid {
+			series.portfolio[snapshot.SnapshotDate] = snapshot.Xirr.Float64
+		}
+		if snapshot.BenchmarkXirr.Valid {
+			series.benchmark[snapshot.SnapshotDate] = snapshot.BenchmarkXirr.Float64
+		}
+	}
+	return series, nil
+}
+
+/* Holdings of user changed from date. Snapshots from date are deleted and recomputed in background */
+func invalidateSnapshots(userId string, fromDate time.Time) {
+	if !deleteSnapshots(userId, fromDate) {
+		return
+	}
+	go func() {
+		_, err := refreshUserSnapshots(userId)
+		if err != nil {
+			appUtil.AppLogger.Println(err.Error(), " Error while saving snapshots of user "+userId)
+		}
+	}()
+}
+
+/* Delete snapshots of user from date. Returns false when there was nothing to delete or delete failed */
+func deleteSnapshots(userId string, fromDate time.Time) bool {
+	if fromDate.IsZero() || fromDate.Format("2006-01-02") >= time.Now().Format("2006-01-02") {
+		return false
+	}
+	err := data.InvalidatePortfolioSnapshotsDB(userId, fromDate, appUtil.Db)
+	if err != nil {
+		appUtil.AppLogger.Println(err.Error(), " Error while invalidating snapshots of user "+userId)
+		return false
+	}
+	return true
+}
+
+/* Prices or corporate actions of companies changed from the given dates. Snapshots of users holding a company are deleted
+** from the later of change date and their first transaction in it, a benchmark change affects every user.
+** Days deleted are computed on request till the next snapshot job saves them again */
+func invalidateCompanySnapshots(changedFrom map[string]time.Time) {
+	if len(changedFrom) == 0 {
+		return
+	}
+	userFrom := make(map[string]time.Time)
+	consider := func(userId string, fromDate time.Time) {
+		if earliest, isPresent := userFrom[userId]; !isPresent || fromDate.Before(earliest) {
+			userFrom[userId] = fromDate
+		}
+	}
+
+	if bmFrom, isPresent := changedFrom[benchmarkId()]; isPresent {
+		users, err := data.FetchUniqueUsersDB(appUtil.Db)
+		if err != nil {
+			appUtil.AppLogger.Println(err.Error(), " Error while fetching users to invalidate snapshots")
+		}
+		for _, user := range users {
+			consider(user.UserId, bmFrom)
+		}
+	}
+
+	var companyIds []string
+	for companyId := range changedFrom {
+		companyIds = append(companyIds, companyId)
+	}
+	holders, err := data.GetCompanyHoldersDB(companyIds, appUtil.Db)
+	if err != nil {
+		appUtil.AppLogger.Println(err.Error(), " Error while fetching holders to invalidate snapshots")
+	}
+	for _, holder := range holders {
+		fromDate := changedFrom[holder.CompanyId]
+		if holder.FirstTxnDate.After(fromDate) {
+			fromDate = holder.FirstTxnDate
+		}
+		consider(holder.UserId, fromDate)
+	}
+
+	for userId, fromDate := range userFrom {
+		deleteSnapshots(userId, fromDate)
+	}
+}
+
+/* Earliest date of ledger entries and non tracked holdings being added */
+func earliestHoldingsDate(holdingsInput data.HoldingsInputJson) time.Time {
+	var earliest time.Time
+	consider := func(dateStr string) {
+		date, err := parseTxnDate(dateStr)
+		if err == nil && (earliest.IsZero() || date.Before(earliest)) {
+			earliest = date
+		}
+	}
+	for _, txn := range holdingsInput.Transactions {
+		consider(txn.TxnDate)
+	}
+	for _, holdingNT := range holdingsInput.HoldingsNT {
+		consider(holdingNT.BuyDate)
+	}
+	return earliest
+}
@@ -0,0 +1,122 @@
+package processor
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vijayyogesh/PortfolioApis/constants"
+)
+
+/* HTTP stand-in for Yahoo Finance and NSE India serving files of a fixture directory.
+** Point PRICE_PROVIDER_URL/MASTER_LIST_PROVIDER_URL at it to run ingestion end to end offline.
+**   GET /v7/finance/download/<yahoo symbol>?period1=..&period2=..  -> <companyId>.csv rows within period
+**   GET /v7/finance/download/<yahoo symbol>?events=div|split         -> <companyId>_div.csv/<companyId>_split.csv rows
+**   GET /content/indices/ind_nifty500list.csv                      -> TOP500.csv
+**   GET /content/indices/<list>.csv                                 -> <list>.csv
+**   GET /spages/NAVAll.txt                                          -> NAVAll.txt
+**   GET /DownloadNAVHistoryReport_Po.aspx?frmdt=..&todt=..          -> NAVHistory.txt rows within dates */
+type StandInServer struct {
+	Dir string
+}
+
+func NewStandInServer(dir string) *StandInServer {
+	return &StandInServer{Dir: dir}
+}
+
+func (server *StandInServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == constants.AppStandInMasterPath {
+		http.ServeFile(w, r, filepath.Join(server.Dir, constants.AppDataMasterFile))
+	} else if strings.HasPrefix(r.URL.Path, constants.AppStandInIndicesPath) {
+		http.ServeFile(w, r, filepath.Join(server.Dir, filepath.Base(r.URL.Path)))
+	} else if r.URL.Path == constants.AppStandInNavPath {
+		http.ServeFile(w, r, filepath.Join(server.Dir, constants.AppAmfiNavFile))
+	} else if r.URL.Path == constants.AppStandInHistoryPath {
+		server.serveNavHistory(w, r)
+	} else if strings.HasPrefix(r.URL.Path, constants.AppStandInPricesPath) {
+		symbol := strings.TrimPrefix(r.URL.Path, constants.AppStandInPricesPath)
+		server.servePrices(w, r, companyIdForSymbol(symbol))
+	} else {
+		http.NotFound(w, r)
+	}
+}
+
+/* Write header and rows whose date falls between period1 and period2 (unix seconds) */
+func (server *StandInServer) servePrices(w http.ResponseWriter, r *http.Request, companyId string) {
+	fileName := filepath.Base(companyId)
+	events := r.URL.Query().Get("events")
+	if events == constants.AppDataEventsDividend || events == constants.AppDataEventsSplit {
+		fileName = fileName + "_" + events
+	}
+	file, err := os.Open(filepath.Join(server.Dir, fileName+constants.AppDataCsv))
+	if os.IsNotExist(err) && fileName != filepath.Base(companyId) {
+		/* No events for company */
+		w.Header().Set("Content-Type", "text/csv")
+		return
+	}
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer file.Close()
+
+	fromTime := unixParam(r, "period1", time.Time{})
+	toTime := unixParam(r, "period2", time.Now())
+
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	csvWriter := csv.NewWriter(w)
+	for k, record := range records {
+		if k != 0 {
+			dateVal, err := time.Parse("2006-01-02", record[0])
+			if err == nil && (dateVal.Before(fromTime.Truncate(24*time.Hour)) || dateVal.After(toTime)) {
+				continue
+			}
+		}
+		csvWriter.Write(record)
+	}
+	csvWriter.Flush()
+}
+
+/* Write header and NAV rows whose date falls between frmdt and todt (dd-Mon-yyyy). Other lines are kept */
+func (server *StandInServer) serveNavHistory(w http.ResponseWriter, r *http.Request) {
+	file, err := os.Open(filepath.Join(server.Dir, constants.AppAmfiHistoryFile))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer file.Close()
+
+	fromTime, errFrom := time.Parse("02-Jan-2006", r.URL.Query().Get("frmdt"))
+	toTime, errTo := time.Parse("02-Jan-2006", r.URL.Query().Get("todt"))
+	w.Header().Set("Content-Type", "text/plain")
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		fields := strings.Split(line, ";")
+		navDate, errDate := time.Parse("02-Jan-2006", strings.TrimSpace(fields[len(fields)-1]))
+		if errDate == nil && ((errFrom == nil && navDate.Before(fromTime)) || (errTo == nil && navDate.After(toTime))) {
+			continue
+		}
+		fmt.Fprintln(w, line)
+	}
+}
+
+func unixParam(r *http.Request, name string, defaultTime time.Time) time.Time {
+	seconds, err := strconv.ParseInt(r.URL.Query().Get(name), 10, 64)
+	if err != nil {
+		return defaultTime
+	}
+	return time.Unix(seconds, 0).UTC()
+}
@@ -0,0 +1,116 @@
+package processor
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/vijayyogesh/PortfolioApis/constants"
+	"github.com/vijayyogesh/PortfolioApis/data"
+	"github.com/vijayyogesh/PortfolioApis/money"
+	"github.com/vijayyogesh/PortfolioApis/timeseries"
+)
+
+/* Period of a time weighted return, counted back from the end date */
+type twrPeriod struct {
+	name  string
+	start func(endDate time.Time) time.Time
+}
+
+var twrPeriods = []twrPeriod{
+	{constants.TwrPeriodMTD, func(endDate time.Time) time.Time {
+		return time.Date(endDate.Year(), endDate.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}},
+	{constants.TwrPeriodQTD, func(endDate time.Time) time.Time {
+		return time.Date(endDate.Year(), endDate.Month()-(endDate.Month()-1)%3, 1, 0, 0, 0, 0, time.UTC)
+	}},
+	{constants.TwrPeriodYTD, func(endDate time.Time) time.Time {
+		return time.Date(endDate.Year(), time.January, 1, 0, 0, 0, 0, time.UTC)
+	}},
+	{constants.TwrPeriod1Y, func(endDate time.Time) time.Time { return yearsBefore(endDate, 1).AddDate(0, 0, 1) }},
+	{constants.TwrPeriod3Y, func(endDate time.Time) time.Time { return yearsBefore(endDate, 3).AddDate(0, 0, 1) }},
+	{constants.TwrPeriod5Y, func(endDate time.Time) time.Time { return yearsBefore(endDate, 5).AddDate(0, 0, 1) }},
+}
+
+/* Same day years back, 29 Feb goes to 28 Feb instead of rolling over into March */
+func yearsBefore(endDate time.Time, years int) time.Time {
+	day := endDate.Day()
+	if lastDay := time.Date(endDate.Year()-years, endDate.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day(); day > lastDay {
+		day = lastDay
+	}
+	return time.Date(endDate.Year()-years, endDate.Month(), day, 0, 0, 0, 0, time.UTC)
+}
+
+/* 15) Time weighted return of portfolio and benchmark over standard periods.
+** NAV chain links daily returns net of Buy/Sell flows, so TWR of a period is the growth of NAV from close
+** of the day before it. Benchmark has no flows and its TWR is the growth of the index */
+func CalculateTwrReturn(userInput []byte) (data.TwrOutputJson, error) {
+	var twrOutput data.TwrOutputJson
+	var user data.User
+	json.Unmarshal(userInput, &user)
+	returnType, err := parseReturnType(user.ReturnType)
+	if err != nil {
+		appUtil.AppLogger.Println(err)
+		return twrOutput, err
+	}
+
+	series, portfolioId, err := requestedNavSeries(user, returnType)
+	if err != nil {
+		appUtil.AppLogger.Println(err)
+		return twrOutput, err
+	}
+	twrOutput.UserID = user.UserId
+	twrOutput.PortfolioId = portfolioId
+	twrOutput.ReturnType = returnType
+	twrOutput.BenchmarkId = benchmarkId()
+	twrOutput.Periods = twrPeriodReturns(series, fetchReturnPrices(twrOutput.BenchmarkId, returnType))
+	return twrOutput, nil
+}
+
+/* Returns of periods fully covered by portfolio, and since inception. Periods are skipped when benchmark has no value to start from */
+func twrPeriodReturns(series navSeries, bmPrices *timeseries.Series) []data.TwrPeriodReturn {
+	periodReturns := []data.TwrPeriodReturn{}
+	if series.startDate.IsZero() || len(series.nav) == 0 {
+		return periodReturns
+	}
+
+	/* NAV is at base value on close of the day before inception */
+	inceptionDate := time.Date(series.startDate.Year(), series.startDate.Month(), series.startDate.Day(), 0, 0, 0, 0, time.UTC)
+	dates := []time.Time{inceptionDate.AddDate(0, 0, -1)}
+	values := []money.Decimal{money.NewFromInt(constants.ReturnBaseValue)}
+	for dateStr, nav := range series.nav {
+		date, _ := time.Parse("2006-01-02", dateStr)
+		dates = append(dates, date)
+		values = append(values, nav)
+	}
+	navs := timeseries.New(dates, values)
+	endDate := navs.Date(navs.Len() - 1)
+	endNav := navs.Value(navs.Len() - 1)
+	bmEnd, bmEndOk := bmPrices.AsOf(endDate)
+
+	periodReturn := func(name string, startDate time.Time) {
+		/* Period is from close of day before it */
+		fromDate := startDate.AddDate(0, 0, -1)
+		startNav, ok := navs.AsOf(fromDate)
+		if !ok || !bmEndOk {
+			return
+		}
+		bmStart, ok := bmPrices.AsOf(fromDate)
+		if !ok || bmStart.Sign() <= 0 {
+			return
+		}
+		periodReturns = append(periodReturns, data.TwrPeriodReturn{
+			Period:              name,
+			StartDate:           startDate.Format("2006-01-02"),
+			EndDate:             endDate.Format("2006-01-02"),
+			PortfolioReturn:     money.FormatPercent(money.Percent(endNav.Sub(startNav), startNav)),
+			PortfolioAnnualized: money.FormatPercent(annualizedReturn(startNav, endNav, fromDate, endDate)),
+			BenchmarkReturn:     money.FormatPercent(money.Percent(bmEnd.Sub(bmStart), bmStart)),
+			BenchmarkAnnualized: money.FormatPercent(annualizedReturn(bmStart, bmEnd, fromDate, endDate)),
+		})
+	}
+	for _, period := range twrPeriods {
+		periodReturn(period.name, period.start(endDate))
+	}
+	periodReturn(constants.TwrPeriodInception, inceptionDate)
+	return periodReturns
+}
@@ -0,0 +1,173 @@
+package processor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vijayyogesh/PortfolioApis/constants"
+	"github.com/vijayyogesh/PortfolioApis/data"
+	"github.com/vijayyogesh/PortfolioApis/money"
+	"github.com/vijayyogesh/PortfolioApis/timeseries"
+)
+
+/* Weekday values from start to end, at low before step date and at high from it */
+func testStepValues(start string, end string, step string, low int64, high int64) ([]time.Time, []money.Decimal) {
+	var dates []time.Time
+	var values []money.Decimal
+	for day := testDate(start); !day.After(testDate(end)); day = day.AddDate(0, 0, 1) {
+		if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+			continue
+		}
+		dates = append(dates, day)
+		if day.Before(testDate(step)) {
+			values = append(values, money.NewFromInt(low))
+		} else {
+			values = append(values, money.NewFromInt(high))
+		}
+	}
+	return dates, values
+}
+
+/* NAV goes from base value of 10 to 12 on step date */
+func testNavSeries(start string, end string, step string) navSeries {
+	series := navSeries{nav: make(map[string]money.Decimal), startDate: testDate(start)}
+	dates, values := testStepValues(start, end, step, constants.ReturnBaseValue, 12)
+	for key, date := range dates {
+		series.nav[date.Format("2006-01-02")] = values[key]
+	}
+	return series
+}
+
+/* Benchmark goes from 100 to 110 on step date */
+func testBenchmark(start string, end string, step string) *timeseries.Series {
+	return timeseries.New(testStepValues(start, end, step, 100, 110))
+}
+
+func TestTwrPeriodReturns(t *testing.T) {
+	tests := []struct {
+		name   string
+		series navSeries
+		bm     *timeseries.Series
+		end    string
+		want   []data.TwrPeriodReturn
+	}{
+		{
+			name:   "all periods held, end in second month of quarter",
+			series: testNavSeries("2019-01-01", "2024-05-15", "2024-04-15"),
+			bm:     testBenchmark("2018-12-03", "2024-05-15", "2024-04-15"),
+			end:    "2024-05-15",
+			want: []data.TwrPeriodReturn{
+				{Period: constants.TwrPeriodMTD, StartDate: "2024-05-01", PortfolioReturn: "0.00", BenchmarkReturn: "0.00"},
+				{Period: constants.TwrPeriodQTD, StartDate: "2024-04-01", PortfolioReturn: "20.00", BenchmarkReturn: "10.00"},
+				{Period: constants.TwrPeriodYTD, StartDate: "2024-01-01", PortfolioReturn: "20.00", BenchmarkReturn: "10.00"},
+				{Period: constants.TwrPeriod1Y, StartDate: "2023-05-16", PortfolioReturn: "20.00", BenchmarkReturn: "10.00"},
+				{Period: constants.TwrPeriod3Y, StartDate: "2021-05-16", PortfolioReturn: "20.00", BenchmarkReturn: "10.00"},
+				{Period: constants.TwrPeriod5Y, StartDate: "2019-05-16", PortfolioReturn: "20.00", BenchmarkReturn: "10.00"},
+				{Period: constants.TwrPeriodInception, StartDate: "2019-01-01", PortfolioReturn: "20.00", BenchmarkReturn: "10.00"},
+			},
+		},
+		{
+			name:   "quarter start in December",
+			series: testNavSeries("2019-01-01", "2023-12-29", "2023-12-15"),
+			bm:     testBenchmark("2018-12-03", "2023-12-29", "2023-12-15"),
+			end:    "2023-12-29",
+			want: []data.TwrPeriodReturn{
+				{Period: constants.TwrPeriodMTD, StartDate: "2023-12-01"},
+				{Period: constants.TwrPeriodQTD, StartDate: "2023-10-01"},
+				{Period: constants.TwrPeriodYTD, StartDate: "2023-01-01"},
+				{Period: constants.TwrPeriod1Y, StartDate: "2022-12-30"},
+				{Period: constants.TwrPeriod3Y, StartDate: "2020-12-30"},
+				{Period: constants.TwrPeriodInception, StartDate: "2019-01-01"},
+			},
+		},
+		{
+			/* Periods end on 29 Feb, so they start on 1 Mar of years without it */
+			name:   "end on leap day",
+			series: testNavSeries("2019-01-01", "2024-02-29", "2024-02-15"),
+			bm:     testBenchmark("2018-12-03", "2024-02-29", "2024-02-15"),
+			end:    "2024-02-29",
+			want: []data.TwrPeriodReturn{
+				{Period: constants.TwrPeriodMTD, StartDate: "2024-02-01"},
+				{Period: constants.TwrPeriodQTD, StartDate: "2024-01-01"},
+				{Period: constants.TwrPeriodYTD, StartDate: "2024-01-01"},
+				{Period: constants.TwrPeriod1Y, StartDate: "2023-03-01"},
+				{Period: constants.TwrPeriod3Y, StartDate: "2021-03-01"},
+				{Period: constants.TwrPeriod5Y, StartDate: "2019-03-01"},
+				{Period: constants.TwrPeriodInception, StartDate: "2019-01-01"},
+			},
+		},
+		{
+			/* 1Y is from close of 2023-05-15, which is the base value of NAV */
+			name:   "held exactly one year",
+			series: testNavSeries("2023-05-16", "2024-05-15", "2024-04-15"),
+			bm:     testBenchmark("2018-12-03", "2024-05-15", "2024-04-15"),
+			end:    "2024-05-15",
+			want: []data.TwrPeriodReturn{
+				{Period: constants.TwrPeriodMTD, StartDate: "2024-05-01"},
+				{Period: constants.TwrPeriodQTD, StartDate: "2024-04-01"},
+				{Period: constants.TwrPeriodYTD, StartDate: "2024-01-01"},
+				{Period: constants.TwrPeriod1Y, StartDate: "2023-05-16", PortfolioReturn: "20.00", BenchmarkReturn: "10.00"},
+				{Period: constants.TwrPeriodInception, StartDate: "2023-05-16", PortfolioReturn: "20.00", BenchmarkReturn: "10.00"},
+			},
+		},
+		{
+			name:   "held a day short of one year",
+			series: testNavSeries("2023-05-17", "2024-05-15", "2024-04-15"),
+			bm:     testBenchmark("2018-12-03", "2024-05-15", "2024-04-15"),
+			end:    "2024-05-15",
+			want: []data.TwrPeriodReturn{
+				{Period: constants.TwrPeriodMTD, StartDate: "2024-05-01"},
+				{Period: constants.TwrPeriodQTD, StartDate: "2024-04-01"},
+				{Period: constants.TwrPeriodYTD, StartDate: "2024-01-01"},
+				{Period: constants.TwrPeriodInception, StartDate: "2023-05-17"},
+			},
+		},
+		{
+			name:   "benchmark not covering start of longer periods",
+			series: testNavSeries("2019-01-01", "2024-05-15", "2024-04-15"),
+			bm:     testBenchmark("2023-01-02", "2024-05-15", "2024-04-15"),
+			end:    "2024-05-15",
+			want: []data.TwrPeriodReturn{
+				{Period: constants.TwrPeriodMTD, StartDate: "2024-05-01"},
+				{Period: constants.TwrPeriodQTD, StartDate: "2024-04-01"},
+				{Period: constants.TwrPeriodYTD, StartDate: "2024-01-01"},
+				{Period: constants.TwrPeriod1Y, StartDate: "2023-05-16"},
+			},
+		},
+		{
+			name:   "no benchmark prices",
+			series: testNavSeries("2019-01-01", "2024-05-15", "2024-04-15"),
+			bm:     timeseries.New(nil, nil),
+			want:   []data.TwrPeriodReturn{},
+		},
+		{
+			name:   "no nav",
+			series: navSeries{nav: make(map[string]money.Decimal)},
+			bm:     testBenchmark("2018-12-03", "2024-05-15", "2024-04-15"),
+			want:   []data.TwrPeriodReturn{},
+		},
+	}
+
+	for _, test := range tests {
+		got := twrPeriodReturns(test.series, test.bm)
+		if len(got) != len(test.want) {
+			t.Errorf("%s: got %d periods %+v, want %d", test.name, len(got), got, len(test.want))
+			continue
+		}
+		for key, want := range test.want {
+			period := got[key]
+			if period.Period != want.Period || period.StartDate != want.StartDate {
+				t.Errorf("%s: period %d = %s from %s, want %s from %s", test.name, key, period.Period, period.StartDate, want.Period, want.StartDate)
+			}
+			if period.EndDate != test.end {
+				t.Errorf("%s: %s ends on %s, want %s", test.name, period.Period, period.EndDate, test.end)
+			}
+			if want.PortfolioReturn != "" && period.PortfolioReturn != want.PortfolioReturn {
+				t.Errorf("%s: %s portfolio return = %s, want %s", test.name, period.Period, period.PortfolioReturn, want.PortfolioReturn)
+			}
+			if want.BenchmarkReturn != "" && period.BenchmarkReturn != want.BenchmarkReturn {
+				t.Errorf("%s: %s benchmark return = %s, want %s", test.name, period.Period, period.BenchmarkReturn, want.BenchmarkReturn)
+			}
+		}
+	}
+}
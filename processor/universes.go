@@ -0,0 +1,213 @@
+package processor
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/vijayyogesh/PortfolioApis/constants"
+	"github.com/vijayyogesh/PortfolioApis/data"
+)
+
+/* Index or custom list of instruments whose constituents csv is loaded into master list.
+** Source is a file name under NSE indices url or a complete url */
+type Universe struct {
+	Name   string
+	Source string
+}
+
+/* Index constituent lists published by NSE */
+var nseUniverses = map[string]string{
+	"NIFTY50":          "ind_nifty50list.csv",
+	"NIFTY100":         "ind_nifty100list.csv",
+	"NIFTY500":         "ind_nifty500list.csv",
+	"NIFTYMIDCAP150":   "ind_niftymidcap150list.csv",
+	"NIFTYSMALLCAP250": "ind_niftysmallcap250list.csv",
+}
+
+/* Universes from APP_UNIVERSES, Nifty 500 when not configured.
+** Entries are NSE index names or NAME=url for other lists (eg: BSE100=https://.../bse100.csv) separated by ; */
+func configuredUniverses() ([]Universe, error) {
+	var universes []Universe
+	spec := appUtil.Config.Universes
+	if strings.TrimSpace(spec) == "" {
+		spec = constants.AppDefaultUniverse
+	}
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		entryParts := strings.SplitN(entry, "=", 2)
+		name := strings.ToUpper(strings.TrimSpace(entryParts[0]))
+		if len(entryParts) == 2 {
+			universes = append(universes, Universe{Name: name, Source: strings.TrimSpace(entryParts[1])})
+			continue
+		}
+		source, isPresent := nseUniverses[name]
+		if !isPresent {
+			return universes, fmt.Errorf("unknown universe %s, provide its url as %s=<url>", name, name)
+		}
+		universes = append(universes, Universe{Name: name, Source: source})
+	}
+	return universes, nil
+}
+
+/* File of universe in data and fixture directories. Nifty 500 keeps its original file name */
+func (universe Universe) FileName() string {
+	if universe.Name == constants.AppDefaultUniverse {
+		return constants.AppDataMasterFile
+	}
+	return path.Base(universe.Source)
+}
+
+/* Parse constituents csv of NSE (Company Name,Industry,Symbol,Series,ISIN Code) or
+** BSE (Security Id,Security Name,Industry,ISIN No,...) format. Columns are matched by header name */
+func ReadMasterListCsv(reader io.Reader, universeName string) ([]data.Company, []data.Instrument, error) {
+	var companies []data.Company
+	var instruments []data.Instrument
+
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = -1
+	header, err := csvReader.Read()
+	if err == io.EOF {
+		return companies, instruments, nil
+	}
+	if err != nil {
+		return companies, instruments, fmt.Errorf("error while reading header of universe %s: %v", universeName, err)
+	}
+	columns := make(map[string]int)
+	for key, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = key
+	}
+	column := func(names ...string) int {
+		for _, name := range names {
+			if key, isPresent := columns[name]; isPresent {
+				return key
+			}
+		}
+		return -1
+	}
+
+	exchange := data.ExchangeNSE
+	symbolColumn := column("symbol")
+	if symbolColumn < 0 {
+		symbolColumn = column("security id", "scrip id")
+		exchange = data.ExchangeBSE
+	}
+	nameColumn := column("company name", "security name", "issuer name", "scrip name")
+	industryColumn := column("industry", "sector")
+	isinColumn := column("isin code", "isin no", "isin")
+	if symbolColumn < 0 || nameColumn < 0 {
+		return companies, instruments, fmt.Errorf("symbol or company name column missing in universe %s", universeName)
+	}
+
+	field := func(record []string, key int) string {
+		if key < 0 || key >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[key])
+	}
+	for {
+		record, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return companies, instruments, fmt.Errorf("error while reading universe %s: %v", universeName, err)
+		}
+		company := data.Company{
+			CompanyId:   field(record, symbolColumn),
+			CompanyName: field(record, nameColumn),
+			Industry:    field(record, industryColumn),
+			Isin:        field(record, isinColumn),
+		}
+		if company.CompanyId == "" {
+			continue
+		}
+		symbolSuffix := constants.AppDataSymbolSuffixNSE
+		if exchange == data.ExchangeBSE {
+			symbolSuffix = constants.AppDataSymbolSuffixBSE
+		}
+		companies = append(companies, company)
+		instruments = append(instruments, data.Instrument{CompanyId: company.CompanyId, Exchange: exchange, AssetClass: data.AssetClassEquity,
+			Currency: data.CurrencyINR, ProviderSymbol: company.CompanyId + symbolSuffix, Isin: company.Isin})
+	}
+	return companies, instruments, nil
+}
+
+/* Add companies of universe to master list and make them its members from today */
+func loadUniverse(universeName string, companies []data.Company, instruments []data.Instrument) error {
+	err := LoadCompaniesMasterList(companies)
+	if err != nil {
+		return err
+	}
+
+	/* Master list companies are equities unless edited by admin */
+	err = data.LoadInstrumentsMasterListDB(instruments, appUtil.Db)
+	invalidateMasterListCaches()
+	if err != nil {
+		return err
+	}
+
+	var companyIds []string
+	for _, company := range companies {
+		companyIds = append(companyIds, company.CompanyId)
+	}
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	return data.LoadIndexMembershipDB(universeName, companyIds, today, appUtil.Db)
+}
+
+/* Admin route to load a custom universe from uploaded constituents csv */
+func UploadUniverse(userInput []byte) string {
+	var universeInput data.UniverseInputJson
+	err := json.Unmarshal(userInput, &universeInput)
+	universeName := strings.ToUpper(strings.TrimSpace(universeInput.IndexId))
+	if err != nil || universeName == "" || strings.TrimSpace(universeInput.Csv) == "" {
+		appUtil.AppLogger.Println(err)
+		return constants.AppErrInvalidUniverse
+	}
+
+	companies, instruments, err := ReadMasterListCsv(strings.NewReader(universeInput.Csv), universeName)
+	if err != nil || len(companies) == 0 {
+		appUtil.AppLogger.Println(err)
+		return constants.AppErrInvalidUniverse
+	}
+	err = loadUniverse(universeName, companies, instruments)
+	if err != nil {
+		appUtil.AppLogger.Println(err)
+		return constants.AppErrUploadUniverse
+	}
+	return constants.AppSuccessUploadUniverse
+}
+
+/* Members of an index on date, today when date is not provided */
+func GetIndexMembers(userInput []byte) (data.IndexMembersOutputJson, error) {
+	var membersOutput data.IndexMembersOutputJson
+	var membersInput data.IndexMembersInputJson
+	json.Unmarshal(userInput, &membersInput)
+
+	asOf := time.Now()
+	if membersInput.Date != "" {
+		date, err := time.Parse("2006-01-02", membersInput.Date)
+		if err != nil {
+			appUtil.AppLogger.Println(err)
+			return membersOutput, err
+		}
+		asOf = date
+	}
+	membersOutput.IndexId = strings.ToUpper(strings.TrimSpace(membersInput.IndexId))
+	membersOutput.Date = asOf.Format("2006-01-02")
+	members, err := data.GetIndexMembersDB(membersOutput.IndexId, asOf, appUtil.Db)
+	if err != nil {
+		appUtil.AppLogger.Println(err)
+		return membersOutput, err
+	}
+	membersOutput.Members = members
+	return membersOutput, nil
+}
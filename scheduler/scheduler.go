@@ -0,0 +1,128 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"strings"
+	"sync/atomic"
+
+	"github.com/robfig/cron/v3"
+)
+
+/* Schedule value which disables a job */
+const ScheduleOff = "off"
+
+/* Background job run on a cron schedule. Run returns a message which is logged */
+type Job struct {
+	Name     string
+	Schedule string
+	Run      func() string
+	running  int32
+}
+
+/* Registry of jobs. A job does not start while its previous run is in progress in this process,
+** nor while another replica holds its Postgres advisory lock */
+type Scheduler struct {
+	cron   *cron.Cron
+	db     *sql.DB
+	logger *log.Logger
+	jobs   []*Job
+}
+
+func New(db *sql.DB, logger *log.Logger) *Scheduler {
+	return &Scheduler{cron: cron.New(), db: db, logger: logger}
+}
+
+/* Add job on schedule. Empty or off schedule leaves job disabled */
+func (scheduler *Scheduler) Register(name string, schedule string, run func() string) error {
+	schedule = strings.TrimSpace(schedule)
+	if schedule == "" || strings.EqualFold(schedule, ScheduleOff) {
+		scheduler.logger.Println("Job " + name + " is disabled")
+		return nil
+	}
+	job := &Job{Name: name, Schedule: schedule, Run: run}
+	_, err := scheduler.cron.AddFunc(schedule, func() { scheduler.RunJob(job) })
+	if err != nil {
+		return fmt.Errorf("invalid schedule %s for job %s: %v", schedule, name, err)
+	}
+	scheduler.jobs = append(scheduler.jobs, job)
+	scheduler.logger.Println("Job " + name + " scheduled at " + schedule)
+	return nil
+}
+
+func (scheduler *Scheduler) Start() {
+	scheduler.cron.Start()
+}
+
+func (scheduler *Scheduler) Jobs() []*Job {
+	return scheduler.jobs
+}
+
+/* Run job now unless it is already running here or in another replica */
+func (scheduler *Scheduler) RunJob(job *Job) {
+	if !atomic.CompareAndSwapInt32(&job.running, 0, 1) {
+		scheduler.logger.Println("Skipping job " + job.Name + " as previous run is in progress")
+		return
+	}
+	defer atomic.StoreInt32(&job.running, 0)
+
+	isLocked, err := scheduler.withAdvisoryLock(job.Name, func() {
+		scheduler.logger.Println("Starting job " + job.Name)
+		msg := job.Run()
+		scheduler.logger.Println("Completed job " + job.Name + " : " + msg)
+	})
+	if err != nil {
+		scheduler.logger.Println(err.Error(), " Error while running job "+job.Name)
+		return
+	}
+	if !isLocked {
+		scheduler.logger.Println("Skipping job " + job.Name + " as it is running in another instance")
+	}
+}
+
+/* Run fn holding session level advisory lock of name. Lock and unlock use the same connection */
+func (scheduler *Scheduler) withAdvisoryLock(name string, fn func()) (bool, error) {
+	ctx := context.Background()
+	conn, err := scheduler.db.Conn(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	key := lockKey(name)
+	var isLocked bool
+	err = conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&isLocked)
+	if err != nil || !isLocked {
+		return false, err
+	}
+	defer conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", key)
+
+	fn()
+	return true, nil
+}
+
+/* Advisory lock key of job, same across replicas */
+func lockKey(name string) int64 {
+	hash := fnv.New64a()
+	hash.Write([]byte("PortfolioApis:job:" + name))
+	return int64(hash.Sum64())
+}
+
+/* Parse job=schedule pairs separated by ; (eg: pricerefresh=@hourly;masterlist=0 6 * * 1) */
+func ParseSchedules(spec string) (map[string]string, error) {
+	schedules := make(map[string]string)
+	for _, entry := range strings.Split(spec, ";") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		entryParts := strings.SplitN(entry, "=", 2)
+		if len(entryParts) != 2 || strings.TrimSpace(entryParts[0]) == "" {
+			return schedules, fmt.Errorf("invalid job schedule %s", entry)
+		}
+		schedules[strings.ToLower(strings.TrimSpace(entryParts[0]))] = strings.TrimSpace(entryParts[1])
+	}
+	return schedules, nil
+}
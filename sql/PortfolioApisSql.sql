@@ -124,4 +124,410 @@ alter table users add column password character varying(500)
 TABLESPACE pg_default;
 
 ALTER TABLE public.users
-    OWNER to postgres;
\ No newline at end of file
+    OWNER to postgres;
+	
+-- Table: public.user_transactions
+
+-- DROP TABLE public.user_transactions;
+
+CREATE TABLE IF NOT EXISTS public.user_transactions
+(
+    transaction_id bigserial NOT NULL,
+    user_id character varying(30) COLLATE pg_catalog."default" NOT NULL,
+    company_id character varying(30) COLLATE pg_catalog."default" NOT NULL,
+    txn_type character varying(10) COLLATE pg_catalog."default" NOT NULL,
+    quantity numeric(30,10) NOT NULL,
+    price numeric(30,10) NOT NULL DEFAULT 0,
+    fees numeric(30,10) NOT NULL DEFAULT 0,
+    brokerage numeric(30,10) NOT NULL DEFAULT 0,
+    taxes numeric(30,10) NOT NULL DEFAULT 0,
+    txn_date date NOT NULL,
+    settlement_date date,
+    notes character varying(500) COLLATE pg_catalog."default",
+    CONSTRAINT user_transactions_pkey PRIMARY KEY (transaction_id),
+    CONSTRAINT user_transactions_type_check CHECK (txn_type IN ('BUY', 'SELL', 'DIVIDEND', 'SPLIT')),
+    CONSTRAINT user_transactions_qty_check CHECK (quantity > 0)
+)
+
+TABLESPACE pg_default;
+
+ALTER TABLE public.user_transactions
+    OWNER to postgres;
+
+CREATE INDEX IF NOT EXISTS user_transactions_user_idx
+    ON public.user_transactions (user_id, txn_date);
+
+-- Migration: user_holdings -> user_transactions
+-- Negative quantity rows were sells with the sell price stored in buy_price.
+-- user_holdings is retained as is and no longer read by the application.
+-- Users whose holdings are already migrated are skipped, so the script can be re-run.
+
+INSERT INTO public.user_transactions(user_id, company_id, txn_type, quantity, price, txn_date, settlement_date, notes)
+SELECT user_id, company_id,
+       CASE WHEN quantity < 0 THEN 'SELL' ELSE 'BUY' END,
+       ABS(quantity), buy_price, buy_date, buy_date, 'Migrated from user_holdings'
+FROM public.user_holdings holding
+WHERE quantity IS NOT NULL AND quantity != 0
+  AND NOT EXISTS (SELECT 1 FROM public.user_transactions txn
+                  WHERE txn.user_id = holding.user_id AND txn.notes LIKE 'Migrated from user_holdings%');
+
+-- Legacy holdings were never checked for sells exceeding the quantity held, while the ledger rejects every
+-- later transaction of such a position. Migrated sells are capped at the quantity held on their date, in the
+-- order the ledger is replayed, and dropped when nothing is held. Original rows remain in user_holdings.
+
+DO $$
+DECLARE
+    txn record;
+    txn_position text := '';
+    held numeric := 0;
+BEGIN
+    FOR txn IN SELECT transaction_id, user_id, company_id, txn_type, quantity FROM public.user_transactions
+               WHERE notes LIKE 'Migrated from user_holdings%'
+               ORDER BY user_id, company_id, txn_date, transaction_id
+    LOOP
+        IF txn.user_id || ':' || txn.company_id != txn_position THEN
+            txn_position := txn.user_id || ':' || txn.company_id;
+            held := 0;
+        END IF;
+        IF txn.txn_type = 'BUY' THEN
+            held := held + txn.quantity;
+        ELSIF txn.quantity <= held THEN
+            held := held - txn.quantity;
+        ELSIF held = 0 THEN
+            DELETE FROM public.user_transactions WHERE transaction_id = txn.transaction_id;
+        ELSE
+            UPDATE public.user_transactions
+            SET quantity = held, notes = 'Migrated from user_holdings, sell of ' || txn.quantity || ' capped at holding'
+            WHERE transaction_id = txn.transaction_id;
+            held := 0;
+        END IF;
+    END LOOP;
+END $$;
+
+	
+-- Table: public.portfolios
+
+-- DROP TABLE public.portfolios;
+
+CREATE TABLE IF NOT EXISTS public.portfolios
+(
+    portfolio_id bigserial NOT NULL,
+    user_id character varying(30) COLLATE pg_catalog."default" NOT NULL,
+    portfolio_name character varying(100) COLLATE pg_catalog."default" NOT NULL,
+    target_amount numeric(30,10),
+    created_date date,
+    CONSTRAINT portfolios_pkey PRIMARY KEY (portfolio_id),
+    CONSTRAINT portfolios_user_name_key UNIQUE (user_id, portfolio_name),
+    CONSTRAINT portfolios_user_fkey FOREIGN KEY (user_id) REFERENCES public.users (user_id)
+)
+
+TABLESPACE pg_default;
+
+ALTER TABLE public.portfolios
+    OWNER to postgres;
+
+-- Migration: one Default portfolio per user carrying users.target_amount
+
+INSERT INTO public.portfolios(user_id, portfolio_name, target_amount, created_date)
+SELECT user_id, 'Default', target_amount, COALESCE(start_date, CURRENT_DATE)
+FROM public.users
+ON CONFLICT (user_id, portfolio_name) DO NOTHING;
+
+ALTER TABLE public.user_transactions ADD COLUMN IF NOT EXISTS portfolio_id bigint;
+ALTER TABLE public.user_holdings_nt ADD COLUMN IF NOT EXISTS portfolio_id bigint;
+ALTER TABLE public.user_model_pf ADD COLUMN IF NOT EXISTS portfolio_id bigint;
+
+UPDATE public.user_transactions txn SET portfolio_id = pf.portfolio_id
+FROM public.portfolios pf WHERE pf.user_id = txn.user_id AND pf.portfolio_name = 'Default' AND txn.portfolio_id IS NULL;
+
+UPDATE public.user_holdings_nt nt SET portfolio_id = pf.portfolio_id
+FROM public.portfolios pf WHERE pf.user_id = nt.user_id AND pf.portfolio_name = 'Default' AND nt.portfolio_id IS NULL;
+
+UPDATE public.user_model_pf model SET portfolio_id = pf.portfolio_id
+FROM public.portfolios pf WHERE pf.user_id = model.user_id AND pf.portfolio_name = 'Default' AND model.portfolio_id IS NULL;
+
+ALTER TABLE public.user_transactions ALTER COLUMN portfolio_id SET NOT NULL;
+ALTER TABLE public.user_model_pf ALTER COLUMN portfolio_id SET NOT NULL;
+
+ALTER TABLE public.user_model_pf DROP CONSTRAINT IF EXISTS user_model_pf_pkey;
+ALTER TABLE public.user_model_pf ADD CONSTRAINT user_model_pf_pkey PRIMARY KEY (portfolio_id, security_id);
+
+CREATE INDEX IF NOT EXISTS user_transactions_portfolio_idx
+    ON public.user_transactions (portfolio_id, txn_date);
+
+CREATE INDEX IF NOT EXISTS user_holdings_nt_portfolio_idx
+    ON public.user_holdings_nt (portfolio_id);
+
+-- Target amount now lives on portfolios
+ALTER TABLE public.users DROP COLUMN IF EXISTS target_amount;
+
+	
+-- Table: public.portfolio_grants
+
+-- DROP TABLE public.portfolio_grants;
+
+CREATE TABLE IF NOT EXISTS public.portfolio_grants
+(
+    portfolio_id bigint NOT NULL,
+    grantee_user_id character varying(30) COLLATE pg_catalog."default" NOT NULL,
+    permission character varying(10) COLLATE pg_catalog."default" NOT NULL,
+    granted_date date,
+    CONSTRAINT portfolio_grants_pkey PRIMARY KEY (portfolio_id, grantee_user_id),
+    CONSTRAINT portfolio_grants_permission_check CHECK (permission IN ('READ', 'WRITE')),
+    CONSTRAINT portfolio_grants_portfolio_fkey FOREIGN KEY (portfolio_id) REFERENCES public.portfolios (portfolio_id) ON DELETE CASCADE,
+    CONSTRAINT portfolio_grants_grantee_fkey FOREIGN KEY (grantee_user_id) REFERENCES public.users (user_id)
+)
+
+TABLESPACE pg_default;
+
+ALTER TABLE public.portfolio_grants
+    OWNER to postgres;
+
+CREATE INDEX IF NOT EXISTS portfolio_grants_grantee_idx
+    ON public.portfolio_grants (grantee_user_id);
+
+	
+-- Table: public.instruments
+
+-- DROP TABLE public.instruments;
+
+CREATE TABLE IF NOT EXISTS public.instruments
+(
+    company_id character varying(30) COLLATE pg_catalog."default" NOT NULL,
+    exchange character varying(10) COLLATE pg_catalog."default" NOT NULL DEFAULT 'NSE',
+    asset_class character varying(10) COLLATE pg_catalog."default" NOT NULL DEFAULT 'EQUITY',
+    currency character varying(3) COLLATE pg_catalog."default" NOT NULL DEFAULT 'INR',
+    provider character varying(20) COLLATE pg_catalog."default",
+    provider_symbol character varying(40) COLLATE pg_catalog."default",
+    isin character varying(12) COLLATE pg_catalog."default",
+    CONSTRAINT instruments_pkey PRIMARY KEY (company_id),
+    CONSTRAINT instruments_asset_class_check CHECK (asset_class IN ('EQUITY', 'ETF', 'MF', 'INDEX', 'BOND', 'GOLD')),
+    CONSTRAINT instruments_company_fkey FOREIGN KEY (company_id) REFERENCES public.companies (company_id) ON DELETE CASCADE
+)
+
+TABLESPACE pg_default;
+
+ALTER TABLE public.instruments
+    OWNER to postgres;
+
+-- Migration: classify existing companies using the earlier ticker rules
+-- (0P00 prefix => BSE MF, BSE- => BSE index, NSEI => NSE index, others => NSE equity)
+
+INSERT INTO public.instruments(company_id, exchange, asset_class, currency, provider_symbol)
+SELECT company_id,
+    CASE WHEN company_id LIKE '%0P00%' OR company_id LIKE '%BSE-%' THEN 'BSE' ELSE 'NSE' END,
+    CASE WHEN company_id LIKE '%0P00%' THEN 'MF'
+         WHEN company_id LIKE '%BSE-%' OR company_id = 'NSEI' THEN 'INDEX'
+         ELSE 'EQUITY' END,
+    'INR',
+    CASE WHEN company_id LIKE '%0P00%' OR company_id LIKE '%BSE-%' THEN company_id || '.BO'
+         WHEN company_id = 'NSEI' THEN '^' || company_id
+         ELSE company_id || '.NS' END
+FROM public.companies
+ON CONFLICT (company_id) DO NOTHING;
+
+-- Migration: adjusted close and volume from price feed
+-- adj_close_val is null for rows loaded earlier, readers fall back to close_val
+
+ALTER TABLE public.companies_price_data ADD COLUMN IF NOT EXISTS adj_close_val numeric(30,10);
+ALTER TABLE public.companies_price_data ADD COLUMN IF NOT EXISTS volume bigint;
+
+-- Table: public.price_events
+
+-- DROP TABLE public.price_events;
+
+CREATE TABLE IF NOT EXISTS public.price_events
+(
+    company_id character varying(30) COLLATE pg_catalog."default" NOT NULL,
+    event_date date NOT NULL,
+    event_type character varying(10) COLLATE pg_catalog."default" NOT NULL,
+    amount numeric(30,10),
+    split_to integer,
+    split_from integer,
+    CONSTRAINT price_events_pkey PRIMARY KEY (company_id, event_date, event_type),
+    CONSTRAINT price_events_event_type_check CHECK (event_type IN ('DIVIDEND', 'SPLIT'))
+)
+
+TABLESPACE pg_default;
+
+ALTER TABLE public.price_events
+    OWNER to postgres;
+
+-- Table: public.corporate_actions
+
+-- DROP TABLE public.corporate_actions;
+
+-- SPLIT ratio_to:ratio_from new shares for old shares, BONUS ratio_to new shares for every ratio_from held,
+-- DIVIDEND amount per share. Source is FEED when derived from price_events, ADMIN otherwise.
+
+CREATE TABLE IF NOT EXISTS public.corporate_actions
+(
+    company_id character varying(30) COLLATE pg_catalog."default" NOT NULL,
+    ex_date date NOT NULL,
+    action_type character varying(10) COLLATE pg_catalog."default" NOT NULL,
+    ratio_to integer NOT NULL DEFAULT 1,
+    ratio_from integer NOT NULL DEFAULT 1,
+    amount numeric(30,10) NOT NULL DEFAULT 0,
+    source character varying(10) COLLATE pg_catalog."default" NOT NULL DEFAULT 'ADMIN',
+    CONSTRAINT corporate_actions_pkey PRIMARY KEY (company_id, ex_date, action_type),
+    CONSTRAINT corporate_actions_action_type_check CHECK (action_type IN ('SPLIT', 'BONUS', 'DIVIDEND')),
+    CONSTRAINT corporate_actions_ratio_check CHECK (ratio_to > 0 AND ratio_from > 0)
+)
+
+TABLESPACE pg_default;
+
+ALTER TABLE public.corporate_actions
+    OWNER to postgres;
+
+-- Migration: corporate actions from events already ingested
+
+INSERT INTO public.corporate_actions(company_id, ex_date, action_type, ratio_to, ratio_from, amount, source)
+SELECT company_id, event_date, event_type, COALESCE(split_to, 1), COALESCE(split_from, 1), COALESCE(amount, 0), 'FEED'
+FROM public.price_events
+ON CONFLICT (company_id, ex_date, action_type) DO NOTHING;
+
+-- Migration: zero/null rows were inserted for unparseable provider rows, they are now rejected at ingestion
+
+DELETE FROM public.companies_price_data WHERE close_val IS NULL OR close_val <= 0;
+
+-- Table: public.price_quality_flags
+
+-- DROP TABLE public.price_quality_flags;
+
+-- Latest data quality findings per company. flag_type JUMP for suspicious close change, GAP for missing trading days
+
+CREATE TABLE IF NOT EXISTS public.price_quality_flags
+(
+    company_id character varying(30) COLLATE pg_catalog."default" NOT NULL,
+    date_val date NOT NULL,
+    flag_type character varying(10) COLLATE pg_catalog."default" NOT NULL,
+    detail character varying(200) COLLATE pg_catalog."default",
+    detected_at timestamp without time zone NOT NULL DEFAULT now(),
+    CONSTRAINT price_quality_flags_pkey PRIMARY KEY (company_id, date_val, flag_type),
+    CONSTRAINT price_quality_flags_flag_type_check CHECK (flag_type IN ('JUMP', 'GAP'))
+)
+
+TABLESPACE pg_default;
+
+ALTER TABLE public.price_quality_flags
+    OWNER to postgres;
+
+-- Migration: load_date was set even when price load failed. Companies without prices are refreshed from complete history
+
+UPDATE public.companies SET load_date = NULL
+WHERE NOT EXISTS (SELECT 1 FROM public.companies_price_data WHERE companies_price_data.company_id = companies.company_id);
+
+-- Table: public.ingestion_jobs
+
+-- DROP TABLE public.ingestion_jobs;
+
+-- Price ingestion runs. trigger SCHEDULED for cron runs, MANUAL for /updateselectedcompanies
+
+CREATE TABLE IF NOT EXISTS public.ingestion_jobs
+(
+    job_id bigserial NOT NULL,
+    trigger character varying(20) COLLATE pg_catalog."default" NOT NULL,
+    requested_by character varying(30) COLLATE pg_catalog."default",
+    status character varying(10) COLLATE pg_catalog."default" NOT NULL,
+    instruments text COLLATE pg_catalog."default" NOT NULL DEFAULT '',
+    total integer NOT NULL DEFAULT 0,
+    succeeded integer NOT NULL DEFAULT 0,
+    failed integer NOT NULL DEFAULT 0,
+    skipped integer NOT NULL DEFAULT 0,
+    rows_inserted integer NOT NULL DEFAULT 0,
+    rows_updated integer NOT NULL DEFAULT 0,
+    rows_rejected integer NOT NULL DEFAULT 0,
+    errors text COLLATE pg_catalog."default",
+    queued_at timestamp without time zone NOT NULL DEFAULT now(),
+    started_at timestamp without time zone,
+    finished_at timestamp without time zone,
+    owner character varying(100) COLLATE pg_catalog."default",
+    heartbeat_at timestamp without time zone,
+    CONSTRAINT ingestion_jobs_pkey PRIMARY KEY (job_id),
+    CONSTRAINT ingestion_jobs_status_check CHECK (status IN ('QUEUED', 'RUNNING', 'SUCCEEDED', 'PARTIAL', 'FAILED'))
+)
+
+TABLESPACE pg_default;
+
+ALTER TABLE public.ingestion_jobs
+    OWNER to postgres;
+
+CREATE INDEX IF NOT EXISTS ingestion_jobs_status_idx
+    ON public.ingestion_jobs USING btree (status);
+
+-- Migration: instance running a job and its last heartbeat, so a restart fails only jobs no instance is running
+
+ALTER TABLE public.ingestion_jobs ADD COLUMN IF NOT EXISTS owner character varying(100) COLLATE pg_catalog."default";
+ALTER TABLE public.ingestion_jobs ADD COLUMN IF NOT EXISTS heartbeat_at timestamp without time zone;
+
+-- Migration: industry and ISIN of companies from master lists
+
+ALTER TABLE public.companies ADD COLUMN IF NOT EXISTS industry character varying(100) COLLATE pg_catalog."default";
+ALTER TABLE public.companies ADD COLUMN IF NOT EXISTS isin character varying(12) COLLATE pg_catalog."default";
+
+-- Table: public.index_membership
+
+-- DROP TABLE public.index_membership;
+
+-- Constituents of indices/universes. Company is a member from effective_from till the day before effective_to, effective_to is null while it is a member
+
+CREATE TABLE IF NOT EXISTS public.index_membership
+(
+    index_id character varying(30) COLLATE pg_catalog."default" NOT NULL,
+    company_id character varying(30) COLLATE pg_catalog."default" NOT NULL,
+    effective_from date NOT NULL,
+    effective_to date,
+    CONSTRAINT index_membership_pkey PRIMARY KEY (index_id, company_id, effective_from)
+)
+
+TABLESPACE pg_default;
+
+ALTER TABLE public.index_membership
+    OWNER to postgres;
+
+CREATE INDEX IF NOT EXISTS index_membership_company_idx
+    ON public.index_membership USING btree (company_id);
+
+-- Table: public.portfolio_snapshots
+
+-- DROP TABLE public.portfolio_snapshots;
+
+-- Daily valuation of consolidated portfolio of a user. Values are null on days without them (eg: xirr in first 6 months)
+
+CREATE TABLE IF NOT EXISTS public.portfolio_snapshots
+(
+    user_id character varying(30) COLLATE pg_catalog."default" NOT NULL,
+    snapshot_date date NOT NULL,
+    market_value numeric(20,6),
+    invested numeric(20,6),
+    equity_value numeric(20,6),
+    debt_value numeric(20,6),
+    benchmark_value numeric(20,6),
+    xirr double precision,
+    benchmark_xirr double precision,
+    computed_at timestamp without time zone NOT NULL DEFAULT now(),
+    CONSTRAINT portfolio_snapshots_pkey PRIMARY KEY (user_id, snapshot_date)
+)
+
+TABLESPACE pg_default;
+
+ALTER TABLE public.portfolio_snapshots
+    OWNER to postgres;
+
+-- Table: public.portfolio_snapshot_versions
+
+-- DROP TABLE public.portfolio_snapshot_versions;
+
+-- Incremented when snapshots of user are invalidated, so that a computation started before is not saved
+
+CREATE TABLE IF NOT EXISTS public.portfolio_snapshot_versions
+(
+    user_id character varying(30) COLLATE pg_catalog."default" NOT NULL,
+    version bigint NOT NULL DEFAULT 0,
+    CONSTRAINT portfolio_snapshot_versions_pkey PRIMARY KEY (user_id)
+)
+
+TABLESPACE pg_default;
+
+ALTER TABLE public.portfolio_snapshot_versions
+    OWNER to postgres;
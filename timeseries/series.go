@@ -0,0 +1,132 @@
+package timeseries
+
+import (
+	"sort"
+	"time"
+
+	"github.com/vijayyogesh/PortfolioApis/money"
+)
+
+/* Values by calendar day in date order. Days are stored as days since 1970-01-01 of the date part,
+** so lookups do not depend on time of day or location. Series is not modified once built */
+type Series struct {
+	days   []int32
+	values []money.Decimal
+}
+
+/* Series of values on dates. Dates are sorted when needed and a later value of the same day replaces the earlier one */
+func New(dates []time.Time, values []money.Decimal) *Series {
+	series := &Series{days: make([]int32, 0, len(dates)), values: make([]money.Decimal, 0, len(dates))}
+	order := make([]int, len(dates))
+	for key := range order {
+		order[key] = key
+	}
+	sort.SliceStable(order, func(i, j int) bool { return dayOf(dates[order[i]]) < dayOf(dates[order[j]]) })
+
+	for _, key := range order {
+		day := dayOf(dates[key])
+		if last := len(series.days) - 1; last >= 0 && series.days[last] == day {
+			series.values[last] = values[key]
+			continue
+		}
+		series.days = append(series.days, day)
+		series.values = append(series.values, values[key])
+	}
+	return series
+}
+
+func (series *Series) Len() int {
+	return len(series.days)
+}
+
+func (series *Series) Date(key int) time.Time {
+	return dateOf(series.days[key])
+}
+
+func (series *Series) Value(key int) money.Decimal {
+	return series.values[key]
+}
+
+/* Value on date */
+func (series *Series) At(date time.Time) (money.Decimal, bool) {
+	day := dayOf(date)
+	key := series.after(day) - 1
+	if key >= 0 && series.days[key] == day {
+		return series.values[key], true
+	}
+	return money.Zero, false
+}
+
+/* Value on date or the latest before it, i.e. forward filled over days without a value */
+func (series *Series) AsOf(date time.Time) (money.Decimal, bool) {
+	key := series.after(dayOf(date)) - 1
+	if key < 0 {
+		return money.Zero, false
+	}
+	return series.values[key], true
+}
+
+/* First value on or after date, with its date */
+func (series *Series) OnOrAfter(date time.Time) (time.Time, money.Decimal, bool) {
+	day := dayOf(date)
+	key := sort.Search(len(series.days), func(i int) bool { return series.days[i] >= day })
+	if key == len(series.days) {
+		return time.Time{}, money.Zero, false
+	}
+	return dateOf(series.days[key]), series.values[key], true
+}
+
+/* Cursor for lookups on dates in increasing order */
+func (series *Series) Cursor() *Cursor {
+	return &Cursor{series: series}
+}
+
+/* Position of first day after day */
+func (series *Series) after(day int32) int {
+	return sort.Search(len(series.days), func(i int) bool { return series.days[i] > day })
+}
+
+/* Walks a series as dates increase, so a lookup on the next day is constant time.
+** A date before the previous lookup falls back to binary search */
+type Cursor struct {
+	series *Series
+	next   int
+}
+
+func (cursor *Cursor) At(date time.Time) (money.Decimal, bool) {
+	day := cursor.seek(date)
+	if cursor.next > 0 && cursor.series.days[cursor.next-1] == day {
+		return cursor.series.values[cursor.next-1], true
+	}
+	return money.Zero, false
+}
+
+func (cursor *Cursor) AsOf(date time.Time) (money.Decimal, bool) {
+	cursor.seek(date)
+	if cursor.next == 0 {
+		return money.Zero, false
+	}
+	return cursor.series.values[cursor.next-1], true
+}
+
+/* Move next to the first day after date */
+func (cursor *Cursor) seek(date time.Time) int32 {
+	day := dayOf(date)
+	days := cursor.series.days
+	if cursor.next > 0 && days[cursor.next-1] > day {
+		cursor.next = cursor.series.after(day)
+		return day
+	}
+	for cursor.next < len(days) && days[cursor.next] <= day {
+		cursor.next++
+	}
+	return day
+}
+
+func dayOf(date time.Time) int32 {
+	return int32(time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC).Unix() / 86400)
+}
+
+func dateOf(day int32) time.Time {
+	return time.Unix(int64(day)*86400, 0).UTC()
+}
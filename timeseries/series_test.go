@@ -0,0 +1,144 @@
+package timeseries
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vijayyogesh/PortfolioApis/money"
+)
+
+func date(val string) time.Time {
+	parsed, _ := time.Parse("2006-01-02", val)
+	return parsed
+}
+
+/* Prices on Fri 2024-01-05, Mon 2024-01-08 and Wed 2024-01-10 given out of order with a duplicate */
+func testSeries() *Series {
+	dates := []time.Time{date("2024-01-10"), date("2024-01-05"), date("2024-01-08"), date("2024-01-05").Add(10 * time.Hour)}
+	values := []money.Decimal{money.NewFromInt(30), money.NewFromInt(10), money.NewFromInt(20), money.NewFromInt(11)}
+	return New(dates, values)
+}
+
+func TestNew(t *testing.T) {
+	series := testSeries()
+	if series.Len() != 3 {
+		t.Fatalf("Len = %d, want 3", series.Len())
+	}
+	wantDates := []string{"2024-01-05", "2024-01-08", "2024-01-10"}
+	wantValues := []int64{11, 20, 30}
+	for key := range wantDates {
+		if got := series.Date(key).Format("2006-01-02"); got != wantDates[key] {
+			t.Errorf("Date(%d) = %s, want %s", key, got, wantDates[key])
+		}
+		if !series.Value(key).Equal(money.NewFromInt(wantValues[key])) {
+			t.Errorf("Value(%d) = %s, want %d", key, series.Value(key), wantValues[key])
+		}
+	}
+
+	empty := New(nil, nil)
+	if _, ok := empty.AsOf(date("2024-01-05")); ok || empty.Len() != 0 {
+		t.Errorf("empty series has values")
+	}
+}
+
+func TestLookups(t *testing.T) {
+	series := testSeries()
+	tests := []struct {
+		date        string
+		at          int64
+		atOk        bool
+		asOf        int64
+		asOfOk      bool
+		onOrAfter   string
+		onOrAfterOk bool
+	}{
+		{"2024-01-04", 0, false, 0, false, "2024-01-05", true},
+		{"2024-01-05", 11, true, 11, true, "2024-01-05", true},
+		{"2024-01-06", 0, false, 11, true, "2024-01-08", true},
+		{"2024-01-08", 20, true, 20, true, "2024-01-08", true},
+		{"2024-01-09", 0, false, 20, true, "2024-01-10", true},
+		{"2024-01-10", 30, true, 30, true, "2024-01-10", true},
+		{"2024-01-11", 0, false, 30, true, "", false},
+	}
+	for _, test := range tests {
+		day := date(test.date)
+		if got, ok := series.At(day); ok != test.atOk || !got.Equal(money.NewFromInt(test.at)) {
+			t.Errorf("At(%s) = %s %v, want %d %v", test.date, got, ok, test.at, test.atOk)
+		}
+		if got, ok := series.AsOf(day); ok != test.asOfOk || !got.Equal(money.NewFromInt(test.asOf)) {
+			t.Errorf("AsOf(%s) = %s %v, want %d %v", test.date, got, ok, test.asOf, test.asOfOk)
+		}
+		gotDate, _, ok := series.OnOrAfter(day)
+		if ok != test.onOrAfterOk || (ok && gotDate.Format("2006-01-02") != test.onOrAfter) {
+			t.Errorf("OnOrAfter(%s) = %s %v, want %s %v", test.date, gotDate, ok, test.onOrAfter, test.onOrAfterOk)
+		}
+		/* Time of day does not change the day looked up */
+		if got, _ := series.AsOf(day.Add(23 * time.Hour)); !got.Equal(money.NewFromInt(test.asOf)) {
+			t.Errorf("AsOf(%s 23:00) = %s, want %d", test.date, got, test.asOf)
+		}
+	}
+}
+
+func TestCursor(t *testing.T) {
+	series := testSeries()
+	cursor := series.Cursor()
+
+	/* Forward walk matches series lookups */
+	for day := date("2024-01-03"); day.Before(date("2024-01-13")); day = day.AddDate(0, 0, 1) {
+		wantAt, wantAtOk := series.At(day)
+		gotAt, gotAtOk := cursor.At(day)
+		if gotAtOk != wantAtOk || !gotAt.Equal(wantAt) {
+			t.Errorf("Cursor.At(%s) = %s %v, want %s %v", day.Format("2006-01-02"), gotAt, gotAtOk, wantAt, wantAtOk)
+		}
+		wantAsOf, wantAsOfOk := series.AsOf(day)
+		gotAsOf, gotAsOfOk := cursor.AsOf(day)
+		if gotAsOfOk != wantAsOfOk || !gotAsOf.Equal(wantAsOf) {
+			t.Errorf("Cursor.AsOf(%s) = %s %v, want %s %v", day.Format("2006-01-02"), gotAsOf, gotAsOfOk, wantAsOf, wantAsOfOk)
+		}
+	}
+
+	/* Going back falls back to search */
+	if got, ok := cursor.AsOf(date("2024-01-06")); !ok || !got.Equal(money.NewFromInt(11)) {
+		t.Errorf("Cursor.AsOf after going back = %s %v, want 11", got, ok)
+	}
+	if _, ok := cursor.AsOf(date("2024-01-01")); ok {
+		t.Errorf("Cursor.AsOf before first date has value")
+	}
+	if got, ok := cursor.At(date("2024-01-10")); !ok || !got.Equal(money.NewFromInt(30)) {
+		t.Errorf("Cursor.At after going back and forward = %s %v, want 30", got, ok)
+	}
+}
+
+/* Valuing 30 holdings daily over 10 years, as in networth and xirr loops */
+func BenchmarkPortfolioTenYears(b *testing.B) {
+	start := date("2014-01-01")
+	var dates []time.Time
+	var values []money.Decimal
+	for day := start; day.Before(start.AddDate(10, 0, 0)); day = day.AddDate(0, 0, 1) {
+		if day.Weekday() != time.Saturday && day.Weekday() != time.Sunday {
+			dates = append(dates, day)
+			values = append(values, money.NewFromInt(int64(100+len(dates)%50)))
+		}
+	}
+	holdings := make([]*Series, 30)
+	for key := range holdings {
+		holdings[key] = New(dates, values)
+	}
+	qty := money.NewFromInt(10)
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		cursors := make([]*Cursor, len(holdings))
+		for key, series := range holdings {
+			cursors[key] = series.Cursor()
+		}
+		for day := start; day.Before(start.AddDate(10, 0, 0)); day = day.AddDate(0, 0, 1) {
+			total := money.Zero
+			for _, cursor := range cursors {
+				if closeVal, ok := cursor.AsOf(day); ok {
+					total = total.Add(closeVal.Mul(qty))
+				}
+			}
+		}
+	}
+}
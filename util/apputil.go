@@ -30,6 +30,53 @@ type Config struct {
 	AppDataDir string `mapstructure:"APP_DATA_DIR"`
 	AuthKey    string `mapstructure:"AUTH_JWT_KEY"`
 	AuthExp    int    `mapstructure:"AUTH_JWT_EXP_HRS"`
+
+	/* Price and master list providers: yahoo/local and nse/local */
+	PriceProvider          string `mapstructure:"PRICE_PROVIDER"`
+	PriceProviderUrl       string `mapstructure:"PRICE_PROVIDER_URL"`
+	PriceProviderOverrides string `mapstructure:"PRICE_PROVIDER_OVERRIDES"`
+	MasterListProvider     string `mapstructure:"MASTER_LIST_PROVIDER"`
+	MasterListProviderUrl  string `mapstructure:"MASTER_LIST_PROVIDER_URL"`
+	FixtureDir             string `mapstructure:"APP_FIXTURE_DIR"`
+
+	/* Universes loaded into master list as NSE index names or NAME=url separated by ; (eg: NIFTY500;NIFTY50;BSE100=https://...) */
+	Universes string `mapstructure:"APP_UNIVERSES"`
+	StandInPort            int    `mapstructure:"STANDIN_PORT"`
+
+	/* AMFI mutual fund NAV urls, or directory having NAVAll.txt/NAVHistory.txt to read instead */
+	AmfiNavUrl     string `mapstructure:"AMFI_NAV_URL"`
+	AmfiHistoryUrl string `mapstructure:"AMFI_HISTORY_URL"`
+	AmfiNavDir     string `mapstructure:"AMFI_NAV_DIR"`
+
+	/* Price ingestion batch size and optional directory to archive raw provider payloads */
+	IngestBatchSize int    `mapstructure:"APP_INGEST_BATCH_SIZE"`
+	ArchiveDir      string `mapstructure:"APP_ARCHIVE_DIR"`
+
+	/* Data quality: close change % flagged as jump and whether recent gaps are refetched after ingestion */
+	DQJumpPct  int  `mapstructure:"APP_DQ_JUMP_PCT"`
+	DQBackfill bool `mapstructure:"APP_DQ_BACKFILL"`
+
+	/* Provider downloads: requests per second and burst of shared rate limiter, parallel downloads,
+	** retries on 429/5xx and consecutive failures after which provider is paused for cooldown seconds */
+	ProviderRatePerSec          float64 `mapstructure:"APP_PROVIDER_RATE_PER_SEC"`
+	ProviderBurst               int     `mapstructure:"APP_PROVIDER_BURST"`
+	ProviderConcurrency         int     `mapstructure:"APP_PROVIDER_CONCURRENCY"`
+	ProviderMaxRetries          int     `mapstructure:"APP_PROVIDER_MAX_RETRIES"`
+	ProviderBreakerThreshold    int     `mapstructure:"APP_PROVIDER_BREAKER_THRESHOLD"`
+	ProviderBreakerCooldownSecs int     `mapstructure:"APP_PROVIDER_BREAKER_COOLDOWN_SECS"`
+
+	/* Background job schedules as job=cron spec pairs separated by ; (eg: pricerefresh=@hourly;masterlist=0 6 * * 1) */
+	JobSchedules string `mapstructure:"APP_JOB_SCHEDULES"`
+
+	/* Companies whose full price history is kept in cache, least recently used are evicted beyond it */
+	PriceCacheSize int `mapstructure:"APP_PRICE_CACHE_SIZE"`
+
+	/* Directory of exchange holiday files NSE.csv/BSE.csv */
+	HolidayDir string `mapstructure:"APP_HOLIDAY_DIR"`
+
+	/* Benchmark index and comma separated admin users allowed to manage instruments */
+	Benchmark  string `mapstructure:"APP_BENCHMARK"`
+	AdminUsers string `mapstructure:"APP_ADMIN_USERS"`
 }
 
 /* Initialize/Create AppLevel/Global objects
@@ -89,6 +136,7 @@ func LoadConfig(path string) (config *Config) {
 	handleCriticalErr(errUnmarshal)
 
 	Logger.Printf("ENV FILE VALUES - host=%s port=%d user=%s dbname=%s dbdriver=%s datadir=%s", config.DBHost, config.DBPort, config.DBUser, config.DBName, config.DBDriver, config.AppDataDir)
+	Logger.Printf("PROVIDERS - price=%s url=%s overrides=%s master=%s url=%s fixturedir=%s", config.PriceProvider, config.PriceProviderUrl, config.PriceProviderOverrides, config.MasterListProvider, config.MasterListProviderUrl, config.FixtureDir)
 	Logger.Println("Completed LoadConfig")
 	return config
 }